@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"boatdetect/internal/cache"
+	"boatdetect/internal/gdal"
+)
+
+const defaultCacheMaxSize = "20GB"
+
+type cacheOptions struct {
+	Dir          string `json:"dir" yaml:"dir"`
+	Disabled     bool   `json:"disabled" yaml:"disabled"`
+	MaxSize      string `json:"max_size" yaml:"max_size"`
+	HashContents bool   `json:"hash_contents" yaml:"hash_contents"`
+}
+
+func defaultCacheOptions() cacheOptions {
+	return cacheOptions{MaxSize: defaultCacheMaxSize}
+}
+
+// addCacheFlags binds the cache flags to opts, using its values as defaults.
+func addCacheFlags(fs *flag.FlagSet, opts *cacheOptions) {
+	fs.StringVar(&opts.Dir, "cache-dir", opts.Dir, "Persistent preprocessing cache directory; caching is off unless set (must be under the working directory for Docker GDAL)")
+	fs.BoolVar(&opts.Disabled, "no-cache", opts.Disabled, "Preprocess every scene without reading or writing the cache")
+	fs.StringVar(&opts.MaxSize, "cache-max-size", opts.MaxSize, "Prune least recently used cache entries above this size after a run, e.g. 500MB (0 disables)")
+	fs.BoolVar(&opts.HashContents, "cache-hash-content", opts.HashContents, "Key cache entries by a hash of the input contents instead of size, mtime and inode")
+}
+
+// enabled reports whether runs read and write the cache: a directory is set
+// and --no-cache is not.
+func (o cacheOptions) enabled() bool {
+	return o.Dir != "" && !o.Disabled
+}
+
+// openPreprocessCache opens the cache selected by the flags. Both results
+// are nil unless the cache is enabled.
+func openPreprocessCache(ctx context.Context, opts cacheOptions) (*cache.Cache, *gdal.PreprocessCache, error) {
+	if !opts.enabled() {
+		return nil, nil, nil
+	}
+	if _, err := cache.ParseSize(opts.MaxSize); err != nil {
+		return nil, nil, usagef("cache-max-size: %v", err)
+	}
+
+	store, err := cache.Open(opts.Dir, opts.HashContents)
+	if err != nil {
+		return nil, nil, err
+	}
+	pc, err := gdal.NewPreprocessCache(ctx, store)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, pc, nil
+}
+
+// pruneCache releases the run's leases and prunes the cache to maxSize.
+// Entries still leased by concurrent runs are kept.
+func pruneCache(store *cache.Cache, pc *gdal.PreprocessCache, maxSize string) error {
+	if err := pc.Release(); err != nil {
+		return err
+	}
+	if store == nil {
+		return nil
+	}
+	limit, err := cache.ParseSize(maxSize)
+	if err != nil {
+		return fmt.Errorf("cache-max-size: %w", err)
+	}
+	if limit == 0 {
+		return nil
+	}
+	if _, err := store.Prune(limit, 0); err != nil {
+		return fmt.Errorf("prune cache: %w", err)
+	}
+	return nil
+}
+
+func runCacheCommand(ctx context.Context, args []string) error {
+	return runCache(os.Stdout, args)
+}
+
+// runCache implements "boatdetect cache ls|prune|clear".
+func runCache(out io.Writer, args []string) error {
+	fs := newFlagSet("cache")
+	dir := fs.String("dir", "", "Preprocessing cache directory (required)")
+	maxSize := fs.String("max-size", "", "prune: remove least recently used entries above this size, e.g. 10GB")
+	maxAge := fs.Duration("max-age", 0, "prune: remove entries unused for longer than this, e.g. 720h")
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if err := parseArgs(fs, args); err != nil {
+			return err
+		}
+		return usagef("cache needs a subcommand: ls, prune or clear")
+	}
+	if err := parseArgs(fs, args[1:]); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return usagef("cache needs --dir")
+	}
+
+	store, err := cache.Open(*dir, false)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "ls":
+		return listCache(out, store)
+	case "prune":
+		limit, err := cache.ParseSize(*maxSize)
+		if err != nil {
+			return usagef("max-size: %v", err)
+		}
+		if limit == 0 && *maxAge == 0 {
+			return usagef("prune needs --max-size or --max-age")
+		}
+		removed, err := store.Prune(limit, *maxAge)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "removed %d entries (%s)\n", len(removed), cache.FormatSize(totalSize(removed)))
+		return err
+	case "clear":
+		n, err := store.Clear()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "removed %d entries\n", n)
+		return err
+	default:
+		return usagef("unknown cache command %q", args[0])
+	}
+}
+
+func listCache(out io.Writer, store *cache.Cache) error {
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "key\tsize\tlast_used\tsource"); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Key, cache.FormatSize(e.Size), e.LastUsed.Format(time.RFC3339), e.Source); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(tw, "total\t%s\t\t%d entries\n", cache.FormatSize(totalSize(entries)), len(entries)); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+func totalSize(entries []cache.Entry) int64 {
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	return total
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"boatdetect/internal/atomicfile"
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/imagery"
+)
+
+// chipExtensions maps the data chip formats to their file extensions.
+var chipExtensions = map[string]string{
+	"geotiff": ".tif",
+	"npy":     ".npy",
+}
+
+// validateChips checks the chip settings when chips are written or, for the
+// size, rendered into the report.
+func validateChips(cfg chipsConfig, report bool) error {
+	if cfg.Dir == "" && !report {
+		return nil
+	}
+	if cfg.Size <= 0 {
+		return usagef("chip-size must be positive")
+	}
+	if cfg.Dir == "" {
+		return nil
+	}
+	if _, ok := chipExtensions[cfg.Format]; !ok {
+		return usagef("unknown chip format %q", cfg.Format)
+	}
+	return nil
+}
+
+// chipGeoreference is the sidecar written next to NPY chips, which carry no
+// georeferencing or mask of their own.
+type chipGeoreference struct {
+	CRS          string     `json:"crs"`
+	GeoTransform [6]float64 `json:"geotransform"`
+	// NoData is the value of pixels outside the raster or at its nodata
+	// value. Valid pixels may hold it too; GeoTIFF chips mask them exactly.
+	NoData int `json:"nodata"`
+}
+
+// renderRunChips cuts a chip around every record of run from its grid.
+// When chips are enabled it writes them and sets the record's chip to the
+// PNG path, relative to the directory of the output so it resolves from the
+// detections file; counts numbers the chips of each scene. When thumbnails
+// is non-nil it receives each record's PNG.
+func renderRunChips(run sceneRun, grid gdal.Grid, records []candidateRecord, opts outputConfig, counts map[string]int, thumbnails [][]byte) error {
+	for i := range records {
+		if records[i].source != run.path {
+			continue
+		}
+		chip := imagery.Cut(grid, run.result.GeoTransform, records[i].candidate.Lon, records[i].candidate.Lat, opts.Chips.Size)
+		data, err := encodeImage(chip.WritePNG)
+		if err != nil {
+			return fmt.Errorf("encode chip: %w", err)
+		}
+		if thumbnails != nil {
+			thumbnails[i] = data
+		}
+		if opts.Chips.Dir == "" {
+			continue
+		}
+
+		counts[run.sceneID]++
+		base := filepath.Join(opts.Chips.Dir, fmt.Sprintf("%s_%03d", run.sceneID, counts[run.sceneID]))
+		if err := writeChip(base, chip, data, opts.Chips.Format); err != nil {
+			return err
+		}
+		records[i].chip = chipLink(opts.Path, base+".png")
+	}
+	return nil
+}
+
+// writeChip writes the PNG quicklook and data chip of base.
+func writeChip(base string, chip imagery.Chip, pngData []byte, format string) error {
+	if err := writeBytes(base+".png", pngData); err != nil {
+		return fmt.Errorf("write chip: %w", err)
+	}
+
+	switch format {
+	case "npy":
+		if err := atomicfile.Write(base+".npy", chip.WriteNPY); err != nil {
+			return fmt.Errorf("write chip: %w", err)
+		}
+		return atomicfile.Write(base+".json", func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(chipGeoreference{CRS: "EPSG:4326", GeoTransform: chip.GeoTransform})
+		})
+	default:
+		if err := atomicfile.Write(base+".tif", chip.WriteGeoTIFF); err != nil {
+			return fmt.Errorf("write chip: %w", err)
+		}
+		return nil
+	}
+}
+
+// chipLink returns chipPath relative to the directory of outPath, or as
+// given when it cannot be made relative.
+func chipLink(outPath, chipPath string) string {
+	rel, err := filepath.Rel(filepath.Dir(outPath), chipPath)
+	if err != nil {
+		return filepath.ToSlash(chipPath)
+	}
+	return filepath.ToSlash(rel)
+}
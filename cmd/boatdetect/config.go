@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/output"
+)
+
+// runConfig holds every detect setting. Defaults come from
+// defaultRunConfig, then the --config file, then explicitly set flags.
+type runConfig struct {
+	Input           string            `json:"input" yaml:"input"`
+	Output          outputConfig      `json:"output" yaml:"output"`
+	Preprocess      preprocessConfig  `json:"preprocess" yaml:"preprocess"`
+	Threshold       thresholdConfig   `json:"threshold" yaml:"threshold"`
+	Speckle         speckleConfig     `json:"speckle" yaml:"speckle"`
+	Morphology      morphologyConfig  `json:"morphology" yaml:"morphology"`
+	Cluster         clusterConfig     `json:"cluster" yaml:"cluster"`
+	Ambiguity       ambiguityConfig   `json:"ambiguity" yaml:"ambiguity"`
+	Incidence       incidenceConfig   `json:"incidence" yaml:"incidence"`
+	Performance     performanceConfig `json:"performance" yaml:"performance"`
+	ContinueOnError bool              `json:"continue_on_error" yaml:"continue_on_error"`
+}
+
+type outputConfig struct {
+	Path          string     `json:"path" yaml:"path"`
+	Formats       stringList `json:"formats" yaml:"formats"`
+	MaxCandidates int        `json:"max_candidates" yaml:"max_candidates"`
+	// Manifest writes the run.json provenance record next to the output,
+	// as <out>.run.json.
+	Manifest bool `json:"manifest" yaml:"manifest"`
+	// ManifestHash records a SHA-256 of every input in the manifest, which
+	// reads each input in full.
+	ManifestHash bool `json:"manifest_hash" yaml:"manifest_hash"`
+	// Footprint names the traced footprint: none, outline, rectangle or hull.
+	Footprint string `json:"footprint" yaml:"footprint"`
+	// Geometry is the feature geometry: point, footprint or both.
+	Geometry string `json:"geometry" yaml:"geometry"`
+	// GeoJSONLayout frames the GeoJSON output: collection, seq or ndjson.
+	GeoJSONLayout string `json:"geojson_layout" yaml:"geojson_layout"`
+	// Precision rounds output coordinates to this many decimals; 0 keeps all.
+	Precision  int             `json:"precision" yaml:"precision"`
+	Chips      chipsConfig     `json:"chips" yaml:"chips"`
+	Quicklooks quicklookConfig `json:"quicklooks" yaml:"quicklooks"`
+	// Report is the path of a self-contained HTML report; empty disables it.
+	Report string `json:"report" yaml:"report"`
+	// KMLScoreBreaks are the two scores separating the low, medium and high
+	// KML placemark styles.
+	KMLScoreBreaks floatList `json:"kml_score_breaks" yaml:"kml_score_breaks"`
+}
+
+// quicklookConfig controls the per-scene overview images.
+type quicklookConfig struct {
+	// Dir receives one PNG per scene; empty disables them.
+	Dir string `json:"dir" yaml:"dir"`
+	// Size bounds the longer side in pixels.
+	Size int `json:"size" yaml:"size"`
+}
+
+// chipsConfig controls the image chips cut around each candidate.
+type chipsConfig struct {
+	// Dir receives the chips; empty disables them.
+	Dir  string `json:"dir" yaml:"dir"`
+	Size int    `json:"size" yaml:"size"`
+	// Format is the data chip format: geotiff or npy.
+	Format string `json:"format" yaml:"format"`
+}
+
+type preprocessConfig struct {
+	KeepTemp bool         `json:"keep_temp" yaml:"keep_temp"`
+	Cache    cacheOptions `json:"cache" yaml:"cache"`
+}
+
+type thresholdConfig struct {
+	// Percentile selects a percentile threshold; 0 uses mean ± K·std.
+	Percentile float64 `json:"percentile" yaml:"percentile"`
+	K          float64 `json:"k" yaml:"k"`
+	Invert     bool    `json:"invert" yaml:"invert"`
+	MinAreaPx  int     `json:"min_area_px" yaml:"min_area_px"`
+}
+
+type speckleConfig struct {
+	Filter string `json:"filter" yaml:"filter"`
+	Window int    `json:"window" yaml:"window"`
+}
+
+type morphologyConfig struct {
+	Ops       stringList `json:"ops" yaml:"ops"`
+	Element   string     `json:"element" yaml:"element"`
+	Radius    int        `json:"radius" yaml:"radius"`
+	FillHoles bool       `json:"fill_holes" yaml:"fill_holes"`
+}
+
+type clusterConfig struct {
+	MergeDistanceM float64 `json:"merge_distance_m" yaml:"merge_distance_m"`
+	NMSRadiusM     float64 `json:"nms_radius_m" yaml:"nms_radius_m"`
+}
+
+type ambiguityConfig struct {
+	Action          string        `json:"action" yaml:"action"`
+	HeadingDeg      optionalFloat `json:"heading_deg" yaml:"heading_deg"`
+	PRFHz           float64       `json:"prf_hz" yaml:"prf_hz"`
+	SlantRangeM     float64       `json:"slant_range_m" yaml:"slant_range_m"`
+	ToleranceM      float64       `json:"tolerance_m" yaml:"tolerance_m"`
+	SidelobeRadiusM float64       `json:"sidelobe_radius_m" yaml:"sidelobe_radius_m"`
+}
+
+type incidenceConfig struct {
+	Normalize bool    `json:"normalize" yaml:"normalize"`
+	Raster    string  `json:"raster" yaml:"raster"`
+	BinDeg    float64 `json:"bin_deg" yaml:"bin_deg"`
+}
+
+type performanceConfig struct {
+	Jobs         int `json:"jobs" yaml:"jobs"`
+	TileSize     int `json:"tile_size" yaml:"tile_size"`
+	TileWorkers  int `json:"tile_workers" yaml:"tile_workers"`
+	LabelWorkers int `json:"label_workers" yaml:"label_workers"`
+}
+
+func defaultRunConfig() runConfig {
+	return runConfig{
+		Output: outputConfig{
+			Formats:       stringList{"geojson"},
+			MaxCandidates: defaultMaxCandidates,
+			Manifest:      true,
+			Footprint:     "none",
+			Geometry:      "both",
+			GeoJSONLayout: "collection",
+			Chips:         chipsConfig{Size: 64, Format: "geotiff"},
+			Quicklooks:    quicklookConfig{Size: 1024},
+			// Copied so flags and config files cannot change the default.
+			KMLScoreBreaks: append(floatList(nil), output.DefaultScoreBreaks...),
+		},
+		Preprocess: preprocessConfig{
+			Cache: defaultCacheOptions(),
+		},
+		Threshold: thresholdConfig{
+			Percentile: defaultPercentile,
+			K:          defaultK,
+			Invert:     defaultInvert,
+			MinAreaPx:  defaultMinAreaPx,
+		},
+		Speckle:    speckleConfig{Filter: "none"},
+		Morphology: morphologyConfig{Ops: stringList{}, Element: "square", Radius: 1},
+		Ambiguity: ambiguityConfig{
+			Action:     "none",
+			HeadingDeg: optionalFloat(math.NaN()),
+			ToleranceM: 200,
+		},
+		Incidence:   incidenceConfig{BinDeg: 0.5},
+		Performance: performanceConfig{Jobs: 1},
+	}
+}
+
+// addRunFlags binds the detect flags to cfg.
+func addRunFlags(fs *flag.FlagSet, cfg *runConfig, configPath *string) {
+	fs.StringVar(configPath, "config", "", "YAML or JSON run configuration; flags override its values")
+	fs.StringVar(&cfg.Input, "input", cfg.Input, "Input folder containing .tif or .SAFE")
+	fs.StringVar(&cfg.Output.Path, "out", cfg.Output.Path, "Output GeoJSON path")
+	fs.Var(&cfg.Output.Formats, "format", "Comma-separated output formats: geojson, csv, parquet, kml, kmz, shapefile, gpkg")
+	fs.IntVar(&cfg.Output.MaxCandidates, "max-candidates", cfg.Output.MaxCandidates, "Keep at most this many candidates across all scenes, strongest first (0 keeps all)")
+	fs.StringVar(&cfg.Output.Footprint, "footprint", cfg.Output.Footprint, "Footprint traced per candidate: none, outline, rectangle, hull")
+	fs.StringVar(&cfg.Output.Geometry, "geometry", cfg.Output.Geometry, "Feature geometry: point, footprint or both (footprints need --footprint)")
+	fs.StringVar(&cfg.Output.GeoJSONLayout, "geojson-layout", cfg.Output.GeoJSONLayout, "GeoJSON framing: collection, seq (RFC 8142 text sequence) or ndjson")
+	fs.IntVar(&cfg.Output.Precision, "precision", cfg.Output.Precision, "Round output coordinates to this many decimal places (0 keeps full precision)")
+	fs.StringVar(&cfg.Output.Chips.Dir, "chips", cfg.Output.Chips.Dir, "Write a PNG quicklook and data chip around each candidate into this directory")
+	fs.IntVar(&cfg.Output.Chips.Size, "chip-size", cfg.Output.Chips.Size, "Chip width and height in pixels")
+	fs.StringVar(&cfg.Output.Chips.Format, "chip-format", cfg.Output.Chips.Format, "Data chip format: geotiff or npy")
+	fs.StringVar(&cfg.Output.Quicklooks.Dir, "quicklooks", cfg.Output.Quicklooks.Dir, "Write a PNG overview of each scene with its candidates into this directory")
+	fs.IntVar(&cfg.Output.Quicklooks.Size, "quicklook-size", cfg.Output.Quicklooks.Size, "Longest quicklook side in pixels")
+	fs.StringVar(&cfg.Output.Report, "report", cfg.Output.Report, "Write a self-contained HTML report with summary, histograms, candidates and images to this path")
+	fs.Var(&cfg.Output.KMLScoreBreaks, "kml-score-breaks", "Two comma-separated scores separating the low, medium and high KML placemark styles")
+	fs.BoolVar(&cfg.Output.Manifest, "manifest", cfg.Output.Manifest, "Write a <out>.run.json provenance manifest next to the output")
+	fs.BoolVar(&cfg.Output.ManifestHash, "manifest-hash", cfg.Output.ManifestHash, "Record a SHA-256 of every input in the manifest (reads each input in full)")
+	fs.Float64Var(&cfg.Threshold.Percentile, "percentile", cfg.Threshold.Percentile, "Percentile threshold (0 uses mean ± k·std)")
+	fs.Float64Var(&cfg.Threshold.K, "k", cfg.Threshold.K, "Standard deviations from the mean for the k·std threshold")
+	fs.BoolVar(&cfg.Threshold.Invert, "invert", cfg.Threshold.Invert, "Detect pixels below the threshold instead of above")
+	fs.IntVar(&cfg.Threshold.MinAreaPx, "min-area", cfg.Threshold.MinAreaPx, "Minimum component area in pixels")
+	fs.StringVar(&cfg.Speckle.Filter, "speckle", cfg.Speckle.Filter, "Speckle filter before thresholding: none, boxcar, median, lee, refined-lee, frost")
+	fs.IntVar(&cfg.Speckle.Window, "speckle-window", cfg.Speckle.Window, "Speckle filter window size in pixels (odd, 0 for the filter default)")
+	fs.Var(&cfg.Morphology.Ops, "morph", "Comma-separated mask morphology before labeling: erode, dilate, open, close")
+	fs.StringVar(&cfg.Morphology.Element, "morph-element", cfg.Morphology.Element, "Morphology structuring element: square, cross, disk")
+	fs.IntVar(&cfg.Morphology.Radius, "morph-radius", cfg.Morphology.Radius, "Morphology structuring element radius in pixels")
+	fs.BoolVar(&cfg.Morphology.FillHoles, "fill-holes", cfg.Morphology.FillHoles, "Fill enclosed holes in the detection mask before labeling")
+	fs.Float64Var(&cfg.Cluster.MergeDistanceM, "merge-distance", cfg.Cluster.MergeDistanceM, "Merge components whose footprints are within this many metres (0 disables)")
+	fs.Float64Var(&cfg.Cluster.NMSRadiusM, "nms-radius", cfg.Cluster.NMSRadiusM, "Keep only the strongest candidate within this radius in metres (0 disables)")
+	fs.StringVar(&cfg.Ambiguity.Action, "ambiguity", cfg.Ambiguity.Action, "Azimuth ambiguity and sidelobe filter: none, mark, remove")
+	fs.Var(&cfg.Ambiguity.HeadingDeg, "heading", "Platform heading in degrees from north (default from SAFE annotation)")
+	fs.Float64Var(&cfg.Ambiguity.PRFHz, "prf", cfg.Ambiguity.PRFHz, "Pulse repetition frequency in Hz (default from SAFE annotation)")
+	fs.Float64Var(&cfg.Ambiguity.SlantRangeM, "slant-range", cfg.Ambiguity.SlantRangeM, "Slant range in metres (default from SAFE annotation)")
+	fs.Float64Var(&cfg.Ambiguity.ToleranceM, "ambiguity-tolerance", cfg.Ambiguity.ToleranceM, "Distance in metres between a candidate and a predicted ghost")
+	fs.Float64Var(&cfg.Ambiguity.SidelobeRadiusM, "sidelobe-radius", cfg.Ambiguity.SidelobeRadiusM, "Flag weaker candidates on the azimuth/range axes of a stronger one within this radius in metres")
+	fs.BoolVar(&cfg.Incidence.Normalize, "incidence-normalize", cfg.Incidence.Normalize, "Normalize sea clutter across incidence angle before thresholding")
+	fs.StringVar(&cfg.Incidence.Raster, "incidence-raster", cfg.Incidence.Raster, "Incidence angle raster in degrees (default from SAFE annotation)")
+	fs.Float64Var(&cfg.Incidence.BinDeg, "incidence-bin", cfg.Incidence.BinDeg, "Incidence angle bin width in degrees for clutter estimation")
+	fs.IntVar(&cfg.Performance.TileSize, "tile-size", cfg.Performance.TileSize, "Stream each scene in tiles of this many pixels per side, reading one tile and its halo at a time (0 reads whole scenes)")
+	fs.IntVar(&cfg.Performance.TileWorkers, "tile-workers", cfg.Performance.TileWorkers, "Number of tiles processed concurrently (0 uses one per CPU)")
+	fs.IntVar(&cfg.Performance.Jobs, "jobs", cfg.Performance.Jobs, "Number of scenes preprocessed and detected concurrently (0 uses one per CPU)")
+	fs.IntVar(&cfg.Performance.LabelWorkers, "label-workers", cfg.Performance.LabelWorkers, "Number of row bands labeled concurrently for whole scenes (0 uses one per CPU)")
+	fs.BoolVar(&cfg.Preprocess.KeepTemp, "keep-temp", cfg.Preprocess.KeepTemp, "Keep intermediate rasters in the run workspace under "+gdal.DefaultWorkspaceRoot+" for debugging")
+	fs.BoolVar(&cfg.ContinueOnError, "continue-on-error", cfg.ContinueOnError, "Record failed scenes in the summary and keep processing the rest (exit code 3 if any failed)")
+	addCacheFlags(fs, &cfg.Preprocess.Cache)
+}
+
+// parseRunConfig merges defaults, the --config file and flags, in that order
+// of increasing precedence.
+func parseRunConfig(fs *flag.FlagSet, args []string) (runConfig, error) {
+	cfg := defaultRunConfig()
+	var configPath string
+	addRunFlags(fs, &cfg, &configPath)
+	if err := parseArgs(fs, args); err != nil {
+		return runConfig{}, err
+	}
+	if configPath == "" {
+		return cfg, nil
+	}
+
+	// Flags write into cfg, so remember them, load the file over the
+	// defaults and apply them again.
+	set := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+
+	cfg = defaultRunConfig()
+	if err := loadRunConfig(configPath, &cfg); err != nil {
+		return runConfig{}, err
+	}
+	for name, value := range set {
+		if err := fs.Set(name, value); err != nil {
+			return runConfig{}, usagef("flag -%s: %v", name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// loadRunConfig decodes a YAML or JSON file over cfg, rejecting unknown keys.
+func loadRunConfig(path string, cfg *runConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return usagef("parse config %s: %v", path, err)
+		}
+		if dec.More() {
+			return usagef("parse config %s: unexpected content after the configuration object", path)
+		}
+		return nil
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return usagef("parse config %s: %v", path, err)
+	}
+	var extra yaml.Node
+	if err := dec.Decode(&extra); err != io.EOF {
+		return usagef("parse config %s: expected a single YAML document", path)
+	}
+	return nil
+}
+
+func runConfigCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return usagef("usage: boatdetect config print [--config <file>] [--output-format yaml|json] [detect flags]")
+	}
+
+	fs := newFlagSet("config")
+	format := fs.String("output-format", "yaml", "Format of the printed configuration: yaml or json")
+	cfg, err := parseRunConfig(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	return printRunConfig(os.Stdout, cfg, *format)
+}
+
+func printRunConfig(out io.Writer, cfg runConfig, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cfg)
+	case "yaml":
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		if err := enc.Encode(cfg); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return usagef("unknown output format %q", format)
+	}
+}
+
+// stringList is a comma-separated flag that is a list in config files.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = nil
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*l = append(*l, part)
+		}
+	}
+	return nil
+}
+
+// floatList is a comma-separated flag of numbers that is a list in config
+// files.
+type floatList []float64
+
+func (l *floatList) String() string {
+	parts := make([]string, len(*l))
+	for i, v := range *l {
+		parts[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *floatList) Set(s string) error {
+	var values floatList
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return err
+		}
+		values = append(values, v)
+	}
+	*l = values
+	return nil
+}
+
+// optionalFloat is a float flag where NaN means unset; config files write
+// it as null.
+type optionalFloat float64
+
+func (f *optionalFloat) String() string {
+	if math.IsNaN(float64(*f)) {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*f), 'g', -1, 64)
+}
+
+func (f *optionalFloat) Set(s string) error {
+	if s == "" {
+		*f = optionalFloat(math.NaN())
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*f = optionalFloat(v)
+	return nil
+}
+
+func (f optionalFloat) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(f))
+}
+
+func (f *optionalFloat) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*f = optionalFloat(math.NaN())
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = optionalFloat(v)
+	return nil
+}
+
+func (f optionalFloat) MarshalYAML() (any, error) {
+	if math.IsNaN(float64(f)) {
+		return nil, nil
+	}
+	return float64(f), nil
+}
+
+func (f *optionalFloat) UnmarshalYAML(node *yaml.Node) error {
+	if node.Tag == "!!null" {
+		*f = optionalFloat(math.NaN())
+		return nil
+	}
+	var v float64
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	*f = optionalFloat(v)
+	return nil
+}
+
+// validateOutput checks the output settings that detection does not parse.
+func validateOutput(cfg outputConfig) error {
+	if err := validateFormats(cfg.Formats); err != nil {
+		return err
+	}
+	if cfg.Precision < 0 {
+		return usagef("precision must not be negative, got %d", cfg.Precision)
+	}
+	if err := validateScoreBreaks(cfg.KMLScoreBreaks); err != nil {
+		return err
+	}
+	report := cfg.Report != ""
+	if err := validateChips(cfg.Chips, report); err != nil {
+		return err
+	}
+	return validateQuicklooks(cfg.Quicklooks, report)
+}
+
+// validateScoreBreaks checks the KML score breaks are two ascending numbers.
+func validateScoreBreaks(breaks []float64) error {
+	if len(breaks) != 2 {
+		return usagef("kml-score-breaks needs 2 values, got %d", len(breaks))
+	}
+	if breaks[0] >= breaks[1] {
+		return usagef("kml-score-breaks must ascend, got %v then %v", breaks[0], breaks[1])
+	}
+	return nil
+}
+
+// validateFormats checks the requested output formats.
+func validateFormats(formats []string) error {
+	if len(formats) == 0 {
+		return usagef("at least one output format is required")
+	}
+	seen := make(map[string]bool, len(formats))
+	for _, name := range formats {
+		format, err := output.Lookup(name)
+		if err != nil {
+			return usagef("%v", err)
+		}
+		if seen[format.Name] {
+			return usagef("output format %q given twice", format.Name)
+		}
+		seen[format.Name] = true
+	}
+	return nil
+}
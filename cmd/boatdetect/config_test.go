@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestParseRunConfigFlagsOverrideFile(t *testing.T) {
+	path := writeConfig(t, "run.yaml", "input: ./data\nthreshold:\n  k: 4\n  min_area_px: 9\n")
+
+	cfg, err := parseRunConfig(newFlagSet("detect"), []string{"--k", "2.5", "--config", path})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Threshold.K != 2.5 {
+		t.Fatalf("expected flag k 2.5, got %v", cfg.Threshold.K)
+	}
+	if cfg.Input != "./data" || cfg.Threshold.MinAreaPx != 9 {
+		t.Fatalf("expected file values kept, got input %q min area %d", cfg.Input, cfg.Threshold.MinAreaPx)
+	}
+	if cfg.Threshold.Percentile != defaultPercentile {
+		t.Fatalf("expected default percentile, got %v", cfg.Threshold.Percentile)
+	}
+}
+
+func TestLoadRunConfigRejectsUnknownKeys(t *testing.T) {
+	for name, content := range map[string]string{
+		"run.yaml": "threshold:\n  kk: 4\n",
+		"run.json": `{"threshold": {"kk": 4}}`,
+	} {
+		cfg := defaultRunConfig()
+		err := loadRunConfig(writeConfig(t, name, content), &cfg)
+		var usage *usageError
+		if !errors.As(err, &usage) {
+			t.Fatalf("%s: expected usage error, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadRunConfigRejectsTrailingContent(t *testing.T) {
+	for name, content := range map[string]string{
+		"run.json": `{"input": "a"} {"input": "b"}`,
+		"run.yaml": "input: a\n---\ninput: b\n",
+	} {
+		cfg := defaultRunConfig()
+		err := loadRunConfig(writeConfig(t, name, content), &cfg)
+		var usage *usageError
+		if !errors.As(err, &usage) {
+			t.Fatalf("%s: expected usage error, got %v", name, err)
+		}
+	}
+}
+
+func TestPrintRunConfigRoundTrips(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.Input = "./data"
+	cfg.Output.Formats = stringList{"geojson", "csv"}
+	cfg.Threshold.K = 2.5
+	cfg.Ambiguity.HeadingDeg = 12
+	cfg.Morphology.Ops = stringList{"open"}
+	cfg.Output.KMLScoreBreaks = floatList{40, 120.5}
+
+	for _, format := range []string{"yaml", "json"} {
+		var printed bytes.Buffer
+		if err := printRunConfig(&printed, cfg, format); err != nil {
+			t.Fatalf("%s: expected no error, got %v", format, err)
+		}
+
+		loaded := defaultRunConfig()
+		if err := loadRunConfig(writeConfig(t, "run."+format, printed.String()), &loaded); err != nil {
+			t.Fatalf("%s: expected no error, got %v", format, err)
+		}
+		var reprinted bytes.Buffer
+		if err := printRunConfig(&reprinted, loaded, format); err != nil {
+			t.Fatalf("%s: expected no error, got %v", format, err)
+		}
+		if reprinted.String() != printed.String() {
+			t.Fatalf("%s: expected round trip\n%s\ngot\n%s", format, printed.String(), reprinted.String())
+		}
+	}
+}
+
+func TestValidateOutputRejectsNegativePrecision(t *testing.T) {
+	cfg := defaultRunConfig().Output
+	if err := validateOutput(cfg); err != nil {
+		t.Fatalf("expected defaults valid, got %v", err)
+	}
+	cfg.Precision = -1
+	var usage *usageError
+	if err := validateOutput(cfg); !errors.As(err, &usage) {
+		t.Fatalf("expected usage error, got %v", err)
+	}
+}
+
+func TestValidateOutputChecksImageSizesForReport(t *testing.T) {
+	cfg := defaultRunConfig().Output
+	cfg.Chips.Size = 0
+	cfg.Quicklooks.Size = 0
+	if err := validateOutput(cfg); err != nil {
+		t.Fatalf("expected unused sizes ignored, got %v", err)
+	}
+
+	cfg.Report = "r.html"
+	var usage *usageError
+	if err := validateOutput(cfg); !errors.As(err, &usage) {
+		t.Fatalf("expected usage error for chip size, got %v", err)
+	}
+	cfg.Chips.Size = 64
+	if err := validateOutput(cfg); !errors.As(err, &usage) {
+		t.Fatalf("expected usage error for quicklook size, got %v", err)
+	}
+	cfg.Quicklooks.Size = 256
+	if err := validateOutput(cfg); err != nil {
+		t.Fatalf("expected valid report settings, got %v", err)
+	}
+}
+
+func TestValidateOutputChecksScoreBreaks(t *testing.T) {
+	cfg := defaultRunConfig().Output
+	var breaks floatList
+	if err := breaks.Set("120, 60"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	cfg.KMLScoreBreaks = breaks
+	var usage *usageError
+	if err := validateOutput(cfg); !errors.As(err, &usage) {
+		t.Fatalf("expected usage error for descending breaks, got %v", err)
+	}
+	cfg.KMLScoreBreaks = floatList{60}
+	if err := validateOutput(cfg); !errors.As(err, &usage) {
+		t.Fatalf("expected usage error for one break, got %v", err)
+	}
+	if err := breaks.Set("x"); err == nil {
+		t.Fatalf("expected error for a non-number")
+	}
+}
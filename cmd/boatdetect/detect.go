@@ -2,19 +2,23 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"io"
 	"math"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"boatdetect/internal/detect"
 	"boatdetect/internal/gdal"
 	"boatdetect/internal/geojson"
+	"boatdetect/internal/output"
+	"boatdetect/internal/parallel"
+	"boatdetect/internal/sentinel1"
 )
 
 const (
@@ -25,107 +29,400 @@ const (
 	defaultMaxCandidates = 200
 )
 
-type detectOptions struct {
-	input string
-	out   string
-}
-
 type candidateRecord struct {
 	sceneID   string
+	source    string
+	threshold float64
 	candidate detect.Candidate
+	chip      string
+}
+
+func runDetectCommand(ctx context.Context, args []string) error {
+	opts, err := parseRunConfig(newFlagSet("detect"), args)
+	if err != nil {
+		return err
+	}
+	if opts.Input == "" {
+		return usagef("input is required")
+	}
+	if opts.Output.Path == "" {
+		return usagef("out is required")
+	}
+	if err := validateOutput(opts.Output); err != nil {
+		return err
+	}
+	return withGDAL(ctx, func() error {
+		return runDetect(ctx, os.Stdout, opts)
+	})
 }
 
-func parseFlags() (detectOptions, error) {
-	var opts detectOptions
-	flag.StringVar(&opts.input, "input", "", "Input folder containing .tif or .SAFE")
-	flag.StringVar(&opts.out, "out", "", "Output GeoJSON path")
+func detectConfig(opts runConfig) (detect.Config, error) {
+	filter, err := detect.ParseSpeckleFilter(opts.Speckle.Filter)
+	if err != nil {
+		return detect.Config{}, err
+	}
 
-	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage: boatdetect --input <dir> --out <file>\n\n")
-		flag.PrintDefaults()
+	ops, err := detect.ParseMorphOps(opts.Morphology.Ops.String())
+	if err != nil {
+		return detect.Config{}, err
 	}
 
-	flag.Parse()
+	shape, err := detect.ParseElementShape(opts.Morphology.Element)
+	if err != nil {
+		return detect.Config{}, err
+	}
 
-	if opts.input == "" {
-		return detectOptions{}, fmt.Errorf("input is required")
+	if opts.Cluster.MergeDistanceM < 0 || opts.Cluster.NMSRadiusM < 0 {
+		return detect.Config{}, fmt.Errorf("merge-distance and nms-radius must not be negative")
 	}
-	if opts.out == "" {
-		return detectOptions{}, fmt.Errorf("out is required")
+
+	action, err := detect.ParseAmbiguityAction(opts.Ambiguity.Action)
+	if err != nil {
+		return detect.Config{}, err
 	}
-	return opts, nil
+
+	footprint, err := detect.ParseFootprintKind(opts.Output.Footprint)
+	if err != nil {
+		return detect.Config{}, err
+	}
+
+	return detect.Config{
+		K:          opts.Threshold.K,
+		Percentile: opts.Threshold.Percentile,
+		Invert:     opts.Threshold.Invert,
+		MinAreaPx:  opts.Threshold.MinAreaPx,
+		Speckle: detect.SpeckleConfig{
+			Filter: filter,
+			Window: opts.Speckle.Window,
+		},
+		Morphology: detect.MorphologyConfig{
+			Ops: ops,
+			Element: detect.StructuringElement{
+				Shape:  shape,
+				Radius: opts.Morphology.Radius,
+			},
+			FillHoles: opts.Morphology.FillHoles,
+		},
+		Cluster: detect.ClusterConfig{
+			MergeDistanceM: opts.Cluster.MergeDistanceM,
+			NMSRadiusM:     opts.Cluster.NMSRadiusM,
+		},
+		Ambiguity: detect.AmbiguityConfig{
+			Action: action,
+			Geometry: detect.SARGeometry{
+				HeadingDeg:  float64(opts.Ambiguity.HeadingDeg),
+				PRFHz:       opts.Ambiguity.PRFHz,
+				SlantRangeM: opts.Ambiguity.SlantRangeM,
+			},
+			ToleranceM:      opts.Ambiguity.ToleranceM,
+			SidelobeRadiusM: opts.Ambiguity.SidelobeRadiusM,
+		},
+		Incidence: detect.IncidenceConfig{
+			Enabled:    opts.Incidence.Normalize || opts.Incidence.Raster != "",
+			RasterPath: opts.Incidence.Raster,
+			BinDeg:     opts.Incidence.BinDeg,
+		},
+		Tiles: detect.TileConfig{
+			Size:    opts.Performance.TileSize,
+			Workers: opts.Performance.TileWorkers,
+		},
+		LabelWorkers: opts.Performance.LabelWorkers,
+		Footprint:    footprint,
+	}, nil
 }
 
-func runDetect(ctx context.Context, out io.Writer, opts detectOptions) error {
-	inputFiles, err := findTifFiles(opts.input)
+func runDetect(ctx context.Context, out io.Writer, opts runConfig) error {
+	started := time.Now()
+	// Scene, tile and label pools nest; one budget keeps them from
+	// multiplying.
+	ctx = parallel.WithLimiter(ctx, parallel.NewLimiter(max(opts.Performance.Jobs, runtime.NumCPU())))
+	cfg, err := detectConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	geometry, err := geojson.ParseGeometryMode(opts.Output.Geometry)
+	if err != nil {
+		return err
+	}
+	layout, err := geojson.ParseLayout(opts.Output.GeoJSONLayout)
+	if err != nil {
+		return err
+	}
+	writerOpts := output.Options{
+		GeoJSON:     geojson.EncoderOptions{Layout: layout, Precision: opts.Output.Precision},
+		Geometry:    geometry,
+		ScoreBreaks: opts.Output.KMLScoreBreaks,
+	}
+
+	inputFiles, err := findTifFiles(opts.Input)
 	if err != nil {
 		return err
 	}
 	if len(inputFiles) == 0 {
-		return fmt.Errorf("no .tif files found in %s", opts.input)
+		return fmt.Errorf("no .tif files found in %s", opts.Input)
+	}
+
+	if err := ensureOutputDir(opts.Output.Path); err != nil {
+		return err
+	}
+
+	failures := newSceneFailures(opts.ContinueOnError)
+	// When every extent failed under --continue-on-error, every input is
+	// already a failed scene and the run goes on to report them.
+	bbox, haveBBox, err := bboxFromFiles(ctx, inputFiles, failures)
+	if err != nil {
+		return err
 	}
 
-	if err := ensureOutputDir(opts.out); err != nil {
+	ws, err := gdal.NewWorkspace(gdal.DefaultWorkspaceRoot, opts.Preprocess.KeepTemp)
+	if err != nil {
 		return err
 	}
+	defer ws.Close()
+	writerOpts.Workspace = ws
+	if opts.Preprocess.KeepTemp {
+		fmt.Fprintf(os.Stderr, "keeping temp files in %s\n", ws.Dir())
+	}
 
-	minLon, minLat, maxLon, maxLat, err := bboxFromFiles(ctx, inputFiles)
+	store, pc, err := openPreprocessCache(ctx, opts.Preprocess.Cache)
 	if err != nil {
 		return err
 	}
+	defer pc.Release()
 
-	preprocessDir := filepath.Join(filepath.Dir(opts.out), ".tmp", "preprocess")
-	bbox := [4]float64{minLon, minLat, maxLon, maxLat}
+	// Without a candidate limit nothing is ranked across scenes, so each
+	// scene is written as soon as it finishes.
+	var stream *sceneStream
+	var done func(context.Context, int, sceneRun) error
+	if opts.Output.MaxCandidates <= 0 {
+		writers, err := openOutputs(ctx, opts.Output.Path, opts.Output.Formats, writerOpts)
+		if err != nil {
+			return err
+		}
+		defer writers.abort()
+		stream = newSceneStream(ws, inputFiles, opts.Output, opts.Output.Report != "", failures, writers)
+		done = stream.done
+	}
 
-	records, sceneOrder, err := processCandidates(ctx, inputFiles, preprocessDir, bbox)
+	runs, err := processInputs(ctx, ws, pc, inputFiles, bbox, cfg, opts.Performance.Jobs, failures, done)
 	if err != nil {
 		return err
 	}
 
-	records = limitCandidates(records, defaultMaxCandidates)
+	// Inputs that succeeded in a failed scene are dropped with it; the
+	// manifest still describes every input.
+	written := failures.withoutFailedScenes(runs)
+	records, sceneOrder := collectCandidates(written)
+	var images sceneImages
+	if stream != nil {
+		records, images, err = stream.close()
+		if err != nil {
+			return err
+		}
+	} else {
+		records = sortByScene(sceneOrder, limitCandidates(records, opts.Output.MaxCandidates))
+	}
 	byScene := groupCandidates(sceneOrder, records)
 
-	if err := writeSummaryTable(out, sceneOrder, byScene); err != nil {
+	if err := writeSummaryTable(out, sceneOrder, byScene, failures); err != nil {
 		return err
 	}
 
-	if err := writeGeojson(opts.out, sceneOrder, byScene); err != nil {
+	if stream == nil {
+		images, err = writeSceneImages(ctx, ws, written, records, opts.Output, opts.Output.Report != "")
+		if err != nil {
+			return err
+		}
+		if err := writeOutputs(ctx, opts.Output.Path, opts.Output.Formats, records, writerOpts); err != nil {
+			return err
+		}
+	}
+
+	if opts.Output.Report != "" {
+		r := newReport(started, sceneOrder, byScene, failures, written, records, images)
+		if err := writeReport(opts.Output.Report, r); err != nil {
+			return err
+		}
+	}
+
+	if opts.Output.Manifest {
+		var manifestBBox *[4]float64
+		if haveBBox {
+			manifestBBox = &bbox
+		}
+		m, err := newRunManifest(ctx, opts, started, manifestBBox, runs, failures)
+		if err != nil {
+			return err
+		}
+		if err := writeRunManifest(manifestPath(opts.Output.Path), m); err != nil {
+			return err
+		}
+	}
+
+	if err := pruneCache(store, pc, opts.Preprocess.Cache.MaxSize); err != nil {
 		return err
 	}
 
-	return cleanupTemp(opts.out)
+	if err := ws.Close(); err != nil {
+		return err
+	}
+	return failures.result(sceneOrder)
 }
 
-func processCandidates(ctx context.Context, inputFiles []string, preprocessDir string, bbox [4]float64) ([]candidateRecord, []string, error) {
+// sceneRun is the outcome of detection on one input raster.
+type sceneRun struct {
+	path    string
+	sceneID string
+	// raster is the preprocessed raster detection ran on.
+	raster     string
+	result     detect.Result
+	preprocess time.Duration
+	detect     time.Duration
+}
+
+// processInputs detects every input not already failed. When done is set it
+// is called with each input's run once the input finishes or fails.
+func processInputs(ctx context.Context, ws *gdal.Workspace, pc *gdal.PreprocessCache, inputFiles []string, bbox [4]float64, cfg detect.Config, jobs int, failures *sceneFailures, done func(ctx context.Context, i int, run sceneRun) error) ([]sceneRun, error) {
+	runs := make([]sceneRun, len(inputFiles))
+	err := parallel.ForEach(ctx, len(inputFiles), jobs, func(ctx context.Context, i int) error {
+		runs[i].path = inputFiles[i]
+		runs[i].sceneID = sceneIDFromPath(inputFiles[i])
+		if !failures.failed(inputFiles[i]) {
+			if err := detectInput(ctx, ws, pc, &runs[i], bbox, cfg); err != nil {
+				// Cancellation of the whole run is not a scene failure.
+				if ctx.Err() != nil {
+					return err
+				}
+				if err := failures.record(inputFiles[i], err); err != nil {
+					return err
+				}
+			}
+		}
+		if done == nil {
+			return nil
+		}
+		return done(ctx, i, runs[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// collectCandidates flattens runs in input order so output does not depend
+// on scheduling.
+func collectCandidates(runs []sceneRun) ([]candidateRecord, []string) {
 	records := make([]candidateRecord, 0)
 	seenScenes := make(map[string]struct{})
 	sceneOrder := make([]string, 0)
+	for _, run := range runs {
+		sceneOrder = appendSceneIfMissing(sceneOrder, seenScenes, run.sceneID)
+		records = appendCandidateRecords(records, run)
+	}
+	return records, sceneOrder
+}
+
+// detectInput preprocesses and detects run.path, filling in the result and
+// stage timings.
+func detectInput(ctx context.Context, ws *gdal.Workspace, pc *gdal.PreprocessCache, run *sceneRun, bbox [4]float64, cfg detect.Config) error {
+	start := time.Now()
+	byteTif, err := gdal.Preprocess(ctx, ws, pc, run.path, bbox)
+	run.preprocess = time.Since(start)
+	if err != nil {
+		return fmt.Errorf("preprocess %s: %w", run.path, err)
+	}
+
+	run.raster = byteTif
+
+	cfg, err = sceneConfig(cfg, run.path)
+	if err != nil {
+		return err
+	}
+
+	start = time.Now()
+	result, err := detect.Detect(ctx, ws, byteTif, cfg)
+	run.detect = time.Since(start)
+	if err != nil {
+		return fmt.Errorf("detect %s: %w", run.path, err)
+	}
 
-	for _, inputPath := range inputFiles {
-		sceneID, candidates, err := detectCandidatesForInput(ctx, inputPath, preprocessDir, bbox)
+	run.result = result
+	return nil
+}
+
+// sceneConfig fills per-scene settings the flags left unset from the SAFE
+// annotation next to inputPath, when a stage that needs them is enabled.
+func sceneConfig(cfg detect.Config, inputPath string) (detect.Config, error) {
+	needGeometry := cfg.Ambiguity.Action != detect.AmbiguityOff
+	needIncidence := cfg.Incidence.Enabled && cfg.Incidence.RasterPath == ""
+	if !needGeometry && !needIncidence {
+		return cfg, nil
+	}
+
+	var ann *sentinel1.Annotation
+	if path, ok := sentinel1.AnnotationPath(inputPath); ok {
+		read, err := sentinel1.ReadAnnotationFile(path)
+		if err != nil {
+			return detect.Config{}, fmt.Errorf("read annotation for %s: %w", inputPath, err)
+		}
+		ann = &read
+	}
+
+	if needGeometry {
+		geometry, err := sceneGeometry(cfg.Ambiguity.Geometry, ann, inputPath)
 		if err != nil {
-			return nil, nil, err
+			return detect.Config{}, err
 		}
+		cfg.Ambiguity.Geometry = geometry
+	}
 
-		sceneOrder = appendSceneIfMissing(sceneOrder, seenScenes, sceneID)
-		records = appendCandidateRecords(records, sceneID, candidates)
+	if needIncidence {
+		if ann == nil || len(ann.Geolocation) == 0 {
+			return detect.Config{}, fmt.Errorf("incidence normalization for %s: no SAFE geolocation grid, pass --incidence-raster", inputPath)
+		}
+		cfg.Incidence.Points = incidencePoints(ann.Geolocation)
 	}
 
-	return records, sceneOrder, nil
+	return cfg, nil
 }
 
-func detectCandidatesForInput(ctx context.Context, inputPath string, preprocessDir string, bbox [4]float64) (string, []detect.Candidate, error) {
-	byteTif, err := gdal.Preprocess(ctx, inputPath, preprocessDir, bbox)
-	if err != nil {
-		return "", nil, fmt.Errorf("preprocess %s: %w", inputPath, err)
+func sceneGeometry(geometry detect.SARGeometry, ann *sentinel1.Annotation, inputPath string) (detect.SARGeometry, error) {
+	if ann != nil {
+		if math.IsNaN(geometry.HeadingDeg) {
+			geometry.HeadingDeg = ann.PlatformHeadingDeg
+		}
+		if geometry.PRFHz == 0 {
+			geometry.PRFHz = ann.PRFHz
+		}
+		if geometry.SlantRangeM == 0 {
+			geometry.SlantRangeM = ann.SlantRangeM
+		}
+		geometry.VelocityMps = ann.VelocityMps
+		geometry.WavelengthM = ann.WavelengthM()
 	}
 
-	candidates, err := detect.DetectCandidates(ctx, byteTif, defaultK, defaultPercentile, defaultInvert, defaultMinAreaPx)
-	if err != nil {
-		return "", nil, fmt.Errorf("detect %s: %w", inputPath, err)
+	if math.IsNaN(geometry.HeadingDeg) {
+		return detect.SARGeometry{}, fmt.Errorf("ambiguity filter for %s: heading unknown, pass --heading or use SAFE input", inputPath)
+	}
+	// Without them no ghost positions can be predicted, and the filter would
+	// silently check sidelobes only.
+	if geometry.PRFHz <= 0 || geometry.SlantRangeM <= 0 {
+		return detect.SARGeometry{}, fmt.Errorf("ambiguity filter for %s: PRF or slant range unknown, pass --prf and --slant-range or use SAFE input with them", inputPath)
 	}
+	return geometry, nil
+}
 
-	return sceneIDFromPath(inputPath), candidates, nil
+func incidencePoints(grid []sentinel1.GeolocationPoint) []detect.IncidencePoint {
+	points := make([]detect.IncidencePoint, 0, len(grid))
+	for _, pt := range grid {
+		points = append(points, detect.IncidencePoint{
+			Lon:      pt.Lon,
+			Lat:      pt.Lat,
+			AngleDeg: pt.IncidenceDeg,
+		})
+	}
+	return points
 }
 
 func appendSceneIfMissing(sceneOrder []string, seenScenes map[string]struct{}, sceneID string) []string {
@@ -137,10 +434,12 @@ func appendSceneIfMissing(sceneOrder []string, seenScenes map[string]struct{}, s
 	return append(sceneOrder, sceneID)
 }
 
-func appendCandidateRecords(records []candidateRecord, sceneID string, candidates []detect.Candidate) []candidateRecord {
-	for _, candidate := range candidates {
+func appendCandidateRecords(records []candidateRecord, run sceneRun) []candidateRecord {
+	for _, candidate := range run.result.Candidates {
 		records = append(records, candidateRecord{
-			sceneID:   sceneID,
+			sceneID:   run.sceneID,
+			source:    run.path,
+			threshold: run.result.Threshold,
 			candidate: candidate,
 		})
 	}
@@ -148,26 +447,93 @@ func appendCandidateRecords(records []candidateRecord, sceneID string, candidate
 	return records
 }
 
-func writeGeojson(outPath string, sceneOrder []string, byScene map[string][]detect.Candidate) error {
-	features := make([]geojson.Feature, 0)
-	for _, sceneID := range sceneOrder {
-		fc := geojson.BuildBoatsFC(sceneID, byScene[sceneID])
-		features = append(features, fc.Features...)
+// writeOutputs writes records to one file per format, each replaced
+// atomically.
+func writeOutputs(ctx context.Context, outPath string, formats []string, records []candidateRecord, opts output.Options) error {
+	w, err := openOutputs(ctx, outPath, formats, opts)
+	if err != nil {
+		return err
 	}
+	if err := w.write(records); err != nil {
+		w.abort()
+		return err
+	}
+	return w.close()
+}
+
+// outputWriters writes records to one file per format.
+type outputWriters struct {
+	formats []output.Format
+	writers []output.Writer
+}
 
-	fc := geojson.FeatureCollection{
-		Type:     "FeatureCollection",
-		Features: features,
+func openOutputs(ctx context.Context, outPath string, formats []string, opts output.Options) (*outputWriters, error) {
+	w := &outputWriters{}
+	for _, name := range formats {
+		format, err := output.Lookup(name)
+		if err != nil {
+			w.abort()
+			return nil, err
+		}
+		writer, err := format.Create(ctx, format.Path(outPath, len(formats)), opts)
+		if err != nil {
+			w.abort()
+			return nil, fmt.Errorf("write %s: %w", format.Name, err)
+		}
+		w.formats = append(w.formats, format)
+		w.writers = append(w.writers, writer)
 	}
-	if err := geojson.WriteFeatureCollection(outPath, fc); err != nil {
-		return fmt.Errorf("write geojson: %w", err)
+	return w, nil
+}
+
+func (w *outputWriters) write(records []candidateRecord) error {
+	for _, record := range records {
+		r := output.Record{
+			SceneID:   record.sceneID,
+			Source:    record.source,
+			Threshold: record.threshold,
+			Candidate: record.candidate,
+			Chip:      record.chip,
+		}
+		for i, writer := range w.writers {
+			if err := writer.Write(r); err != nil {
+				return fmt.Errorf("write %s: %w", w.formats[i].Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// close commits every file, discarding those not yet committed once one
+// fails.
+func (w *outputWriters) close() error {
+	for i, writer := range w.writers {
+		if err := writer.Close(); err != nil {
+			w.abort()
+			return fmt.Errorf("write %s: %w", w.formats[i].Name, err)
+		}
 	}
 	return nil
 }
 
-func cleanupTemp(outPath string) error {
-	tmpDir := filepath.Join(filepath.Dir(outPath), ".tmp")
-	return os.RemoveAll(tmpDir)
+// abort discards every file not yet committed.
+func (w *outputWriters) abort() {
+	for _, writer := range w.writers {
+		writer.Abort()
+	}
+}
+
+// sortByScene orders records by scene, keeping their order within a scene.
+func sortByScene(sceneOrder []string, records []candidateRecord) []candidateRecord {
+	rank := make(map[string]int, len(sceneOrder))
+	for i, sceneID := range sceneOrder {
+		rank[sceneID] = i
+	}
+	sorted := append([]candidateRecord(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return rank[sorted[i].sceneID] < rank[sorted[j].sceneID]
+	})
+	return sorted
 }
 
 func findTifFiles(inputDir string) ([]string, error) {
@@ -252,15 +618,15 @@ func groupCandidates(sceneOrder []string, records []candidateRecord) map[string]
 	return byScene
 }
 
-func writeSummaryTable(w io.Writer, sceneOrder []string, byScene map[string][]detect.Candidate) error {
+func writeSummaryTable(w io.Writer, sceneOrder []string, byScene map[string][]detect.Candidate, failures *sceneFailures) error {
 	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
-	if _, err := fmt.Fprintln(tw, "scene_id\tcandidates\tscore_mean\tscore_max\tarea_min\tarea_max"); err != nil {
+	if _, err := fmt.Fprintln(tw, "scene_id\tcandidates\tscore_mean\tscore_max\tarea_min\tarea_max\tstatus\terror"); err != nil {
 		return err
 	}
 
 	for _, sceneID := range sceneOrder {
 		candidates := byScene[sceneID]
-		if err := writeSummaryRow(tw, sceneID, candidates); err != nil {
+		if err := writeSummaryRow(tw, sceneID, candidates, failures.sceneErr(sceneID)); err != nil {
 			return err
 		}
 	}
@@ -268,15 +634,20 @@ func writeSummaryTable(w io.Writer, sceneOrder []string, byScene map[string][]de
 	return tw.Flush()
 }
 
-func writeSummaryRow(tw *tabwriter.Writer, sceneID string, candidates []detect.Candidate) error {
+func writeSummaryRow(tw *tabwriter.Writer, sceneID string, candidates []detect.Candidate, sceneErr error) error {
+	status, errText := "ok", ""
+	if sceneErr != nil {
+		status, errText = "failed", summaryError(sceneErr)
+	}
+
 	if len(candidates) == 0 {
-		_, err := fmt.Fprintf(tw, "%s\t0\t0.00\t0.00\t0\t0\n", sceneID)
+		_, err := fmt.Fprintf(tw, "%s\t0\t0.00\t0.00\t0\t0\t%s\t%s\n", sceneID, status, errText)
 		return err
 	}
 
 	stats := calculateCandidateStats(candidates)
-	_, err := fmt.Fprintf(tw, "%s\t%d\t%.2f\t%.2f\t%d\t%d\n",
-		sceneID, len(candidates), stats.meanScore, stats.maxScore, stats.minArea, stats.maxArea)
+	_, err := fmt.Fprintf(tw, "%s\t%d\t%.2f\t%.2f\t%d\t%d\t%s\t%s\n",
+		sceneID, len(candidates), stats.meanScore, stats.maxScore, stats.minArea, stats.maxArea, status, errText)
 	return err
 }
 
@@ -288,29 +659,21 @@ type candidateStats struct {
 }
 
 func calculateCandidateStats(candidates []detect.Candidate) candidateStats {
-	scoreSum := 0.0
-	scoreMax := candidates[0].Score
-	areaMin := candidates[0].AreaPx
-	areaMax := candidates[0].AreaPx
-
-	for _, c := range candidates {
-		scoreSum += c.Score
-		if c.Score > scoreMax {
-			scoreMax = c.Score
-		}
-		if c.AreaPx < areaMin {
-			areaMin = c.AreaPx
-		}
-		if c.AreaPx > areaMax {
-			areaMax = c.AreaPx
-		}
+	scores := make([]float64, len(candidates))
+	areas := make([]float64, len(candidates))
+	for i, c := range candidates {
+		scores[i] = c.Score
+		areas[i] = float64(c.AreaPx)
 	}
 
+	// Without percentiles Summarize cannot fail.
+	score, _ := detect.Summarize(scores, math.NaN())
+	area, _ := detect.Summarize(areas, math.NaN())
 	return candidateStats{
-		meanScore: scoreSum / float64(len(candidates)),
-		maxScore:  scoreMax,
-		minArea:   areaMin,
-		maxArea:   areaMax,
+		meanScore: score.Mean,
+		maxScore:  score.Max,
+		minArea:   int(area.Min),
+		maxArea:   int(area.Max),
 	}
 }
 
@@ -325,21 +688,23 @@ func ensureOutputDir(outPath string) error {
 	return nil
 }
 
-func bboxFromFiles(ctx context.Context, inputFiles []string) (minLon, minLat, maxLon, maxLat float64, err error) {
-	minLon = math.Inf(1)
-	minLat = math.Inf(1)
-	maxLon = math.Inf(-1)
-	maxLat = math.Inf(-1)
+// bboxFromFiles returns the union of the inputs' extents. Inputs whose
+// extent cannot be read are recorded as failed; ok is false when failures
+// are collected and no input has an extent, so there is nothing to
+// preprocess.
+func bboxFromFiles(ctx context.Context, inputFiles []string, failures *sceneFailures) (bbox [4]float64, ok bool, err error) {
+	minLon := math.Inf(1)
+	minLat := math.Inf(1)
+	maxLon := math.Inf(-1)
+	maxLat := math.Inf(-1)
 
 	for _, path := range inputFiles {
-		info, err := gdal.GetInfo(ctx, path)
+		bbox, err := inputBBox(ctx, path)
 		if err != nil {
-			return 0, 0, 0, 0, fmt.Errorf("get raster info: %w", err)
-		}
-
-		bbox, err := rasterBBox(info)
-		if err != nil {
-			return 0, 0, 0, 0, err
+			if err := failures.record(path, err); err != nil {
+				return [4]float64{}, false, err
+			}
+			continue
 		}
 
 		minLon = math.Min(minLon, bbox[0])
@@ -349,10 +714,21 @@ func bboxFromFiles(ctx context.Context, inputFiles []string) (minLon, minLat, ma
 	}
 
 	if math.IsInf(minLon, 1) || math.IsInf(minLat, 1) || math.IsInf(maxLon, -1) || math.IsInf(maxLat, -1) {
-		return 0, 0, 0, 0, fmt.Errorf("no valid raster extents found")
+		if failures.enabled {
+			return [4]float64{}, false, nil
+		}
+		return [4]float64{}, false, fmt.Errorf("no valid raster extents found")
 	}
 
-	return minLon, minLat, maxLon, maxLat, nil
+	return [4]float64{minLon, minLat, maxLon, maxLat}, true, nil
+}
+
+func inputBBox(ctx context.Context, path string) ([4]float64, error) {
+	info, err := gdal.GetInfo(ctx, path)
+	if err != nil {
+		return [4]float64{}, fmt.Errorf("get raster info: %w", err)
+	}
+	return rasterBBox(info)
 }
 
 func rasterBBox(info gdal.RasterInfo) ([4]float64, error) {
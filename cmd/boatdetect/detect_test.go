@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"boatdetect/internal/detect"
+	"boatdetect/internal/sentinel1"
+)
+
+func TestSceneGeometryFillsFromAnnotation(t *testing.T) {
+	ann := &sentinel1.Annotation{PlatformHeadingDeg: 190, PRFHz: 1700, SlantRangeM: 850000, VelocityMps: 7500}
+	geometry, err := sceneGeometry(detect.SARGeometry{HeadingDeg: math.NaN(), PRFHz: 1500}, ann, "scene.tif")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if geometry.HeadingDeg != 190 || geometry.PRFHz != 1500 || geometry.SlantRangeM != 850000 {
+		t.Fatalf("expected flags to override the annotation, got %+v", geometry)
+	}
+}
+
+func TestSceneGeometryRejectsMissingGhostParameters(t *testing.T) {
+	for name, tt := range map[string]struct {
+		geometry detect.SARGeometry
+		ann      *sentinel1.Annotation
+		want     string
+	}{
+		"no heading":       {detect.SARGeometry{HeadingDeg: math.NaN(), PRFHz: 1700, SlantRangeM: 850000}, nil, "heading"},
+		"no annotation":    {detect.SARGeometry{HeadingDeg: 10}, nil, "PRF or slant range"},
+		"annotation lacks": {detect.SARGeometry{HeadingDeg: math.NaN()}, &sentinel1.Annotation{PlatformHeadingDeg: 10, PRFHz: 1700}, "PRF or slant range"},
+	} {
+		_, err := sceneGeometry(tt.geometry, tt.ann, "scene.tif")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("%s: expected error about %s, got %v", name, tt.want, err)
+		}
+	}
+}
+
+func TestBBoxFromFilesAllFailedUnderContinueOnError(t *testing.T) {
+	// No gdalinfo on PATH, so every extent read fails.
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("BOATDETECT_GDAL_MODE", "local")
+	inputs := []string{filepath.Join("a", "S1.tif"), filepath.Join("b", "S2.tif")}
+
+	if _, _, err := bboxFromFiles(context.Background(), inputs, newSceneFailures(false)); err == nil {
+		t.Fatalf("expected error without --continue-on-error")
+	}
+
+	failures := newSceneFailures(true)
+	_, ok, err := bboxFromFiles(context.Background(), inputs, failures)
+	if err != nil || ok {
+		t.Fatalf("expected no extent and no error, got ok=%v err=%v", ok, err)
+	}
+	for _, path := range inputs {
+		if !failures.failed(path) {
+			t.Fatalf("expected %s recorded as failed", path)
+		}
+	}
+	if code := exitCode(failures.result([]string{"S1", "S2"})); code != exitSceneFailures {
+		t.Fatalf("expected exit code %d, got %d", exitSceneFailures, code)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"boatdetect/internal/atomicfile"
+	"boatdetect/internal/eval"
+	"boatdetect/internal/geojson"
+)
+
+func runEvalCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("eval")
+	detections := fs.String("detections", "", "Detections GeoJSON written by detect")
+	truth := fs.String("truth", "", "Ground-truth vessel positions as GeoJSON")
+	radius := fs.String("radius", "100m", "Largest match distance (m, km or nmi; a bare number is metres)")
+	method := fs.String("method", string(eval.MethodHungarian), "Matching method: hungarian (most matches, least total distance) or greedy (closest first)")
+	timeWindow := fs.Duration("time-window", 0, "Largest time difference between matched features that both carry a time (0 ignores times)")
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+	outPath := fs.String("out", "", "Also write the JSON result to this file")
+	if err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *detections == "" || *truth == "" {
+		return usagef("detections and truth are required")
+	}
+
+	cfg := eval.Config{TimeWindow: *timeWindow}
+	var err error
+	if cfg.RadiusM, err = eval.ParseDistance(*radius); err != nil {
+		return usagef("%v", err)
+	}
+	if cfg.RadiusM <= 0 {
+		return usagef("radius must be positive")
+	}
+	if cfg.Method, err = eval.ParseMethod(*method); err != nil {
+		return usagef("%v", err)
+	}
+	if *timeWindow < 0 {
+		return usagef("time-window must not be negative")
+	}
+
+	result, err := evaluate(*detections, *truth, cfg)
+	if err != nil {
+		return err
+	}
+	if *outPath != "" {
+		if err := atomicfile.Write(*outPath, func(w io.Writer) error { return writeEvalJSON(w, result) }); err != nil {
+			return fmt.Errorf("write eval result: %w", err)
+		}
+	}
+	if *asJSON {
+		return writeEvalJSON(os.Stdout, result)
+	}
+	return writeEvalTable(os.Stdout, result)
+}
+
+// evaluate reads both GeoJSON files and scores the detections.
+func evaluate(detectionsPath, truthPath string, cfg eval.Config) (eval.Result, error) {
+	detections, err := readPoints(detectionsPath)
+	if err != nil {
+		return eval.Result{}, err
+	}
+	truth, err := readPoints(truthPath)
+	if err != nil {
+		return eval.Result{}, err
+	}
+	return eval.Evaluate(detections, truth, cfg)
+}
+
+func readPoints(path string) ([]eval.Point, error) {
+	fc, err := geojson.ReadValidFeatureCollection(path)
+	if err != nil {
+		return nil, err
+	}
+	points, err := eval.Points(fc)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return points, nil
+}
+
+func writeEvalJSON(w io.Writer, result eval.Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// writeEvalTable prints one row per scene followed by the overall score.
+func writeEvalTable(w io.Writer, result eval.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "scene_id\ttp\tfp\tfn\tprecision\trecall\tf1\terr_mean_m\terr_median_m\terr_p90_m\terr_max_m"); err != nil {
+		return err
+	}
+	for _, s := range result.Scenes {
+		if err := writeEvalRow(tw, s.SceneID, s); err != nil {
+			return err
+		}
+	}
+	if err := writeEvalRow(tw, "all", result.Overall); err != nil {
+		return err
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d detections, %d truth positions, %s matching within %g m\n",
+		result.Detections, result.Truth, result.Method, result.RadiusM)
+	return err
+}
+
+func writeEvalRow(tw *tabwriter.Writer, sceneID string, s eval.Score) error {
+	if sceneID == "" {
+		sceneID = "-"
+	}
+	loc := s.Localization
+	_, err := fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.3f\t%.3f\t%.3f\t%.1f\t%.1f\t%.1f\t%.1f\n",
+		sceneID, s.TP, s.FP, s.FN, s.Precision, s.Recall, s.F1, loc.Mean, loc.Median, loc.P90, loc.Max)
+	return err
+}
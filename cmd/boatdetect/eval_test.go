@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadPointsRejectsInvalidPositions(t *testing.T) {
+	for name, input := range map[string]string{
+		"short": `{"type":"Point","coordinates":[1]}`,
+		"empty": `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[]},"properties":{}}]}`,
+		"range": `{"type":"Point","coordinates":[1,95]}`,
+	} {
+		path := filepath.Join(t.TempDir(), name+".geojson")
+		if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		_, err := readPoints(path)
+		if err == nil || !strings.Contains(err.Error(), path) {
+			t.Fatalf("%s: expected error naming %s, got %v", name, path, err)
+		}
+	}
+}
+
+func TestReadPoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "truth.geojson")
+	input := `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[10,54]},"properties":{"scene_id":"A"}}]}`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	points, err := readPoints(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(points) != 1 || points[0].SceneID != "A" || points[0].Lon != 10 || points[0].Lat != 54 {
+		t.Fatalf("expected one point in scene A, got %+v", points)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// exitSceneFailures is the exit code when --continue-on-error completed the
+// batch but some scenes failed.
+const exitSceneFailures = 3
+
+// sceneFailuresError reports that some scenes failed under --continue-on-error.
+type sceneFailuresError struct {
+	failed int
+	total  int
+}
+
+func (e *sceneFailuresError) Error() string {
+	return fmt.Sprintf("%d of %d scenes failed", e.failed, e.total)
+}
+
+// sceneFailures collects per-scene errors when enabled; otherwise errors are
+// returned unchanged and abort the batch.
+type sceneFailures struct {
+	enabled bool
+	mu      sync.Mutex
+	byPath  map[string]error
+	byScene map[string]error
+}
+
+func newSceneFailures(enabled bool) *sceneFailures {
+	return &sceneFailures{
+		enabled: enabled,
+		byPath:  make(map[string]error),
+		byScene: make(map[string]error),
+	}
+}
+
+// record stores err against the scene of inputPath and returns nil, or
+// returns err when failures are not being collected.
+func (f *sceneFailures) record(inputPath string, err error) error {
+	if err == nil || !f.enabled {
+		return err
+	}
+
+	sceneID := sceneIDFromPath(inputPath)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.byPath[inputPath] = errors.Join(f.byPath[inputPath], err)
+	f.byScene[sceneID] = errors.Join(f.byScene[sceneID], err)
+	return nil
+}
+
+// failed reports whether inputPath already failed in an earlier step.
+func (f *sceneFailures) failed(inputPath string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.byPath[inputPath]
+	return ok
+}
+
+// pathErr returns the errors recorded for inputPath, or nil.
+func (f *sceneFailures) pathErr(inputPath string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.byPath[inputPath]
+}
+
+// sceneErr returns the joined errors of a scene, or nil when it succeeded.
+func (f *sceneFailures) sceneErr(sceneID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.byScene[sceneID]
+}
+
+// withoutFailedScenes returns a copy of runs in which every run of a failed
+// scene has no candidates or raster, so that a scene's output is written
+// whole or not at all, even when only one of its inputs failed.
+func (f *sceneFailures) withoutFailedScenes(runs []sceneRun) []sceneRun {
+	kept := make([]sceneRun, len(runs))
+	for i, run := range runs {
+		if f.sceneErr(run.sceneID) != nil {
+			run.raster = ""
+			run.result.Candidates = nil
+		}
+		kept[i] = run
+	}
+	return kept
+}
+
+// result returns a sceneFailuresError when any of the scenes failed.
+func (f *sceneFailures) result(sceneOrder []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.byScene) == 0 {
+		return nil
+	}
+	return &sceneFailuresError{failed: len(f.byScene), total: len(sceneOrder)}
+}
+
+// summaryError flattens an error onto one line for the summary table.
+func summaryError(err error) string {
+	msg := strings.ReplaceAll(err.Error(), "\n", "; ")
+	return strings.ReplaceAll(msg, "\t", " ")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"boatdetect/internal/detect"
+)
+
+func TestSceneFailuresRecord(t *testing.T) {
+	errBoom := errors.New("boom")
+	if err := newSceneFailures(false).record("a/S1.tif", errBoom); err != errBoom {
+		t.Fatalf("expected error returned when not collecting, got %v", err)
+	}
+
+	f := newSceneFailures(true)
+	if err := f.record("a/S1.tif", errBoom); err != nil {
+		t.Fatalf("expected error collected, got %v", err)
+	}
+	if !f.failed("a/S1.tif") || f.failed("a/S2.tif") {
+		t.Fatalf("expected only a/S1.tif failed")
+	}
+	if !errors.Is(f.sceneErr(sceneIDFromPath("a/S1.tif")), errBoom) {
+		t.Fatalf("expected scene error recorded")
+	}
+	if f.pathErr("a/S2.tif") != nil {
+		t.Fatalf("expected no error for a/S2.tif")
+	}
+}
+
+func TestSceneFailuresResultExitCode(t *testing.T) {
+	f := newSceneFailures(true)
+	if err := f.result([]string{"S1", "S2"}); err != nil {
+		t.Fatalf("expected no error without failures, got %v", err)
+	}
+
+	f.record("a/S1.tif", errors.New("boom"))
+	err := f.result([]string{sceneIDFromPath("a/S1.tif"), "S2"})
+	if err == nil || err.Error() != "1 of 2 scenes failed" {
+		t.Fatalf("expected 1 of 2 scenes failed, got %v", err)
+	}
+	if code := exitCode(err); code != exitSceneFailures {
+		t.Fatalf("expected exit code %d, got %d", exitSceneFailures, code)
+	}
+}
+
+func TestWithoutFailedScenesDropsWholeScene(t *testing.T) {
+	f := newSceneFailures(true)
+	f.record("S1.SAFE/measurement/vv.tif", errors.New("boom"))
+	candidates := []detect.Candidate{{AreaPx: 3}}
+	runs := []sceneRun{
+		{path: "S1.SAFE/measurement/vv.tif", sceneID: "S1"},
+		{path: "S1.SAFE/measurement/vh.tif", sceneID: "S1", raster: "vh_byte.tif", result: detect.Result{Candidates: candidates}},
+		{path: "S2.SAFE/measurement/vv.tif", sceneID: "S2", raster: "s2_byte.tif", result: detect.Result{Candidates: candidates}},
+	}
+
+	kept := f.withoutFailedScenes(runs)
+	if kept[1].raster != "" || len(kept[1].result.Candidates) != 0 {
+		t.Fatalf("expected failed scene dropped, got %+v", kept[1])
+	}
+	if kept[2].raster == "" || len(kept[2].result.Candidates) != 1 {
+		t.Fatalf("expected other scene kept, got %+v", kept[2])
+	}
+	if len(runs[1].result.Candidates) != 1 {
+		t.Fatalf("expected runs left unchanged")
+	}
+
+	records, sceneOrder := collectCandidates(kept)
+	if len(records) != 1 || len(sceneOrder) != 2 {
+		t.Fatalf("expected 1 record over 2 scenes, got %d over %v", len(records), sceneOrder)
+	}
+}
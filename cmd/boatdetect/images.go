@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"boatdetect/internal/atomicfile"
+	"boatdetect/internal/gdal"
+)
+
+// sceneImages holds the PNGs embedded in the HTML report: a quicklook per
+// run and a chip per record, nil where none was rendered.
+type sceneImages struct {
+	quicklooks [][]byte
+	chips      [][]byte
+}
+
+// writeSceneImages reads the preprocessed raster of each detected scene once
+// and writes the chips and quicklooks that are enabled. With report set it
+// also renders both, in memory, for the HTML report.
+func writeSceneImages(ctx context.Context, ws *gdal.Workspace, runs []sceneRun, records []candidateRecord, opts outputConfig, report bool) (sceneImages, error) {
+	var images sceneImages
+	if report {
+		images = sceneImages{quicklooks: make([][]byte, len(runs)), chips: make([][]byte, len(records))}
+	}
+	chips, quicklooks := opts.Chips.Dir != "", opts.Quicklooks.Dir != ""
+	if !chips && !quicklooks && !report {
+		return images, nil
+	}
+	for _, dir := range []string{opts.Chips.Dir, opts.Quicklooks.Dir} {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return sceneImages{}, fmt.Errorf("create image dir: %w", err)
+		}
+	}
+
+	tempDir, cleanup, err := ws.TempDir("images-")
+	if err != nil {
+		return sceneImages{}, err
+	}
+	defer cleanup()
+
+	chipCounts := make(map[string]int)
+	names := quicklookNames(runs)
+	for i, run := range runs {
+		if run.raster == "" || (!quicklooks && !report && !hasSource(records, run.path)) {
+			continue
+		}
+		grid, err := gdal.ReadGrid(ctx, tempDir, run.raster)
+		if err != nil {
+			return sceneImages{}, fmt.Errorf("read %s: %w", run.path, err)
+		}
+
+		if chips || report {
+			if err := renderRunChips(run, grid, records, opts, chipCounts, images.chips); err != nil {
+				return sceneImages{}, err
+			}
+		}
+		if quicklooks || report {
+			data, err := quicklookPNG(run, grid, records, opts.Quicklooks.Size)
+			if err != nil {
+				return sceneImages{}, err
+			}
+			if report {
+				images.quicklooks[i] = data
+			}
+			if quicklooks {
+				if err := writeQuicklook(opts.Quicklooks.Dir, names[i], data); err != nil {
+					return sceneImages{}, err
+				}
+			}
+		}
+	}
+	return images, nil
+}
+
+// quicklookNames names each run's quicklook after its scene, numbering
+// scenes that span several inputs.
+func quicklookNames(runs []sceneRun) []string {
+	total := make(map[string]int)
+	for _, run := range runs {
+		total[run.sceneID]++
+	}
+	seen := make(map[string]int)
+	names := make([]string, len(runs))
+	for i, run := range runs {
+		names[i] = run.sceneID
+		if total[run.sceneID] > 1 {
+			seen[run.sceneID]++
+			names[i] = fmt.Sprintf("%s_%d", run.sceneID, seen[run.sceneID])
+		}
+	}
+	return names
+}
+
+func hasSource(records []candidateRecord, path string) bool {
+	for _, record := range records {
+		if record.source == path {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeImage returns the bytes written by encode.
+func encodeImage(encode func(w io.Writer) error) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBytes atomically writes data to path.
+func writeBytes(path string, data []byte) error {
+	return atomicfile.Write(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
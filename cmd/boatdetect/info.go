@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"boatdetect/internal/gdal"
+)
+
+type rasterSummary struct {
+	Path         string     `json:"path"`
+	Width        int        `json:"width"`
+	Height       int        `json:"height"`
+	GeoTransform [6]float64 `json:"geoTransform"`
+	BBox         [4]float64 `json:"bbox"`
+}
+
+func runInfoCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("info")
+	asJSON := fs.Bool("json", false, "Print a JSON array instead of a table")
+	if err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("info needs at least one raster path")
+	}
+
+	return withGDAL(ctx, func() error {
+		return runInfo(ctx, os.Stdout, fs.Args(), *asJSON)
+	})
+}
+
+func runInfo(ctx context.Context, out io.Writer, paths []string, asJSON bool) error {
+	summaries := make([]rasterSummary, 0, len(paths))
+	for _, path := range paths {
+		info, err := gdal.GetInfo(ctx, path)
+		if err != nil {
+			return fmt.Errorf("info %s: %w", path, err)
+		}
+		bbox, err := rasterBBox(info)
+		if err != nil {
+			return fmt.Errorf("info %s: %w", path, err)
+		}
+		summaries = append(summaries, rasterSummary{
+			Path:         path,
+			Width:        info.Width,
+			Height:       info.Height,
+			GeoTransform: info.GeoTransform,
+			BBox:         bbox,
+		})
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summaries)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "path\twidth\theight\tpixel_size\tbbox"); err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		if _, err := fmt.Fprintf(tw, "%s\t%d\t%d\t%g x %g\t%.6f,%.6f,%.6f,%.6f\n",
+			s.Path, s.Width, s.Height, s.GeoTransform[1], s.GeoTransform[5],
+			s.BBox[0], s.BBox[1], s.BBox[2], s.BBox[3]); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
@@ -2,28 +2,150 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"boatdetect/internal/gdal"
 )
 
+const (
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name     string
+	synopsis string
+	summary  string
+	run      func(ctx context.Context, args []string) error
+}
+
+func commands() []command {
+	return []command{
+		{"detect", "--input <dir> --out <file> [flags]", "Detect boat candidates and write them as GeoJSON", runDetectCommand},
+		{"info", "[flags] <raster>...", "Print raster size, geotransform and WGS84 extent", runInfoCommand},
+		{"preprocess", "--input <dir> --out-dir <dir> [flags]", "Warp and Byte-scale rasters and keep the outputs", runPreprocessCommand},
+		{"eval", "--detections <file> --truth <file> [flags]", "Score detections against ground-truth positions", runEvalCommand},
+		{"validate", "[--input <dir>] [flags]", "Check GDAL, working directories and inputs", runValidateCommand},
+		{"config", "print [--config <file>] [flags]", "Print the effective detect configuration", runConfigCommand},
+		{"cache", "ls|prune|clear [flags]", "List, prune or clear the preprocessing cache", runCacheCommand},
+		{"version", "[flags]", "Print the boatdetect version", runVersionCommand},
+	}
+}
+
 func main() {
-	ctx := context.Background()
-	if err := gdal.Initialize(ctx); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	os.Exit(run(context.Background(), os.Args[1:]))
+}
+
+func run(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+
+	name := args[0]
+	switch {
+	case name == "help" || name == "-h" || name == "--help":
+		printUsage(os.Stdout)
+		return 0
+	case strings.HasPrefix(name, "-"):
+		// Flags without a command keep the original single-action CLI working.
+		name = "detect"
+	default:
+		args = args[1:]
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return exitCode(cmd.run(ctx, args))
+		}
 	}
-	defer gdal.Shutdown()
 
-	opts, err := parseFlags()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(2)
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	return exitUsage
+}
+
+func exitCode(err error) int {
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return 0
 	}
 
-	if err := runDetect(ctx, os.Stdout, opts); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	var usage *usageError
+	if errors.As(err, &usage) {
+		if !usage.reported {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return exitUsage
 	}
+
+	fmt.Fprintln(os.Stderr, err)
+	var failed *sceneFailuresError
+	if errors.As(err, &failed) {
+		return exitSceneFailures
+	}
+	return exitFailure
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: boatdetect <command> [flags]\n\nCommands:\n")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nRun \"boatdetect <command> -h\" for the flags of a command.\n")
+}
+
+// usageError reports invalid arguments and exits with code 2. reported is
+// set when the flag package already printed the problem.
+type usageError struct {
+	msg      string
+	reported bool
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// newFlagSet returns a flag set whose help names the command.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		for _, cmd := range commands() {
+			if cmd.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: boatdetect %s %s\n\n%s.\n\nFlags:\n", cmd.name, cmd.synopsis, cmd.summary)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func parseArgs(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &usageError{msg: err.Error(), reported: true}
+	}
+	return nil
+}
+
+// withGDAL sets up the GDAL runner for the duration of fn.
+func withGDAL(ctx context.Context, fn func() error) error {
+	if err := gdal.Initialize(ctx); err != nil {
+		return err
+	}
+	defer gdal.Shutdown()
+	return fn()
 }
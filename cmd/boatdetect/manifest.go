@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"boatdetect/internal/atomicfile"
+	"boatdetect/internal/cache"
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/parallel"
+)
+
+// runManifest is the provenance record written next to detection outputs.
+type runManifest struct {
+	Tool            toolInfo         `json:"tool"`
+	GDAL            gdal.RuntimeInfo `json:"gdal"`
+	Host            hostInfo         `json:"host"`
+	StartedAt       time.Time        `json:"started_at"`
+	FinishedAt      time.Time        `json:"finished_at"`
+	DurationSeconds float64          `json:"duration_seconds"`
+	// BBox is the preprocessing extent, omitted when no input had one.
+	BBox   *[4]float64     `json:"bbox,omitempty"`
+	Config runConfig       `json:"config"`
+	Inputs []inputManifest `json:"inputs"`
+}
+
+type toolInfo struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+type hostInfo struct {
+	Hostname string `json:"hostname,omitempty"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	CPUs     int    `json:"cpus"`
+}
+
+// inputManifest records one input raster. Threshold and candidate counts
+// are omitted for inputs that failed or were dropped with their scene, and
+// the SHA-256 unless --manifest-hash is set.
+type inputManifest struct {
+	Path              string     `json:"path"`
+	SceneID           string     `json:"scene_id"`
+	SHA256            string     `json:"sha256,omitempty"`
+	SizeBytes         int64      `json:"size_bytes"`
+	ModifiedAt        *time.Time `json:"modified_at,omitempty"`
+	Status            string     `json:"status"`
+	Error             string     `json:"error,omitempty"`
+	Threshold         *float64   `json:"threshold,omitempty"`
+	Width             int        `json:"width,omitempty"`
+	Height            int        `json:"height,omitempty"`
+	Candidates        int        `json:"candidates"`
+	PreprocessSeconds float64    `json:"preprocess_seconds"`
+	DetectSeconds     float64    `json:"detect_seconds"`
+}
+
+// manifestPath returns where the run.json manifest of outPath goes:
+// <out without extension>.run.json, so runs writing to one directory each
+// keep their own.
+func manifestPath(outPath string) string {
+	return strings.TrimSuffix(outPath, filepath.Ext(outPath)) + ".run.json"
+}
+
+func newRunManifest(ctx context.Context, opts runConfig, started time.Time, bbox *[4]float64, runs []sceneRun, failures *sceneFailures) (runManifest, error) {
+	inputs, err := inputManifests(ctx, runs, opts.Performance.Jobs, opts.Output.ManifestHash, failures)
+	if err != nil {
+		return runManifest{}, err
+	}
+
+	revision, modified := vcsRevision()
+	hostname, _ := os.Hostname()
+	finished := time.Now()
+	return runManifest{
+		Tool: toolInfo{
+			Name:      "boatdetect",
+			Version:   buildVersion(),
+			Commit:    revision,
+			Modified:  modified,
+			GoVersion: runtime.Version(),
+		},
+		GDAL: gdal.Runtime(ctx),
+		Host: hostInfo{
+			Hostname: hostname,
+			OS:       runtime.GOOS,
+			Arch:     runtime.GOARCH,
+			CPUs:     runtime.NumCPU(),
+		},
+		StartedAt:       started.UTC(),
+		FinishedAt:      finished.UTC(),
+		DurationSeconds: finished.Sub(started).Seconds(),
+		BBox:            bbox,
+		Config:          opts,
+		Inputs:          inputs,
+	}, nil
+}
+
+// inputManifests describes each run by its file size and modification time
+// and, with hash, the SHA-256 of the input, computed on up to jobs
+// goroutines.
+func inputManifests(ctx context.Context, runs []sceneRun, jobs int, hash bool, failures *sceneFailures) ([]inputManifest, error) {
+	inputs := make([]inputManifest, len(runs))
+	err := parallel.ForEach(ctx, len(runs), jobs, func(ctx context.Context, i int) error {
+		run := runs[i]
+		in := inputManifest{
+			Path:              run.path,
+			SceneID:           run.sceneID,
+			Status:            "ok",
+			PreprocessSeconds: run.preprocess.Seconds(),
+			DetectSeconds:     run.detect.Seconds(),
+		}
+		if err := failures.pathErr(run.path); err != nil {
+			in.Status = "failed"
+			in.Error = summaryError(err)
+		} else if failures.sceneErr(run.sceneID) != nil {
+			// Its candidates were dropped with the rest of the scene.
+			in.Status = "dropped"
+			in.Error = "another input of the scene failed"
+		} else {
+			threshold := run.result.Threshold
+			in.Threshold = &threshold
+			in.Width = run.result.Width
+			in.Height = run.result.Height
+			in.Candidates = len(run.result.Candidates)
+		}
+
+		if fi, err := os.Stat(run.path); err == nil {
+			in.SizeBytes = fi.Size()
+			modified := fi.ModTime().UTC()
+			in.ModifiedAt = &modified
+		}
+		if hash {
+			// A scene that failed may also be unreadable; leave its hash empty.
+			sum, err := cache.ContentHash(run.path)
+			if err != nil && in.Status == "ok" {
+				return fmt.Errorf("hash %s: %w", run.path, err)
+			}
+			in.SHA256 = strings.TrimPrefix(sum, "sha256:")
+		}
+		inputs[i] = in
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inputs, nil
+}
+
+func writeRunManifest(path string, m runManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode run manifest: %w", err)
+	}
+	err = atomicfile.Write(path, func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("write run manifest: %w", err)
+	}
+	return nil
+}
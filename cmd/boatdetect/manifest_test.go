@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"boatdetect/internal/detect"
+)
+
+func TestManifestPath(t *testing.T) {
+	for out, want := range map[string]string{
+		"detections.geojson":   "detections.run.json",
+		"out/results":          "out/results.run.json",
+		"out.d/detections.csv": "out.d/detections.run.json",
+	} {
+		if got := manifestPath(out); got != want {
+			t.Fatalf("%s: expected %s, got %s", out, want, got)
+		}
+	}
+}
+
+func TestNewRunManifestInputs(t *testing.T) {
+	t.Setenv("BOATDETECT_GDAL_MODE", "local")
+	dir := t.TempDir()
+	okPath := filepath.Join(dir, "ok.tif")
+	data := []byte("raster")
+	if err := os.WriteFile(okPath, data, 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	badPath := filepath.Join(dir, "missing.tif")
+
+	failures := newSceneFailures(true)
+	failures.record(badPath, errors.New("preprocess failed"))
+	runs := []sceneRun{
+		{path: okPath, sceneID: "ok", result: detect.Result{Threshold: 12.5, Width: 4, Height: 3, Candidates: make([]detect.Candidate, 2)}},
+		{path: badPath, sceneID: "missing"},
+	}
+
+	opts := defaultRunConfig()
+	opts.Output.ManifestHash = true
+	m, err := newRunManifest(context.Background(), opts, time.Now(), &[4]float64{}, runs, failures)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(m.Inputs) != 2 {
+		t.Fatalf("expected 2 inputs, got %d", len(m.Inputs))
+	}
+
+	ok := m.Inputs[0]
+	sum := sha256.Sum256(data)
+	if ok.Status != "ok" || ok.Threshold == nil || *ok.Threshold != 12.5 || ok.Candidates != 2 {
+		t.Fatalf("unexpected ok input %+v", ok)
+	}
+	if ok.SHA256 != hex.EncodeToString(sum[:]) || ok.SizeBytes != int64(len(data)) || ok.ModifiedAt == nil {
+		t.Fatalf("unexpected ok input identity %+v", ok)
+	}
+
+	failed := m.Inputs[1]
+	if failed.Status != "failed" || failed.Error != "preprocess failed" || failed.Threshold != nil || failed.SHA256 != "" {
+		t.Fatalf("unexpected failed input %+v", failed)
+	}
+
+	opts.Output.ManifestHash = false
+	m, err = newRunManifest(context.Background(), opts, time.Now(), &[4]float64{}, runs, failures)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if m.Inputs[0].SHA256 != "" {
+		t.Fatalf("expected no hash without --manifest-hash, got %s", m.Inputs[0].SHA256)
+	}
+}
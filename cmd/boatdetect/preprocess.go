@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/parallel"
+)
+
+type preprocessOptions struct {
+	input  string
+	outDir string
+	bbox   string
+	jobs   int
+	cache  cacheOptions
+}
+
+func runPreprocessCommand(ctx context.Context, args []string) error {
+	opts := preprocessOptions{cache: defaultCacheOptions()}
+	fs := newFlagSet("preprocess")
+	fs.StringVar(&opts.input, "input", "", "Input folder containing .tif or .SAFE")
+	fs.StringVar(&opts.outDir, "out-dir", "", "Directory for the Byte-scaled GeoTIFFs")
+	fs.StringVar(&opts.bbox, "bbox", "", "Output extent as minLon,minLat,maxLon,maxLat (default: union of the inputs)")
+	fs.IntVar(&opts.jobs, "jobs", 1, "Number of rasters preprocessed concurrently (0 uses one per CPU)")
+	addCacheFlags(fs, &opts.cache)
+	if err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	if opts.input == "" {
+		return usagef("input is required")
+	}
+	if opts.outDir == "" {
+		return usagef("out-dir is required")
+	}
+
+	return withGDAL(ctx, func() error {
+		return runPreprocess(ctx, os.Stdout, opts)
+	})
+}
+
+func runPreprocess(ctx context.Context, out io.Writer, opts preprocessOptions) error {
+	inputFiles, err := findTifFiles(opts.input)
+	if err != nil {
+		return err
+	}
+	if len(inputFiles) == 0 {
+		return fmt.Errorf("no .tif files found in %s", opts.input)
+	}
+
+	bbox, err := preprocessBBox(ctx, opts.bbox, inputFiles)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(opts.outDir, 0o755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+
+	ws, err := gdal.NewWorkspace(gdal.DefaultWorkspaceRoot, false)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	store, pc, err := openPreprocessCache(ctx, opts.cache)
+	if err != nil {
+		return err
+	}
+	defer pc.Release()
+
+	outputs := make([]string, len(inputFiles))
+	err = parallel.ForEach(ctx, len(inputFiles), opts.jobs, func(ctx context.Context, i int) error {
+		byteTif, err := gdal.Preprocess(ctx, ws, pc, inputFiles[i], bbox)
+		if err != nil {
+			return fmt.Errorf("preprocess %s: %w", inputFiles[i], err)
+		}
+
+		// Copy rather than move: the source may be a cache entry.
+		outputs[i] = filepath.Join(opts.outDir, preprocessedName(inputFiles[i]))
+		return copyFile(byteTif, outputs[i])
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := pruneCache(store, pc, opts.cache.MaxSize); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "input\toutput"); err != nil {
+		return err
+	}
+	for i, input := range inputFiles {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\n", input, outputs[i]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	return ws.Close()
+}
+
+func preprocessBBox(ctx context.Context, flagValue string, inputFiles []string) ([4]float64, error) {
+	if flagValue != "" {
+		return parseBBox(flagValue)
+	}
+
+	// Without collected failures an unreadable extent is an error, so the
+	// union is always set.
+	bbox, _, err := bboxFromFiles(ctx, inputFiles, newSceneFailures(false))
+	return bbox, err
+}
+
+// parseBBox parses "minLon,minLat,maxLon,maxLat".
+func parseBBox(s string) ([4]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return [4]float64{}, usagef("bbox must be minLon,minLat,maxLon,maxLat, got %q", s)
+	}
+
+	var bbox [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return [4]float64{}, usagef("bbox: invalid number %q", part)
+		}
+		bbox[i] = v
+	}
+	if bbox[0] >= bbox[2] || bbox[1] >= bbox[3] {
+		return [4]float64{}, usagef("bbox minimums must be below maximums, got %q", s)
+	}
+	return bbox, nil
+}
+
+func preprocessedName(inputPath string) string {
+	base := filepath.Base(inputPath)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + "_byte.tif"
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy to %s: %w", dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", dst, err)
+	}
+	return nil
+}
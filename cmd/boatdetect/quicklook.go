@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"image/png"
+	"io"
+	"math"
+	"path/filepath"
+
+	"boatdetect/internal/detect"
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/imagery"
+)
+
+// validateQuicklooks checks the quicklook settings when quicklooks are
+// written or rendered into the report.
+func validateQuicklooks(cfg quicklookConfig, report bool) error {
+	if (cfg.Dir != "" || report) && cfg.Size <= 0 {
+		return usagef("quicklook-size must be positive")
+	}
+	return nil
+}
+
+// quicklookPNG renders the grid of run with its candidates as a PNG.
+func quicklookPNG(run sceneRun, grid gdal.Grid, records []candidateRecord, size int) ([]byte, error) {
+	var markers []imagery.Marker
+	for _, record := range records {
+		if record.source != run.path {
+			continue
+		}
+		x, y := detect.LonLatToPixel(run.result.GeoTransform, record.candidate.Lon, record.candidate.Lat)
+		markers = append(markers, imagery.Marker{
+			X:     x,
+			Y:     y,
+			Half:  math.Sqrt(float64(record.candidate.AreaPx)) / 2,
+			Score: record.candidate.Score,
+		})
+	}
+
+	img := imagery.Quicklook(grid, size, markers)
+	data, err := encodeImage(func(w io.Writer) error { return png.Encode(w, img) })
+	if err != nil {
+		return nil, fmt.Errorf("encode quicklook: %w", err)
+	}
+	return data, nil
+}
+
+// writeQuicklook writes a quicklook PNG to <dir>/<name>.png.
+func writeQuicklook(dir, name string, data []byte) error {
+	if err := writeBytes(filepath.Join(dir, name+".png"), data); err != nil {
+		return fmt.Errorf("write quicklook: %w", err)
+	}
+	return nil
+}
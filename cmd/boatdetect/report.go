@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"boatdetect/internal/atomicfile"
+	"boatdetect/internal/detect"
+	"boatdetect/internal/report"
+)
+
+// newReport collects the summary, candidates and rendered images of a run
+// for the HTML report.
+func newReport(started time.Time, sceneOrder []string, byScene map[string][]detect.Candidate, failures *sceneFailures, runs []sceneRun, records []candidateRecord, images sceneImages) report.Report {
+	quicklooks := make(map[string][][]byte)
+	for i, run := range runs {
+		if images.quicklooks[i] != nil {
+			quicklooks[run.sceneID] = append(quicklooks[run.sceneID], images.quicklooks[i])
+		}
+	}
+
+	r := report.Report{
+		Title:     "boatdetect run " + started.Format("2006-01-02 15:04"),
+		Version:   buildVersion(),
+		Generated: time.Now(),
+	}
+	for _, sceneID := range sceneOrder {
+		r.Scenes = append(r.Scenes, reportScene(sceneID, byScene[sceneID], failures.sceneErr(sceneID), quicklooks[sceneID]))
+	}
+	for i, record := range records {
+		c := record.candidate
+		r.Candidates = append(r.Candidates, report.Candidate{
+			SceneID:        record.sceneID,
+			Lon:            c.Lon,
+			Lat:            c.Lat,
+			Score:          c.Score,
+			AreaPx:         c.AreaPx,
+			LengthM:        c.Shape.LengthM,
+			WidthM:         c.Shape.WidthM,
+			OrientationDeg: c.Shape.OrientationDeg,
+			Reason:         c.Reason,
+			Chip:           images.chips[i],
+		})
+	}
+	return r
+}
+
+// reportScene builds the summary row of a scene, matching writeSummaryRow.
+func reportScene(sceneID string, candidates []detect.Candidate, sceneErr error, quicklooks [][]byte) report.Scene {
+	scene := report.Scene{ID: sceneID, Candidates: len(candidates), Quicklooks: quicklooks}
+	if sceneErr != nil {
+		scene.Error = summaryError(sceneErr)
+	}
+	if len(candidates) > 0 {
+		stats := calculateCandidateStats(candidates)
+		scene.ScoreMean, scene.ScoreMax = stats.meanScore, stats.maxScore
+		scene.AreaMin, scene.AreaMax = stats.minArea, stats.maxArea
+	}
+	return scene
+}
+
+// writeReport atomically writes the HTML report to path.
+func writeReport(path string, r report.Report) error {
+	if err := ensureOutputDir(path); err != nil {
+		return err
+	}
+	if err := atomicfile.Write(path, func(w io.Writer) error { return report.Write(w, r) }); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"boatdetect/internal/gdal"
+)
+
+// sceneStream writes the records of each scene, and renders its images, as
+// soon as every input of the scene is done, rather than once the whole batch
+// is. Scenes are written in the order of their first input, so a finished
+// scene waits for the scenes before it. It is used when no candidate limit
+// ranks records across scenes.
+type sceneStream struct {
+	ws       *gdal.Workspace
+	opts     outputConfig
+	report   bool
+	failures *sceneFailures
+	writers  *outputWriters
+
+	mu     sync.Mutex
+	runs   []sceneRun
+	scenes []streamScene
+	// sceneOf is the index in scenes of each input's scene.
+	sceneOf []int
+	// next is the first scene not yet written.
+	next    int
+	records []candidateRecord
+	images  sceneImages
+}
+
+// streamScene is a scene and the indices of its inputs.
+type streamScene struct {
+	id      string
+	inputs  []int
+	pending int
+}
+
+func newSceneStream(ws *gdal.Workspace, inputFiles []string, opts outputConfig, report bool, failures *sceneFailures, writers *outputWriters) *sceneStream {
+	s := &sceneStream{
+		ws:       ws,
+		opts:     opts,
+		report:   report,
+		failures: failures,
+		writers:  writers,
+		runs:     make([]sceneRun, len(inputFiles)),
+		sceneOf:  make([]int, len(inputFiles)),
+	}
+	if report {
+		s.images.quicklooks = make([][]byte, len(inputFiles))
+	}
+
+	index := make(map[string]int)
+	for i, path := range inputFiles {
+		sceneID := sceneIDFromPath(path)
+		j, ok := index[sceneID]
+		if !ok {
+			j = len(s.scenes)
+			index[sceneID] = j
+			s.scenes = append(s.scenes, streamScene{id: sceneID})
+		}
+		s.sceneOf[i] = j
+		s.scenes[j].inputs = append(s.scenes[j].inputs, i)
+		s.scenes[j].pending++
+	}
+	return s
+}
+
+// done records that input i finished, failed or not, and writes the scenes
+// this completes.
+func (s *sceneStream) done(ctx context.Context, i int, run sceneRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs[i] = run
+	s.scenes[s.sceneOf[i]].pending--
+
+	for s.next < len(s.scenes) && s.scenes[s.next].pending == 0 {
+		if err := s.writeScene(ctx, s.scenes[s.next]); err != nil {
+			return err
+		}
+		s.next++
+	}
+	return nil
+}
+
+// writeScene renders the images of a finished scene and writes its records.
+// A failed scene is written with no records, as in the batch path.
+func (s *sceneStream) writeScene(ctx context.Context, scene streamScene) error {
+	runs := make([]sceneRun, len(scene.inputs))
+	for j, i := range scene.inputs {
+		runs[j] = s.runs[i]
+	}
+	runs = s.failures.withoutFailedScenes(runs)
+
+	var records []candidateRecord
+	for _, run := range runs {
+		records = appendCandidateRecords(records, run)
+	}
+
+	images, err := writeSceneImages(ctx, s.ws, runs, records, s.opts, s.report)
+	if err != nil {
+		return err
+	}
+	if s.report {
+		for j, i := range scene.inputs {
+			s.images.quicklooks[i] = images.quicklooks[j]
+		}
+		s.images.chips = append(s.images.chips, images.chips...)
+	}
+
+	if err := s.writers.write(records); err != nil {
+		return err
+	}
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// close commits the output files and returns the records written, in scene
+// order, with the images rendered for the report.
+func (s *sceneStream) close() ([]candidateRecord, sceneImages, error) {
+	if err := s.writers.close(); err != nil {
+		return nil, sceneImages{}, err
+	}
+	return s.records, s.images, nil
+}
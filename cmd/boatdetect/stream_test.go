@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"boatdetect/internal/detect"
+	"boatdetect/internal/output"
+)
+
+// recordingWriter keeps the scene of every record written to it.
+type recordingWriter struct {
+	scenes []string
+	closed bool
+}
+
+func (w *recordingWriter) Write(r output.Record) error {
+	w.scenes = append(w.scenes, r.SceneID)
+	return nil
+}
+
+func (w *recordingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func (w *recordingWriter) Abort() {}
+
+func TestSceneStreamWritesScenesInOrderOnceDone(t *testing.T) {
+	inputs := []string{"S1.SAFE/measurement/vv.tif", "S2.SAFE/measurement/vv.tif", "S1.SAFE/measurement/vh.tif", "S3.SAFE/measurement/vv.tif"}
+	failures := newSceneFailures(true)
+	w := &recordingWriter{}
+	writers := &outputWriters{formats: []output.Format{{Name: "test"}}, writers: []output.Writer{w}}
+	stream := newSceneStream(nil, inputs, outputConfig{}, false, failures, writers)
+
+	run := func(i int) sceneRun {
+		return sceneRun{
+			path:    inputs[i],
+			sceneID: sceneIDFromPath(inputs[i]),
+			raster:  "byte.tif",
+			result:  detect.Result{Candidates: []detect.Candidate{{AreaPx: i + 1}}},
+		}
+	}
+	done := func(i int) {
+		t.Helper()
+		if err := stream.done(context.Background(), i, run(i)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	// S2 finishes first but waits for S1, which waits for its second input.
+	done(1)
+	done(0)
+	if len(w.scenes) != 0 {
+		t.Fatalf("expected nothing written before S1 finishes, got %v", w.scenes)
+	}
+	failures.record(inputs[3], errors.New("boom"))
+	done(3)
+	done(2)
+	if want := []string{"S1", "S1", "S2"}; !reflect.DeepEqual(w.scenes, want) {
+		t.Fatalf("expected %v written, got %v", want, w.scenes)
+	}
+
+	records, _, err := stream.close()
+	if err != nil || !w.closed {
+		t.Fatalf("expected writer closed, got %v", err)
+	}
+	if len(records) != 3 || records[1].source != inputs[2] {
+		t.Fatalf("expected S1 inputs then S2, got %+v", records)
+	}
+}
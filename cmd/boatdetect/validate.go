@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"boatdetect/internal/cache"
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/sentinel1"
+)
+
+type checkResult struct {
+	name   string
+	err    error
+	detail string
+}
+
+func runValidateCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("validate")
+	input := fs.String("input", "", "Input folder to check (optional)")
+	cacheOpts := defaultCacheOptions()
+	addCacheFlags(fs, &cacheOpts)
+	if err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	return runValidate(ctx, os.Stdout, *input, cacheOpts)
+}
+
+// runValidate checks the environment and, when given, the inputs without
+// preprocessing anything. Every check runs; the error counts the failures.
+func runValidate(ctx context.Context, out io.Writer, input string, cacheOpts cacheOptions) error {
+	results := make([]checkResult, 0)
+	add := func(name, detail string, err error) {
+		results = append(results, checkResult{name: name, err: err, detail: detail})
+	}
+
+	gdalErr := gdal.Initialize(ctx)
+	if gdalErr == nil {
+		defer gdal.Shutdown()
+		v, err := gdal.Version(ctx)
+		add("gdal", v, err)
+	} else {
+		add("gdal", "", gdalErr)
+	}
+
+	ws, err := gdal.NewWorkspace(gdal.DefaultWorkspaceRoot, false)
+	if err == nil {
+		err = ws.Close()
+	}
+	add("workspace", gdal.DefaultWorkspaceRoot, err)
+
+	if cacheOpts.enabled() {
+		err := checkCacheDir(cacheOpts.Dir)
+		if err == nil {
+			_, err = cache.ParseSize(cacheOpts.MaxSize)
+		}
+		add("cache", cacheOpts.Dir, err)
+	}
+
+	if input != "" {
+		validateInputs(ctx, input, gdalErr == nil, add)
+	}
+
+	if err := writeChecks(out, results); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+func validateInputs(ctx context.Context, input string, haveGDAL bool, add func(name, detail string, err error)) {
+	inputFiles, err := findTifFiles(input)
+	if err == nil && len(inputFiles) == 0 {
+		err = fmt.Errorf("no .tif files found")
+	}
+	add("input", fmt.Sprintf("%s: %d rasters", input, len(inputFiles)), err)
+
+	for _, path := range inputFiles {
+		if haveGDAL {
+			bbox, err := inputBBox(ctx, path)
+			add("raster", fmt.Sprintf("%s: %.4f,%.4f,%.4f,%.4f", path, bbox[0], bbox[1], bbox[2], bbox[3]), err)
+		}
+
+		if annPath, ok := sentinel1.AnnotationPath(path); ok {
+			_, err := sentinel1.ReadAnnotationFile(annPath)
+			add("annotation", annPath, err)
+		}
+	}
+}
+
+func writeChecks(out io.Writer, results []checkResult) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "check\tstatus\tdetail"); err != nil {
+		return err
+	}
+	for _, r := range results {
+		status, detail := "ok", r.detail
+		if r.err != nil {
+			status = "failed"
+			detail = strings.TrimSpace(strings.Join([]string{r.detail, summaryError(r.err)}, " "))
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", r.name, status, detail); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// checkCacheDir accepts a cache directory that exists or can be created on
+// the first store, without creating it.
+func checkCacheDir(dir string) error {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat cache dir: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"boatdetect/internal/gdal"
+)
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3".
+var version = ""
+
+func runVersionCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("version")
+	withGDALVersion := fs.Bool("gdal", false, "Also print the GDAL version (needs GDAL)")
+	if err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	if err := printVersion(os.Stdout); err != nil {
+		return err
+	}
+	if !*withGDALVersion {
+		return nil
+	}
+	return withGDAL(ctx, func() error {
+		v, err := gdal.Version(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(v)
+		return err
+	})
+}
+
+func printVersion(out io.Writer) error {
+	_, err := fmt.Fprintf(out, "boatdetect %s (%s %s/%s)\n", buildVersion(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return err
+}
+
+// buildVersion prefers the linker-set version, then the module version, then
+// the VCS revision recorded by go build.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+
+	revision, modified := vcsRevision()
+	if revision == "" {
+		return "dev"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return "dev-" + revision
+}
+
+// vcsRevision returns the commit recorded by go build and whether the tree
+// had uncommitted changes; revision is empty when none was recorded.
+func vcsRevision() (revision string, modified bool) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", false
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	return revision, modified
+}
@@ -0,0 +1,93 @@
+// Package atomicfile writes files so readers never observe partial content.
+package atomicfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// File is a buffered temporary file that replaces its target on Commit.
+type File struct {
+	path string
+	tmp  *os.File
+	buf  *bufio.Writer
+	done bool
+}
+
+// Create opens a temporary file next to path. Call Commit to rename it over
+// path or Abort to remove it; path is untouched until Commit succeeds.
+func Create(path string) (*File, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+	return &File{path: path, tmp: tmp, buf: bufio.NewWriter(tmp)}, nil
+}
+
+// Write writes p to the temporary file.
+func (f *File) Write(p []byte) (int, error) {
+	return f.buf.Write(p)
+}
+
+// Commit flushes, syncs and renames the temporary file over the target. On
+// failure the temporary file is removed.
+func (f *File) Commit() (err error) {
+	if f.done {
+		return fmt.Errorf("commit %s: already closed", f.path)
+	}
+	defer func() {
+		if err != nil {
+			f.Abort()
+		}
+	}()
+
+	if err := f.buf.Flush(); err != nil {
+		return fmt.Errorf("write %s: %w", f.path, err)
+	}
+	if err := f.tmp.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", f.path, err)
+	}
+	if err := f.tmp.Chmod(0o644); err != nil {
+		return fmt.Errorf("chmod %s: %w", f.path, err)
+	}
+	if err := f.tmp.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", f.path, err)
+	}
+	if err := os.Rename(f.tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("rename %s: %w", f.path, err)
+	}
+	f.done = true
+	return nil
+}
+
+// Abort removes the temporary file. It is a no-op after Commit or Abort.
+func (f *File) Abort() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	f.tmp.Close()
+	return os.Remove(f.tmp.Name())
+}
+
+// Write calls fn with a buffered writer to a temporary file next to path and
+// renames it over path once fn and the flush succeed. On failure path is left
+// untouched and the temporary file is removed.
+func Write(path string, fn func(w io.Writer) error) error {
+	f, err := Create(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
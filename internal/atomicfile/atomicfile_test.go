@@ -0,0 +1,89 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	err := Write(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Fatalf("expected new content, got %q (%v)", data, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o644 {
+		t.Fatalf("expected mode 0644, got %v (%v)", info.Mode(), err)
+	}
+	assertOnlyFile(t, dir, "out.txt")
+}
+
+func TestWriteFailureKeepsOriginal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	boom := errors.New("boom")
+	err := Write(path, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "old" {
+		t.Fatalf("expected original content, got %q", data)
+	}
+	assertOnlyFile(t, dir, "out.txt")
+}
+
+func assertOnlyFile(t *testing.T, dir, name string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != name {
+		t.Fatalf("expected only %s, got %v", name, entries)
+	}
+}
+
+func TestCreateAbortLeavesNoFile(t *testing.T) {
+	dir := t.TempDir()
+	f, err := Create(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	io.WriteString(f, "partial")
+	if err := f.Abort(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := f.Commit(); err == nil {
+		t.Fatalf("expected commit after abort to fail")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Fatalf("expected empty dir, got %v", entries)
+	}
+}
@@ -0,0 +1,384 @@
+// Package cache stores preprocessed rasters across runs, keyed by the input
+// file identity and the parameters that produced them.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	dataExt = ".tif"
+	metaExt = ".json"
+)
+
+// Cache is a directory of files addressed by key. Each entry is a data file
+// and a JSON sidecar; the data file's modification time records last use.
+type Cache struct {
+	dir          string
+	hashContents bool
+}
+
+// Entry describes one cached file.
+type Entry struct {
+	Key      string    `json:"key"`
+	Source   string    `json:"source"`
+	Created  time.Time `json:"created"`
+	Size     int64     `json:"-"`
+	LastUsed time.Time `json:"-"`
+	Path     string    `json:"-"`
+}
+
+// ErrNotCached is returned by Acquire when the entry is missing or was
+// removed before it could be leased.
+var ErrNotCached = errors.New("entry not cached")
+
+// Open returns a cache over dir. The directory is created on the first
+// Store, so listing or pruning a missing cache leaves no trace. With
+// hashContents, inputs are fingerprinted by a content hash instead of file
+// metadata.
+func Open(dir string, hashContents bool) (*Cache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("cache dir is empty")
+	}
+	return &Cache{dir: dir, hashContents: hashContents}, nil
+}
+
+// Lease is a shared hold on an entry. Prune and Clear skip leased entries,
+// so a run can keep reading a cached file in place.
+type Lease struct {
+	f *os.File
+}
+
+// Release gives up the lease.
+func (l *Lease) Release() error {
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("release cache entry: %w", err)
+	}
+	return nil
+}
+
+// Acquire leases the entry of key until the lease is released or the
+// process exits. It returns ErrNotCached when there is no such entry.
+func (c *Cache) Acquire(key string) (*Lease, error) {
+	path := c.dataPath(key)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotCached
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open cache entry: %w", err)
+	}
+	if err := lockShared(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock cache entry: %w", err)
+	}
+
+	// A prune may have removed the entry, or a store replaced it, between
+	// opening and locking.
+	held, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("stat cache entry: %w", err)
+	}
+	current, err := os.Stat(path)
+	if err != nil || !os.SameFile(held, current) {
+		f.Close()
+		return nil, ErrNotCached
+	}
+	if _, err := os.Stat(c.metaPath(key)); err != nil {
+		f.Close()
+		return nil, ErrNotCached
+	}
+	return &Lease{f: f}, nil
+}
+
+// Dir returns the cache directory.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Key derives an entry key from its parts.
+func Key(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Fingerprint identifies the contents of the file at path, using the cache's
+// fingerprint mode.
+func (c *Cache) Fingerprint(path string) (string, error) {
+	if c.hashContents {
+		return ContentHash(path)
+	}
+	return StatFingerprint(path)
+}
+
+// StatFingerprint identifies a file by size, modification time and, where
+// the platform has one, inode, without reading it.
+func StatFingerprint(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("stat input: %w", err)
+	}
+	return fmt.Sprintf("stat:%d:%d:%d", info.Size(), info.ModTime().UnixNano(), fileID(info)), nil
+}
+
+// ContentHash identifies a file by the SHA-256 of its contents.
+func ContentHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open input: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash input: %w", err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Lookup returns the data path of key and marks it used.
+func (c *Cache) Lookup(key string) (string, bool) {
+	path := c.dataPath(key)
+	if _, err := os.Stat(c.metaPath(key)); err != nil {
+		return "", false
+	}
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// Store moves srcPath into the cache under key and returns its new path.
+// source is recorded for listing.
+func (c *Cache) Store(key, srcPath, source string) (string, error) {
+	dst := c.dataPath(key)
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return "", fmt.Errorf("create cache dir: %w", err)
+	}
+
+	// Stage next to the destination so the final rename is atomic and
+	// concurrent runs never see a partial file.
+	staged, err := os.CreateTemp(c.dir, key+"-*.partial")
+	if err != nil {
+		return "", fmt.Errorf("stage cache entry: %w", err)
+	}
+	stagedPath := staged.Name()
+	staged.Close()
+	defer os.Remove(stagedPath)
+
+	if err := moveFile(srcPath, stagedPath); err != nil {
+		return "", fmt.Errorf("stage cache entry: %w", err)
+	}
+	if err := os.Rename(stagedPath, dst); err != nil {
+		return "", fmt.Errorf("store cache entry: %w", err)
+	}
+
+	meta, err := json.Marshal(Entry{Key: key, Source: source, Created: time.Now().UTC()})
+	if err != nil {
+		return "", fmt.Errorf("encode cache entry: %w", err)
+	}
+	if err := os.WriteFile(c.metaPath(key), meta, 0o644); err != nil {
+		return "", fmt.Errorf("write cache entry: %w", err)
+	}
+
+	return dst, nil
+}
+
+// List returns the cache entries, most recently used first.
+func (c *Cache) List() ([]Entry, error) {
+	metas, err := filepath.Glob(filepath.Join(c.dir, "*"+metaExt))
+	if err != nil {
+		return nil, fmt.Errorf("list cache: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(metas))
+	for _, metaPath := range metas {
+		entry, err := c.readEntry(metaPath)
+		if err != nil {
+			// Half-written or foreign files are not entries; Clear removes them.
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].LastUsed.Equal(entries[j].LastUsed) {
+			return entries[i].LastUsed.After(entries[j].LastUsed)
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+// Prune removes entries unused for longer than maxAge, then least recently
+// used entries until the total size is at most maxBytes. Zero disables a
+// limit. Leased entries are kept. It returns the removed entries.
+func (c *Cache) Prune(maxBytes int64, maxAge time.Duration) ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	removed := make([]Entry, 0)
+	cutoff := time.Now().Add(-maxAge)
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		expired := maxAge > 0 && e.LastUsed.Before(cutoff)
+		oversize := maxBytes > 0 && total > maxBytes
+		if !expired && !oversize {
+			continue
+		}
+		ok, err := c.remove(e.Key)
+		if err != nil {
+			return removed, err
+		}
+		if !ok {
+			continue
+		}
+		total -= e.Size
+		removed = append(removed, e)
+	}
+	return removed, nil
+}
+
+// Clear removes every entry that is not leased and any file in the cache
+// directory that belongs to no entry. It returns the number of entries
+// removed.
+func (c *Cache) Clear() (int, error) {
+	entries, err := c.List()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	kept := make(map[string]bool)
+	for _, e := range entries {
+		ok, err := c.remove(e.Key)
+		if err != nil {
+			return n, err
+		}
+		if ok {
+			n++
+			continue
+		}
+		kept[filepath.Base(c.dataPath(e.Key))] = true
+		kept[filepath.Base(c.metaPath(e.Key))] = true
+	}
+
+	files, err := os.ReadDir(c.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return n, nil
+	}
+	if err != nil {
+		return n, fmt.Errorf("read cache: %w", err)
+	}
+	for _, f := range files {
+		if kept[f.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.dir, f.Name())); err != nil {
+			return n, fmt.Errorf("clear cache: %w", err)
+		}
+	}
+	return n, nil
+}
+
+func (c *Cache) readEntry(metaPath string) (Entry, error) {
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return Entry{}, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, err
+	}
+
+	entry.Path = c.dataPath(entry.Key)
+	info, err := os.Stat(entry.Path)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.Size = info.Size()
+	entry.LastUsed = info.ModTime()
+	return entry, nil
+}
+
+// remove deletes the entry of key unless it is leased, reporting whether it
+// was removed. The exclusive lock is held while removing so that a
+// concurrent Acquire sees the entry gone.
+func (c *Cache) remove(key string) (bool, error) {
+	f, err := os.Open(c.dataPath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("open cache entry: %w", err)
+	}
+	if f != nil {
+		defer f.Close()
+		ok, err := tryLockExclusive(f)
+		if err != nil {
+			return false, fmt.Errorf("lock cache entry: %w", err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+
+	// Drop the sidecar first so a failure never leaves an entry without data.
+	if err := os.Remove(c.metaPath(key)); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("remove cache entry: %w", err)
+	}
+	if err := os.Remove(c.dataPath(key)); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("remove cache entry: %w", err)
+	}
+	return true, nil
+}
+
+func (c *Cache) dataPath(key string) string {
+	return filepath.Join(c.dir, key+dataExt)
+}
+
+func (c *Cache) metaPath(key string) string {
+	return filepath.Join(c.dir, key+metaExt)
+}
+
+// moveFile renames src to dst, copying when they are on different devices.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreAndLookup(t *testing.T) {
+	c := openTestCache(t)
+
+	if _, ok := c.Lookup("missing"); ok {
+		t.Fatalf("expected miss for unknown key")
+	}
+
+	src := writeFile(t, t.TempDir(), "byte.tif", 10)
+	path, err := c.Store("k1", src, "/data/scene.tif")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Fatalf("expected source moved into cache, got %v", err)
+	}
+
+	got, ok := c.Lookup("k1")
+	if !ok || got != path {
+		t.Fatalf("expected hit at %q, got %q (%v)", path, got, ok)
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "k1" || entries[0].Source != "/data/scene.tif" || entries[0].Size != 10 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+}
+
+func TestPruneBySizeRemovesLeastRecentlyUsed(t *testing.T) {
+	c := openTestCache(t)
+	now := time.Now()
+	for i, key := range []string{"old", "mid", "new"} {
+		storeAt(t, c, key, 100, now.Add(time.Duration(i-3)*time.Hour))
+	}
+
+	removed, err := c.Prune(250, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(removed) != 1 || removed[0].Key != "old" {
+		t.Fatalf("expected only old removed, got %+v", removed)
+	}
+	if _, ok := c.Lookup("mid"); !ok {
+		t.Fatalf("expected mid kept")
+	}
+}
+
+func TestPruneByAge(t *testing.T) {
+	c := openTestCache(t)
+	storeAt(t, c, "stale", 1, time.Now().Add(-48*time.Hour))
+	storeAt(t, c, "fresh", 1, time.Now())
+
+	removed, err := c.Prune(0, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(removed) != 1 || removed[0].Key != "stale" {
+		t.Fatalf("expected stale removed, got %+v", removed)
+	}
+}
+
+func TestClear(t *testing.T) {
+	c := openTestCache(t)
+	storeAt(t, c, "a", 1, time.Now())
+	storeAt(t, c, "b", 1, time.Now())
+	writeFile(t, c.Dir(), "leftover.partial", 1)
+
+	n, err := c.Clear()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 entries cleared, got %d", n)
+	}
+	files, _ := os.ReadDir(c.Dir())
+	if len(files) != 0 {
+		t.Fatalf("expected empty cache dir, got %d files", len(files))
+	}
+}
+
+func TestLeasedEntrySurvivesPruneAndClear(t *testing.T) {
+	c := openTestCache(t)
+	storeAt(t, c, "held", 100, time.Now().Add(-48*time.Hour))
+	storeAt(t, c, "free", 100, time.Now().Add(-48*time.Hour))
+
+	lease, err := c.Acquire("held")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	removed, err := c.Prune(1, time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(removed) != 1 || removed[0].Key != "free" {
+		t.Fatalf("expected only free removed, got %+v", removed)
+	}
+	if n, err := c.Clear(); err != nil || n != 0 {
+		t.Fatalf("expected nothing cleared, got %d (%v)", n, err)
+	}
+	if _, ok := c.Lookup("held"); !ok {
+		t.Fatalf("expected leased entry kept")
+	}
+
+	if err := lease.Release(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if n, err := c.Clear(); err != nil || n != 1 {
+		t.Fatalf("expected released entry cleared, got %d (%v)", n, err)
+	}
+	if _, err := c.Acquire("held"); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("expected ErrNotCached, got %v", err)
+	}
+}
+
+func TestMissingDirIsNotCreated(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := Open(dir, false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	entries, err := c.List()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected no entries, got %+v (%v)", entries, err)
+	}
+	if _, err := c.Prune(1, time.Hour); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if n, err := c.Clear(); err != nil || n != 0 {
+		t.Fatalf("expected nothing cleared, got %d (%v)", n, err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected %s not created, got %v", dir, err)
+	}
+}
+
+func TestFingerprintTracksChanges(t *testing.T) {
+	for _, hash := range []bool{false, true} {
+		c, err := Open(t.TempDir(), hash)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		path := writeFile(t, t.TempDir(), "in.tif", 4)
+
+		first, err := c.Fingerprint(path)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		again, _ := c.Fingerprint(path)
+		if first != again {
+			t.Fatalf("expected stable fingerprint, got %q and %q", first, again)
+		}
+
+		if err := os.WriteFile(path, []byte("other contents"), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		changed, _ := c.Fingerprint(path)
+		if changed == first {
+			t.Fatalf("hash=%v: expected fingerprint to change with contents", hash)
+		}
+	}
+}
+
+func TestKeyDependsOnEveryPart(t *testing.T) {
+	if Key("a", "bc") == Key("ab", "c") {
+		t.Fatalf("expected part boundaries to matter")
+	}
+	if Key("a") != Key("a") {
+		t.Fatalf("expected stable key")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"":      0,
+		"0":     0,
+		"512":   512,
+		"2KB":   2048,
+		"1.5M":  1536 * 1024,
+		"20gb":  20 << 30,
+		" 1 T ": 1 << 40,
+	}
+	for in, want := range cases {
+		got, err := ParseSize(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseSize(%q): expected %d, got %d (%v)", in, want, got, err)
+		}
+	}
+	for _, in := range []string{"lots", "-1GB", "GB"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Fatalf("ParseSize(%q): expected error", in)
+		}
+	}
+	if got := FormatSize(1536); got != "1.5KB" {
+		t.Fatalf("expected 1.5KB, got %q", got)
+	}
+}
+
+func openTestCache(t *testing.T) *Cache {
+	t.Helper()
+	c, err := Open(filepath.Join(t.TempDir(), "cache"), false)
+	if err != nil {
+		t.Fatalf("open cache: %v", err)
+	}
+	return c
+}
+
+func storeAt(t *testing.T, c *Cache, key string, size int, used time.Time) {
+	t.Helper()
+	path, err := c.Store(key, writeFile(t, t.TempDir(), key+".tif", size), key)
+	if err != nil {
+		t.Fatalf("store %s: %v", key, err)
+	}
+	if err := os.Chtimes(path, used, used); err != nil {
+		t.Fatalf("set times: %v", err)
+	}
+}
+
+func writeFile(t *testing.T, dir, name string, size int) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	return path
+}
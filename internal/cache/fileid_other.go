@@ -0,0 +1,9 @@
+//go:build !unix
+
+package cache
+
+import "os"
+
+func fileID(os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,15 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package cache
+
+import "os"
+
+// Without flock, leases do not protect entries from concurrent pruning.
+
+func lockShared(*os.File) error {
+	return nil
+}
+
+func tryLockExclusive(*os.File) (bool, error) {
+	return true, nil
+}
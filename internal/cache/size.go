@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a byte size such as "512MB", "20G" or "1048576". Units
+// are binary; an empty string or "0" means no limit.
+func ParseSize(input string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(input))
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", input)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatSize renders a byte count with a binary unit.
+func FormatSize(n int64) string {
+	for _, unit := range sizeUnits[:4] {
+		if n >= unit.bytes {
+			return fmt.Sprintf("%.1f%s", float64(n)/float64(unit.bytes), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", n)
+}
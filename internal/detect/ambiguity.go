@@ -0,0 +1,193 @@
+package detect
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// AmbiguityAction selects what happens to candidates flagged as ghosts.
+type AmbiguityAction string
+
+const (
+	AmbiguityOff    AmbiguityAction = ""
+	AmbiguityMark   AmbiguityAction = "mark"
+	AmbiguityRemove AmbiguityAction = "remove"
+)
+
+// Reasons recorded on flagged candidates.
+const (
+	ReasonAzimuthAmbiguity = "azimuth_ambiguity"
+	ReasonSidelobe         = "sidelobe"
+)
+
+const (
+	defaultAmbiguityOrders    = 1
+	defaultAmbiguityTolerance = 200.0
+	defaultSidelobeAngleDeg   = 5.0
+	// Sentinel-1 C-band defaults used when the geometry leaves them unset.
+	defaultWavelengthM = 0.05546576
+	defaultVelocityMps = 7590.0
+)
+
+// SARGeometry describes the acquisition needed to predict azimuth ghosts.
+type SARGeometry struct {
+	// HeadingDeg is the platform heading, clockwise from north.
+	HeadingDeg  float64
+	PRFHz       float64
+	SlantRangeM float64
+	VelocityMps float64
+	WavelengthM float64
+}
+
+// AzimuthDisplacementM returns the along-track offset of the first azimuth
+// ambiguity, lambda * R * PRF / (2 * V).
+func (g SARGeometry) AzimuthDisplacementM() float64 {
+	wavelength := g.WavelengthM
+	if wavelength == 0 {
+		wavelength = defaultWavelengthM
+	}
+	velocity := g.VelocityMps
+	if velocity == 0 {
+		velocity = defaultVelocityMps
+	}
+	if g.PRFHz <= 0 || g.SlantRangeM <= 0 {
+		return 0
+	}
+	return wavelength * g.SlantRangeM * g.PRFHz / (2 * velocity)
+}
+
+// AmbiguityConfig configures the azimuth ambiguity and sidelobe filter.
+type AmbiguityConfig struct {
+	Action   AmbiguityAction
+	Geometry SARGeometry
+	// Orders is the number of ambiguity orders checked on each side.
+	Orders int
+	// ToleranceM is the allowed distance between a candidate and a predicted ghost.
+	ToleranceM float64
+	// SidelobeRadiusM flags weaker candidates on the azimuth or range axis of a
+	// stronger one within this radius. Zero disables the sidelobe check.
+	SidelobeRadiusM float64
+	// SidelobeAngleDeg is the angular tolerance around each axis.
+	SidelobeAngleDeg float64
+}
+
+// ParseAmbiguityAction resolves an action name as accepted on the command line.
+func ParseAmbiguityAction(name string) (AmbiguityAction, error) {
+	switch action := AmbiguityAction(strings.ToLower(strings.TrimSpace(name))); action {
+	case AmbiguityOff, "none":
+		return AmbiguityOff, nil
+	case AmbiguityMark, AmbiguityRemove:
+		return action, nil
+	default:
+		return AmbiguityOff, fmt.Errorf("unknown ambiguity action %q", name)
+	}
+}
+
+// FilterAmbiguities flags candidates that are predicted azimuth ambiguities or
+// sidelobes of a stronger candidate, then marks or removes them per cfg.
+// Only unflagged candidates act as sources, so ghosts do not spawn ghosts.
+func FilterAmbiguities(candidates []Candidate, cfg AmbiguityConfig) []Candidate {
+	if cfg.Action == AmbiguityOff || len(candidates) < 2 {
+		return candidates
+	}
+	cfg = normalizeAmbiguityConfig(cfg)
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return strongerCandidate(candidates[order[i]], candidates[order[j]])
+	})
+
+	heading := cfg.Geometry.HeadingDeg * math.Pi / 180
+	azimuth := [2]float64{math.Sin(heading), math.Cos(heading)}
+	rangeAxis := [2]float64{math.Cos(heading), -math.Sin(heading)}
+	displacement := cfg.Geometry.AzimuthDisplacementM()
+	sidelobeSin := math.Sin(cfg.SidelobeAngleDeg * math.Pi / 180)
+
+	out := append([]Candidate(nil), candidates...)
+	sources := make([]int, 0, len(candidates))
+	for _, i := range order {
+		c := out[i]
+		for _, s := range sources {
+			east, north := localOffsetMetres(out[s], c)
+			along := east*azimuth[0] + north*azimuth[1]
+			across := east*rangeAxis[0] + north*rangeAxis[1]
+
+			if isAzimuthGhost(along, across, displacement, cfg) {
+				c.Reason = ReasonAzimuthAmbiguity
+				break
+			}
+			if isSidelobe(along, across, sidelobeSin, cfg.SidelobeRadiusM) {
+				c.Reason = ReasonSidelobe
+				break
+			}
+		}
+		out[i] = c
+		if c.Reason == "" {
+			sources = append(sources, i)
+		}
+	}
+
+	if cfg.Action == AmbiguityMark {
+		return out
+	}
+
+	kept := make([]Candidate, 0, len(out))
+	for _, c := range out {
+		if c.Reason == "" {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+func normalizeAmbiguityConfig(cfg AmbiguityConfig) AmbiguityConfig {
+	if cfg.Orders <= 0 {
+		cfg.Orders = defaultAmbiguityOrders
+	}
+	if cfg.ToleranceM <= 0 {
+		cfg.ToleranceM = defaultAmbiguityTolerance
+	}
+	if cfg.SidelobeAngleDeg <= 0 {
+		cfg.SidelobeAngleDeg = defaultSidelobeAngleDeg
+	}
+	return cfg
+}
+
+func isAzimuthGhost(along, across, displacement float64, cfg AmbiguityConfig) bool {
+	if displacement <= 0 {
+		return false
+	}
+	for k := 1; k <= cfg.Orders; k++ {
+		shift := float64(k) * displacement
+		if math.Hypot(math.Abs(along)-shift, across) <= cfg.ToleranceM {
+			return true
+		}
+	}
+	return false
+}
+
+func isSidelobe(along, across, sinTolerance, radius float64) bool {
+	if radius <= 0 {
+		return false
+	}
+	dist := math.Hypot(along, across)
+	if dist == 0 || dist > radius {
+		return false
+	}
+	return math.Min(math.Abs(along), math.Abs(across)) <= dist*sinTolerance
+}
+
+// localOffsetMetres returns the east/north offset of b from a on a local
+// equirectangular approximation, accurate over ambiguity distances.
+func localOffsetMetres(a, b Candidate) (east, north float64) {
+	metresPerDeg := earthRadiusM * math.Pi / 180
+	lat := (a.Lat + b.Lat) / 2 * math.Pi / 180
+	east = (b.Lon - a.Lon) * metresPerDeg * math.Cos(lat)
+	north = (b.Lat - a.Lat) * metresPerDeg
+	return east, north
+}
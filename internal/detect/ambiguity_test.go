@@ -0,0 +1,118 @@
+package detect
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+const metresPerDegree = earthRadiusM * math.Pi / 180
+
+// offsetCandidate moves c by along/across metres relative to a platform
+// heading of headingDeg.
+func offsetCandidate(c Candidate, headingDeg, along, across float64, score float64) Candidate {
+	h := headingDeg * math.Pi / 180
+	east := along*math.Sin(h) + across*math.Cos(h)
+	north := along*math.Cos(h) - across*math.Sin(h)
+	return Candidate{
+		Lon:    c.Lon + east/(metresPerDegree*math.Cos(c.Lat*math.Pi/180)),
+		Lat:    c.Lat + north/metresPerDegree,
+		Score:  score,
+		AreaPx: 4,
+	}
+}
+
+func TestSARGeometryAzimuthDisplacement(t *testing.T) {
+	g := SARGeometry{PRFHz: 1717, SlantRangeM: 850000, VelocityMps: 7590, WavelengthM: 0.0555}
+	want := 0.0555 * 850000 * 1717 / (2 * 7590)
+	assertFloatClose(t, g.AzimuthDisplacementM(), want)
+
+	if got := (SARGeometry{SlantRangeM: 850000}).AzimuthDisplacementM(); got != 0 {
+		t.Fatalf("expected no displacement without PRF, got %v", got)
+	}
+}
+
+func TestFilterAmbiguitiesFlagsAzimuthGhosts(t *testing.T) {
+	const heading = -167.0
+	geometry := SARGeometry{HeadingDeg: heading, PRFHz: 1717, SlantRangeM: 850000}
+	shift := geometry.AzimuthDisplacementM()
+
+	ship := Candidate{Lon: 103.9, Lat: 1.2, Score: 250, AreaPx: 30}
+	candidates := []Candidate{
+		offsetCandidate(ship, heading, shift+50, -40, 60),
+		ship,
+		offsetCandidate(ship, heading, -shift, 20, 50),
+		offsetCandidate(ship, heading, shift, 1500, 40),
+		offsetCandidate(ship, heading, 2*shift, 0, 30),
+	}
+
+	got := FilterAmbiguities(candidates, AmbiguityConfig{Action: AmbiguityMark, Geometry: geometry})
+	wantReasons := []string{ReasonAzimuthAmbiguity, "", ReasonAzimuthAmbiguity, "", ""}
+	assertReasons(t, got, wantReasons)
+
+	got = FilterAmbiguities(candidates, AmbiguityConfig{Action: AmbiguityMark, Geometry: geometry, Orders: 2})
+	wantReasons[4] = ReasonAzimuthAmbiguity
+	assertReasons(t, got, wantReasons)
+
+	removed := FilterAmbiguities(candidates, AmbiguityConfig{Action: AmbiguityRemove, Geometry: geometry})
+	if len(removed) != 3 || !reflect.DeepEqual(removed[0], ship) {
+		t.Fatalf("expected ghosts removed, got %+v", removed)
+	}
+
+	if got := FilterAmbiguities(candidates, AmbiguityConfig{Geometry: geometry}); got[0].Reason != "" {
+		t.Fatalf("expected filter to be off by default")
+	}
+}
+
+func TestFilterAmbiguitiesGhostOfWeakerIsKept(t *testing.T) {
+	const heading = 10.0
+	geometry := SARGeometry{HeadingDeg: heading, PRFHz: 1500, SlantRangeM: 900000}
+	weak := Candidate{Lon: 103.9, Lat: 1.2, Score: 20, AreaPx: 3}
+	strong := offsetCandidate(weak, heading, geometry.AzimuthDisplacementM(), 0, 200)
+
+	got := FilterAmbiguities([]Candidate{weak, strong}, AmbiguityConfig{Action: AmbiguityMark, Geometry: geometry})
+	assertReasons(t, got, []string{ReasonAzimuthAmbiguity, ""})
+}
+
+func TestFilterAmbiguitiesFlagsSidelobes(t *testing.T) {
+	const heading = 30.0
+	ship := Candidate{Lon: 103.9, Lat: 1.2, Score: 250, AreaPx: 30}
+	candidates := []Candidate{
+		ship,
+		offsetCandidate(ship, heading, 150, 3, 80),
+		offsetCandidate(ship, heading, 0, -200, 70),
+		offsetCandidate(ship, heading, 150, 150, 60),
+		offsetCandidate(ship, heading, 600, 0, 50),
+	}
+
+	got := FilterAmbiguities(candidates, AmbiguityConfig{
+		Action:          AmbiguityMark,
+		Geometry:        SARGeometry{HeadingDeg: heading},
+		SidelobeRadiusM: 300,
+	})
+	assertReasons(t, got, []string{"", ReasonSidelobe, ReasonSidelobe, "", ""})
+}
+
+func TestParseAmbiguityAction(t *testing.T) {
+	if got, err := ParseAmbiguityAction("Remove"); err != nil || got != AmbiguityRemove {
+		t.Fatalf("expected remove, got %q (%v)", got, err)
+	}
+	if got, err := ParseAmbiguityAction("none"); err != nil || got != AmbiguityOff {
+		t.Fatalf("expected off, got %q (%v)", got, err)
+	}
+	if _, err := ParseAmbiguityAction("drop"); err == nil {
+		t.Fatalf("expected error for unknown action")
+	}
+}
+
+func assertReasons(t *testing.T, got []Candidate, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d candidates, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].Reason != want[i] {
+			t.Fatalf("candidate %d: expected reason %q, got %q", i, want[i], got[i].Reason)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package detect
+
+import (
+	"math"
+	"sort"
+)
+
+// ClusterConfig configures the post-labeling candidate merge and suppression
+// steps. Zero distances disable the corresponding step.
+type ClusterConfig struct {
+	// MergeDistanceM merges components whose footprints are within this many
+	// metres of each other, e.g. a hull and its wake or sidelobes.
+	MergeDistanceM float64
+	// NMSRadiusM keeps only the strongest candidate within this radius.
+	NMSRadiusM float64
+}
+
+// MergeComponents merges components whose bounding-box footprints lie within
+// distanceM metres of each other, transitively. Area, sum, centroid and
+// footprint of merged components are recomputed from their parts.
+func MergeComponents(components []Component, gt [6]float64, distanceM float64) []Component {
+	if distanceM <= 0 || len(components) < 2 {
+		return components
+	}
+
+	order := make([]int, len(components))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return components[order[i]].MinX < components[order[j]].MinX
+	})
+
+	parent := make([]int, len(components))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	for oi, i := range order {
+		a := components[i]
+		pxW, pxH := PixelSizeMetres(gt, a.Cx, a.Cy)
+		reachX := int(math.Ceil(distanceM / pxW))
+		for _, j := range order[oi+1:] {
+			b := components[j]
+			if b.MinX-a.MaxX-1 > reachX {
+				break
+			}
+			if footprintGapMetres(a, b, pxW, pxH) <= distanceM {
+				union(parent, i, j)
+			}
+		}
+	}
+
+	merged := make([]Component, 0, len(components))
+	slot := make(map[int]int, len(components))
+	for i, component := range components {
+		root := find(parent, i)
+		idx, ok := slot[root]
+		if !ok {
+			slot[root] = len(merged)
+			merged = append(merged, component)
+			continue
+		}
+		merged[idx] = mergeComponent(merged[idx], component)
+	}
+
+	return merged
+}
+
+func footprintGapMetres(a, b Component, pxW, pxH float64) float64 {
+	gapX := max(0, b.MinX-a.MaxX-1, a.MinX-b.MaxX-1)
+	gapY := max(0, b.MinY-a.MaxY-1, a.MinY-b.MaxY-1)
+	return math.Hypot(float64(gapX)*pxW, float64(gapY)*pxH)
+}
+
+func mergeComponent(a, b Component) Component {
+	area := a.Area + b.Area
+	return Component{
+		Area:  area,
+		Sum:   a.Sum + b.Sum,
+		Cx:    (a.Cx*float64(a.Area) + b.Cx*float64(b.Area)) / float64(area),
+		Cy:    (a.Cy*float64(a.Area) + b.Cy*float64(b.Area)) / float64(area),
+		MinX:  min(a.MinX, b.MinX),
+		MinY:  min(a.MinY, b.MinY),
+		MaxX:  max(a.MaxX, b.MaxX),
+		MaxY:  max(a.MaxY, b.MaxY),
+		SumXX: a.SumXX + b.SumXX,
+		SumYY: a.SumYY + b.SumYY,
+		SumXY: a.SumXY + b.SumXY,
+		Seeds: append(append([]int(nil), a.Seeds...), b.Seeds...),
+	}
+}
+
+func find(parent []int, i int) int {
+	for parent[i] != i {
+		parent[i] = parent[parent[i]]
+		i = parent[i]
+	}
+	return i
+}
+
+func union(parent []int, a, b int) {
+	ra, rb := find(parent, a), find(parent, b)
+	if ra == rb {
+		return
+	}
+	if ra < rb {
+		parent[rb] = ra
+		return
+	}
+	parent[ra] = rb
+}
+
+// SuppressNonMaxima keeps a candidate only if no stronger candidate lies
+// within radiusM metres. Strength follows the output ranking: higher score,
+// then larger area.
+func SuppressNonMaxima(candidates []Candidate, radiusM float64) []Candidate {
+	if radiusM <= 0 || len(candidates) < 2 {
+		return candidates
+	}
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return strongerCandidate(candidates[order[i]], candidates[order[j]])
+	})
+
+	kept := make([]bool, len(candidates))
+	keptIdx := make([]int, 0, len(candidates))
+	for _, i := range order {
+		c := candidates[i]
+		suppressed := false
+		for _, k := range keptIdx {
+			if DistanceMetres(c.Lon, c.Lat, candidates[k].Lon, candidates[k].Lat) <= radiusM {
+				suppressed = true
+				break
+			}
+		}
+		if !suppressed {
+			kept[i] = true
+			keptIdx = append(keptIdx, i)
+		}
+	}
+
+	out := make([]Candidate, 0, len(keptIdx))
+	for i, c := range candidates {
+		if kept[i] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func strongerCandidate(a, b Candidate) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.AreaPx > b.AreaPx
+}
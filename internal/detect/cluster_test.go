@@ -0,0 +1,91 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+)
+
+// clusterGT is a lon/lat geotransform at the equator with ~10 m pixels.
+var clusterGT = [6]float64{103, 10.0 / 111195, 0, 0, 0, -10.0 / 111195}
+
+func TestMergeComponentsJoinsNearbyFootprints(t *testing.T) {
+	grid := gridFromRows(
+		"##...#.........",
+		"##...#.......##",
+		".............##",
+	)
+	components := Components(grid, 1, false, 1)
+	if len(components) != 3 {
+		t.Fatalf("expected 3 components, got %d", len(components))
+	}
+
+	// The hull and its sidelobe are 3 px (~30 m) apart, the third blob 7 px.
+	got := MergeComponents(components, clusterGT, 35)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 merged components, got %d", len(got))
+	}
+
+	ship := got[0]
+	if ship.Area != 6 {
+		t.Fatalf("expected merged area 6, got %d", ship.Area)
+	}
+	assertFloatClose(t, ship.Sum, 6)
+	assertFloatClose(t, ship.Cx, (0+1+0+1+5+5)/6.0)
+	assertFloatClose(t, ship.Cy, (0+0+1+1+0+1)/6.0)
+	if ship.MinX != 0 || ship.MinY != 0 || ship.MaxX != 5 || ship.MaxY != 1 {
+		t.Fatalf("unexpected merged footprint: %+v", ship)
+	}
+
+	if got := MergeComponents(components, clusterGT, 25); len(got) != 3 {
+		t.Fatalf("expected no merge below the gap, got %d", len(got))
+	}
+	if got := MergeComponents(components, clusterGT, 100); len(got) != 1 {
+		t.Fatalf("expected transitive merge into 1, got %d", len(got))
+	}
+	if got := MergeComponents(components, clusterGT, 0); len(got) != 3 {
+		t.Fatalf("expected zero distance to disable merging, got %d", len(got))
+	}
+}
+
+func TestMergeComponentsDiagonalGap(t *testing.T) {
+	grid := gridFromRows(
+		"#....",
+		".....",
+		".....",
+		"....#",
+	)
+	components := Components(grid, 1, false, 1)
+
+	// A gap of 3 px by 2 px is ~36 m on the diagonal.
+	if got := MergeComponents(components, clusterGT, 35); len(got) != 2 {
+		t.Fatalf("expected no merge at 35 m, got %d", len(got))
+	}
+	if got := MergeComponents(components, clusterGT, 37); len(got) != 1 {
+		t.Fatalf("expected merge at 37 m, got %d", len(got))
+	}
+}
+
+func TestSuppressNonMaxima(t *testing.T) {
+	const deg100m = 100.0 / 111195
+	candidates := []Candidate{
+		{Lon: 103, Lat: 1, Score: 10, AreaPx: 5},
+		{Lon: 103, Lat: 1 + 0.5*deg100m, Score: 40, AreaPx: 5},
+		{Lon: 103, Lat: 1 + 1.2*deg100m, Score: 20, AreaPx: 9},
+		{Lon: 103, Lat: 1 + 5*deg100m, Score: 5, AreaPx: 2},
+	}
+
+	got := SuppressNonMaxima(candidates, 100)
+	want := []Candidate{candidates[1], candidates[3]}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d candidates, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Fatalf("candidate %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	if got := SuppressNonMaxima(candidates, 0); len(got) != len(candidates) {
+		t.Fatalf("expected zero radius to keep all, got %d", len(got))
+	}
+}
@@ -1,80 +1,79 @@
 package detect
 
-import (
-	"math"
-
-	"boatdetect/internal/gdal"
-)
+import "boatdetect/internal/gdal"
 
 // Component describes a connected component on a thresholded grid.
+// MinX/MinY/MaxX/MaxY is the inclusive pixel bounding box of its footprint.
 type Component struct {
 	Area int
 	Sum  float64
 	Cx   float64
 	Cy   float64
+	MinX int
+	MinY int
+	MaxX int
+	MaxY int
+	// SumXX, SumYY and SumXY are raw second moments of the pixel
+	// coordinates, from which ComponentShape derives the shape.
+	SumXX float64
+	SumYY float64
+	SumXY float64
+	// Seeds holds the raster index of the first pixel of each 4-connected
+	// part; merged components have one seed per part.
+	Seeds []int
 }
 
 // Components extracts 4-neighborhood connected components from a thresholded grid.
 func Components(grid gdal.Grid, threshold float64, invert bool, minAreaPx int) []Component {
+	return MaskComponents(grid, ThresholdMask(grid, threshold, invert), minAreaPx)
+}
+
+// MaskComponents extracts 4-neighborhood connected components from a binary
+// mask, accumulating pixel values from grid.
+func MaskComponents(grid gdal.Grid, mask []bool, minAreaPx int) []Component {
 	if grid.Width <= 0 || grid.Height <= 0 {
 		return nil
 	}
 
 	expected := grid.Width * grid.Height
-	if len(grid.Data) < expected {
+	if len(grid.Data) < expected || len(mask) < expected {
 		return nil
 	}
 
 	visited := make([]bool, expected)
-	checkNoData := !math.IsNaN(grid.NoData)
 	components := make([]Component, 0)
 
-	qualifies := func(v float64) bool {
-		if math.IsNaN(v) {
-			return false
-		}
-		if checkNoData && v == grid.NoData {
-			return false
-		}
-		if invert {
-			return v <= threshold
-		}
-		return v >= threshold
-	}
-
 	for idx := 0; idx < expected; idx++ {
 		if visited[idx] {
 			continue
 		}
-		v := grid.Data[idx]
-		if !qualifies(v) {
+		if !mask[idx] {
 			visited[idx] = true
 			continue
 		}
 
-		component := floodFillComponent(grid, idx, visited, qualifies)
+		component := floodFillComponent(grid, mask, idx, visited)
 		area := component.Area
 
 		if area == 0 || area < minAreaPx {
 			continue
 		}
 
-		components = append(components, Component{
-			Area: area,
-			Sum:  component.Sum,
-			Cx:   component.Cx,
-			Cy:   component.Cy,
-		})
+		components = append(components, component)
 	}
 
 	return components
 }
 
-func floodFillComponent(grid gdal.Grid, startIdx int, visited []bool, qualifies func(float64) bool) Component {
+func floodFillComponent(grid gdal.Grid, mask []bool, startIdx int, visited []bool) Component {
 	area := 0
 	sum := 0.0
 	sumX := 0.0
 	sumY := 0.0
+	sumXX, sumYY, sumXY := 0.0, 0.0, 0.0
+
+	minX, minY := grid.Width, grid.Height
+	maxX, maxY := -1, -1
 
 	stack := []int{startIdx}
 	visited[startIdx] = true
@@ -84,8 +83,7 @@ func floodFillComponent(grid gdal.Grid, startIdx int, visited []bool, qualifies
 		cur := stack[n]
 		stack = stack[:n]
 
-		cv := grid.Data[cur]
-		if !qualifies(cv) {
+		if !mask[cur] {
 			continue
 		}
 
@@ -93,9 +91,14 @@ func floodFillComponent(grid gdal.Grid, startIdx int, visited []bool, qualifies
 		y := cur / grid.Width
 
 		area++
-		sum += cv
+		sum += grid.Data[cur]
 		sumX += float64(x)
 		sumY += float64(y)
+		sumXX += float64(x * x)
+		sumYY += float64(y * y)
+		sumXY += float64(x * y)
+		minX, minY = min(minX, x), min(minY, y)
+		maxX, maxY = max(maxX, x), max(maxY, y)
 
 		stack = addNeighbors(cur, x, y, grid.Width, grid.Height, visited, stack)
 	}
@@ -105,10 +108,18 @@ func floodFillComponent(grid gdal.Grid, startIdx int, visited []bool, qualifies
 	}
 
 	return Component{
-		Area: area,
-		Sum:  sum,
-		Cx:   sumX / float64(area),
-		Cy:   sumY / float64(area),
+		Area:  area,
+		Sum:   sum,
+		Cx:    sumX / float64(area),
+		Cy:    sumY / float64(area),
+		SumXX: sumXX,
+		SumYY: sumYY,
+		SumXY: sumXY,
+		MinX:  minX,
+		MinY:  minY,
+		MaxX:  maxX,
+		MaxY:  maxY,
+		Seeds: []int{startIdx},
 	}
 }
 
@@ -0,0 +1,375 @@
+package detect
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FootprintKind names the footprint geometry traced for each candidate.
+type FootprintKind string
+
+const (
+	FootprintNone FootprintKind = ""
+	// FootprintOutline traces the pixel boundary, including holes.
+	FootprintOutline FootprintKind = "outline"
+	// FootprintRectangle is the oriented minimum-area bounding rectangle.
+	FootprintRectangle FootprintKind = "rectangle"
+	// FootprintHull is the convex hull of the pixels.
+	FootprintHull FootprintKind = "hull"
+)
+
+// Polygon is a list of closed lon/lat rings: a counter-clockwise exterior
+// followed by clockwise holes, as in RFC 7946.
+type Polygon [][][2]float64
+
+// ParseFootprintKind resolves a footprint name as accepted on the command line.
+func ParseFootprintKind(name string) (FootprintKind, error) {
+	switch kind := FootprintKind(strings.ToLower(strings.TrimSpace(name))); kind {
+	case FootprintNone, "none":
+		return FootprintNone, nil
+	case FootprintOutline, FootprintRectangle, FootprintHull:
+		return kind, nil
+	default:
+		return FootprintNone, fmt.Errorf("unknown footprint %q", name)
+	}
+}
+
+// pixelSet is the membership of a component's pixels within its bounding box.
+type pixelSet struct {
+	x0, y0 int
+	w, h   int
+	in     []bool
+}
+
+func (s pixelSet) has(x, y int) bool {
+	return x >= 0 && y >= 0 && x < s.w && y < s.h && s.in[y*s.w+x]
+}
+
+// ComponentFootprint returns the footprint of c in lon/lat. mask is the
+// labeling mask of a width-wide grid. Pixel x spans x-0.5 to x+0.5 so that
+// the footprint is centred on the same coordinates as the candidate point.
+// Merged components yield one outline polygon per part.
+func ComponentFootprint(mask []bool, width int, c Component, gt [6]float64, kind FootprintKind) []Polygon {
+	if kind == FootprintNone || c.Area == 0 || len(c.Seeds) == 0 {
+		return nil
+	}
+
+	set := componentPixels(mask, width, c)
+	toLonLat := func(x, y float64) [2]float64 {
+		lon, lat := PixelToLonLat(gt, float64(set.x0)+x-0.5, float64(set.y0)+y-0.5)
+		return [2]float64{lon, lat}
+	}
+
+	switch kind {
+	case FootprintOutline:
+		return outlinePolygons(set, toLonLat)
+	case FootprintHull:
+		return []Polygon{{closedRing(convexHull(pixelCorners(set)), true, toLonLat)}}
+	case FootprintRectangle:
+		pxW, pxH := PixelSizeMetres(gt, c.Cx, c.Cy)
+		return []Polygon{{closedRing(minAreaRectangle(pixelCorners(set), pxW, pxH), true, toLonLat)}}
+	}
+	return nil
+}
+
+// componentPixels flood-fills mask from the seeds of c within its bounding box.
+func componentPixels(mask []bool, width int, c Component) pixelSet {
+	set := pixelSet{x0: c.MinX, y0: c.MinY, w: c.MaxX - c.MinX + 1, h: c.MaxY - c.MinY + 1}
+	set.in = make([]bool, set.w*set.h)
+
+	stack := make([]int, 0)
+	push := func(x, y int) {
+		if x < 0 || y < 0 || x >= set.w || y >= set.h {
+			return
+		}
+		local := y*set.w + x
+		if set.in[local] || !mask[(set.y0+y)*width+set.x0+x] {
+			return
+		}
+		set.in[local] = true
+		stack = append(stack, local)
+	}
+
+	for _, seed := range c.Seeds {
+		push(seed%width-set.x0, seed/width-set.y0)
+		for len(stack) > 0 {
+			cur := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := cur%set.w, cur/set.w
+			push(x-1, y)
+			push(x+1, y)
+			push(x, y-1)
+			push(x, y+1)
+		}
+	}
+	return set
+}
+
+// Boundary edge directions on the pixel grid, with y pointing down.
+var edgeSteps = [4][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+
+// outlinePolygons traces the pixel boundary of set into polygons. Edges are
+// directed with the member pixel on their right, so exteriors run clockwise
+// on screen and holes anticlockwise; at a vertex shared by two diagonal
+// pixels the trace turns right, keeping 4-connected parts apart.
+func outlinePolygons(set pixelSet, toLonLat func(x, y float64) [2]float64) []Polygon {
+	vw := set.w + 1
+	edges := make([]bool, vw*(set.h+1)*4)
+	edge := func(x, y, dir int) int { return (y*vw+x)*4 + dir }
+	for y := 0; y < set.h; y++ {
+		for x := 0; x < set.w; x++ {
+			if !set.has(x, y) {
+				continue
+			}
+			if !set.has(x, y-1) {
+				edges[edge(x, y, 0)] = true
+			}
+			if !set.has(x+1, y) {
+				edges[edge(x+1, y, 1)] = true
+			}
+			if !set.has(x, y+1) {
+				edges[edge(x+1, y+1, 2)] = true
+			}
+			if !set.has(x-1, y) {
+				edges[edge(x, y+1, 3)] = true
+			}
+		}
+	}
+
+	var exteriors, holes [][][2]int
+	used := make([]bool, len(edges))
+	for start := range edges {
+		if !edges[start] || used[start] {
+			continue
+		}
+		ring := traceRing(edges, used, start, vw)
+		if ringArea(ring) > 0 {
+			exteriors = append(exteriors, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	// Smallest exteriors first, so each hole goes to the innermost one.
+	sort.Slice(exteriors, func(i, j int) bool { return ringArea(exteriors[i]) < ringArea(exteriors[j]) })
+	polygons := make([][][][2]int, len(exteriors))
+	for i, ext := range exteriors {
+		polygons[i] = [][][2]int{ext}
+	}
+	for _, hole := range holes {
+		// The pixel right of the first hole edge belongs to the enclosing part.
+		a, b := hole[0], hole[1]
+		dx, dy := sign(b[0]-a[0]), sign(b[1]-a[1])
+		px := float64(a[0]) + float64(dx-dy)*0.5
+		py := float64(a[1]) + float64(dy+dx)*0.5
+		for i, ext := range exteriors {
+			if pointInRing(ext, px, py) {
+				polygons[i] = append(polygons[i], hole)
+				break
+			}
+		}
+	}
+
+	result := make([]Polygon, 0, len(polygons))
+	for _, rings := range polygons {
+		polygon := make(Polygon, 0, len(rings))
+		for r, ring := range rings {
+			points := make([][2]float64, len(ring))
+			for i, v := range ring {
+				points[i] = [2]float64{float64(v[0]), float64(v[1])}
+			}
+			polygon = append(polygon, closedRing(points, r == 0, toLonLat))
+		}
+		result = append(result, polygon)
+	}
+	return result
+}
+
+// traceRing follows boundary edges from start until it returns, recording a
+// vertex at every change of direction.
+func traceRing(edges, used []bool, start, vw int) [][2]int {
+	ring := make([][2]int, 0)
+	cur := start
+	for {
+		used[cur] = true
+		v, dir := cur/4, cur%4
+		x, y := v%vw+edgeSteps[dir][0], v/vw+edgeSteps[dir][1]
+		next := -1
+		// Right turn, straight on, then left turn.
+		for _, turn := range []int{1, 0, 3} {
+			nd := (dir + turn) % 4
+			if e := (y*vw+x)*4 + nd; edges[e] {
+				next = e
+				break
+			}
+		}
+		if next%4 != dir {
+			ring = append(ring, [2]int{x, y})
+		}
+		if next == start {
+			return ring
+		}
+		cur = next
+	}
+}
+
+// ringArea returns the shoelace area of ring on the y-down pixel grid,
+// positive for rings that run clockwise on screen.
+func ringArea(ring [][2]int) float64 {
+	area := 0
+	for i, a := range ring {
+		b := ring[(i+1)%len(ring)]
+		area += a[0]*b[1] - b[0]*a[1]
+	}
+	return float64(area) / 2
+}
+
+func pointInRing(ring [][2]int, x, y float64) bool {
+	inside := false
+	for i, a := range ring {
+		b := ring[(i+1)%len(ring)]
+		ax, ay, bx, by := float64(a[0]), float64(a[1]), float64(b[0]), float64(b[1])
+		if (ay > y) != (by > y) && x < ax+(y-ay)*(bx-ax)/(by-ay) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// pixelCorners returns the outer corners of each row run of set, which
+// share the convex hull of all pixel squares.
+func pixelCorners(set pixelSet) [][2]float64 {
+	points := make([][2]float64, 0, 4*set.h)
+	for y := 0; y < set.h; y++ {
+		first, last := -1, -1
+		for x := 0; x < set.w; x++ {
+			if set.has(x, y) {
+				if first < 0 {
+					first = x
+				}
+				last = x
+			}
+		}
+		if first < 0 {
+			continue
+		}
+		points = append(points,
+			[2]float64{float64(first), float64(y)},
+			[2]float64{float64(first), float64(y + 1)},
+			[2]float64{float64(last + 1), float64(y)},
+			[2]float64{float64(last + 1), float64(y + 1)},
+		)
+	}
+	return points
+}
+
+// convexHull returns the hull of points by Andrew's monotone chain, without
+// collinear vertices.
+func convexHull(points [][2]float64) [][2]float64 {
+	sorted := append([][2]float64(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i][0] != sorted[j][0] {
+			return sorted[i][0] < sorted[j][0]
+		}
+		return sorted[i][1] < sorted[j][1]
+	})
+	if len(sorted) < 3 {
+		return sorted
+	}
+
+	hull := make([][2]float64, 0, 2*len(sorted))
+	for pass := 0; pass < 2; pass++ {
+		floor := len(hull)
+		for _, p := range sorted {
+			for len(hull) >= floor+2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		hull = hull[:len(hull)-1]
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+	}
+	return hull
+}
+
+func cross(o, a, b [2]float64) float64 {
+	return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+}
+
+// minAreaRectangle returns the corners of the smallest rectangle enclosing
+// points, measured with pixels of pxW by pxH metres. One side of the optimum
+// lies along a hull edge, so every edge direction is tried.
+func minAreaRectangle(points [][2]float64, pxW, pxH float64) [][2]float64 {
+	hull := convexHull(points)
+	for i := range hull {
+		hull[i] = [2]float64{hull[i][0] * pxW, hull[i][1] * pxH}
+	}
+
+	best := math.Inf(1)
+	var corners [][2]float64
+	for i, a := range hull {
+		b := hull[(i+1)%len(hull)]
+		length := math.Hypot(b[0]-a[0], b[1]-a[1])
+		if length == 0 {
+			continue
+		}
+		ux, uy := (b[0]-a[0])/length, (b[1]-a[1])/length
+		minU, maxU := math.Inf(1), math.Inf(-1)
+		minV, maxV := math.Inf(1), math.Inf(-1)
+		for _, p := range hull {
+			u := p[0]*ux + p[1]*uy
+			v := -p[0]*uy + p[1]*ux
+			minU, maxU = math.Min(minU, u), math.Max(maxU, u)
+			minV, maxV = math.Min(minV, v), math.Max(maxV, v)
+		}
+		if area := (maxU - minU) * (maxV - minV); area < best {
+			best = area
+			corners = [][2]float64{}
+			for _, uv := range [][2]float64{{minU, minV}, {maxU, minV}, {maxU, maxV}, {minU, maxV}} {
+				corners = append(corners, [2]float64{
+					(uv[0]*ux - uv[1]*uy) / pxW,
+					(uv[0]*uy + uv[1]*ux) / pxH,
+				})
+			}
+		}
+	}
+	return corners
+}
+
+// closedRing converts ring from local pixel corners to lon/lat, orients it
+// counter-clockwise for an exterior or clockwise for a hole, and closes it.
+func closedRing(ring [][2]float64, exterior bool, toLonLat func(x, y float64) [2]float64) [][2]float64 {
+	points := make([][2]float64, 0, len(ring)+1)
+	for _, p := range ring {
+		points = append(points, toLonLat(p[0], p[1]))
+	}
+	if len(points) == 0 {
+		return points
+	}
+
+	area := 0.0
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		area += a[0]*b[1] - b[0]*a[1]
+	}
+	if (area > 0) != exterior {
+		for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+			points[i], points[j] = points[j], points[i]
+		}
+	}
+	return append(points, points[0])
+}
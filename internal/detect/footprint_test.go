@@ -0,0 +1,161 @@
+package detect
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// footprintGT maps pixel (x, y) to lon x, lat -y, so rings can be read in
+// pixel units with y flipped.
+var footprintGT = [6]float64{0, 1, 0, 0, 0, -1}
+
+func TestParseFootprintKind(t *testing.T) {
+	for name, want := range map[string]FootprintKind{"": FootprintNone, "none": FootprintNone, "Outline": FootprintOutline, "hull": FootprintHull, "rectangle": FootprintRectangle} {
+		got, err := ParseFootprintKind(name)
+		if err != nil || got != want {
+			t.Fatalf("%q: expected %q, got %q (%v)", name, want, got, err)
+		}
+	}
+	if _, err := ParseFootprintKind("circle"); err == nil {
+		t.Fatalf("expected error for unknown footprint")
+	}
+}
+
+func TestComponentFootprintOutlineLShape(t *testing.T) {
+	got := singleFootprint(t, FootprintOutline,
+		"#..",
+		"###",
+	)
+	want := Polygon{{{-0.5, 0.5}, {-0.5, -1.5}, {2.5, -1.5}, {2.5, -0.5}, {0.5, -0.5}, {0.5, 0.5}, {-0.5, 0.5}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestComponentFootprintOutlineHole(t *testing.T) {
+	got := singleFootprint(t, FootprintOutline,
+		"###",
+		"#.#",
+		"###",
+	)
+	if len(got) != 2 {
+		t.Fatalf("expected exterior and hole, got %d rings", len(got))
+	}
+	assertRingOrientation(t, got[0], true)
+	assertRingOrientation(t, got[1], false)
+	if area := math.Abs(ringAreaLonLat(got[1])); area != 1 {
+		t.Fatalf("expected hole of area 1, got %v", area)
+	}
+}
+
+func TestComponentFootprintMergedPartsAndDiagonals(t *testing.T) {
+	grid := gridFromRows(
+		"#.",
+		".#",
+	)
+	mask := ThresholdMask(grid, 1, false)
+	components := MergeComponents(MaskComponents(grid, mask, 1), [6]float64{0, 1e-5, 0, 0, 0, -1e-5}, 10)
+	if len(components) != 1 {
+		t.Fatalf("expected merged component, got %d", len(components))
+	}
+
+	got := ComponentFootprint(mask, grid.Width, components[0], footprintGT, FootprintOutline)
+	if len(got) != 2 {
+		t.Fatalf("expected one polygon per part, got %d", len(got))
+	}
+	for _, polygon := range got {
+		if len(polygon) != 1 || len(polygon[0]) != 5 {
+			t.Fatalf("expected single-pixel square, got %v", polygon)
+		}
+	}
+}
+
+func TestComponentFootprintHull(t *testing.T) {
+	got := singleFootprint(t, FootprintHull,
+		"#..",
+		"###",
+	)
+	if len(got) != 1 {
+		t.Fatalf("expected one ring, got %d", len(got))
+	}
+	assertRingOrientation(t, got[0], true)
+	// L-shape minus the triangle cut off between its arms.
+	if area := ringAreaLonLat(got[0]); math.Abs(area-5) > 1e-9 {
+		t.Fatalf("expected hull area 5, got %v", area)
+	}
+}
+
+func TestComponentFootprintRectangleFollowsDiagonal(t *testing.T) {
+	grid := gridFromRows(
+		"##....",
+		".##...",
+		"..##..",
+		"...##.",
+		"....##",
+	)
+	mask := ThresholdMask(grid, 1, false)
+	components := MaskComponents(grid, mask, 1)
+	gt := [6]float64{0, 1e-5, 0, 0, 0, -1e-5}
+
+	got := ComponentFootprint(mask, grid.Width, components[0], gt, FootprintRectangle)
+	if len(got) != 1 || len(got[0]) != 1 || len(got[0][0]) != 5 {
+		t.Fatalf("expected one closed rectangle, got %v", got)
+	}
+	ring := got[0][0]
+	assertRingOrientation(t, ring, true)
+
+	bboxArea := 6 * 5 * 1e-10
+	if area := ringAreaLonLat(ring); area >= bboxArea*0.75 {
+		t.Fatalf("expected rotated rectangle well under bbox area %v, got %v", bboxArea, area)
+	}
+	for _, p := range ring {
+		if p[0] < -2e-5 || p[0] > 8e-5 || p[1] > 2e-5 || p[1] < -7e-5 {
+			t.Fatalf("rectangle corner %v far outside the component", p)
+		}
+	}
+}
+
+func TestComponentFootprintNone(t *testing.T) {
+	if got := singleFootprintPolygons(t, FootprintNone, "#"); got != nil {
+		t.Fatalf("expected no footprint, got %v", got)
+	}
+}
+
+func singleFootprint(t *testing.T, kind FootprintKind, rows ...string) Polygon {
+	t.Helper()
+	polygons := singleFootprintPolygons(t, kind, rows...)
+	if len(polygons) != 1 {
+		t.Fatalf("expected one polygon, got %d", len(polygons))
+	}
+	return polygons[0]
+}
+
+func singleFootprintPolygons(t *testing.T, kind FootprintKind, rows ...string) []Polygon {
+	t.Helper()
+	grid := gridFromRows(rows...)
+	mask := ThresholdMask(grid, 1, false)
+	components := MaskComponents(grid, mask, 1)
+	if len(components) != 1 {
+		t.Fatalf("expected one component, got %d", len(components))
+	}
+	return ComponentFootprint(mask, grid.Width, components[0], footprintGT, kind)
+}
+
+func assertRingOrientation(t *testing.T, ring [][2]float64, ccw bool) {
+	t.Helper()
+	if ring[0] != ring[len(ring)-1] {
+		t.Fatalf("ring not closed: %v", ring)
+	}
+	if area := ringAreaLonLat(ring); (area > 0) != ccw {
+		t.Fatalf("expected counter-clockwise %v, got signed area %v", ccw, area)
+	}
+}
+
+func ringAreaLonLat(ring [][2]float64) float64 {
+	area := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		area += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return area / 2
+}
@@ -1,5 +1,9 @@
 package detect
 
+import "math"
+
+const earthRadiusM = 6371008.8
+
 // PixelToLonLat converts pixel coordinates to lon/lat using a GDAL-style
 // affine geotransform.
 func PixelToLonLat(gt [6]float64, px, py float64) (lon, lat float64) {
@@ -7,3 +11,38 @@ func PixelToLonLat(gt [6]float64, px, py float64) (lon, lat float64) {
 	lat = gt[3] + px*gt[4] + py*gt[5]
 	return lon, lat
 }
+
+// LonLatToPixel inverts PixelToLonLat. It returns NaN for a degenerate
+// geotransform.
+func LonLatToPixel(gt [6]float64, lon, lat float64) (px, py float64) {
+	det := gt[1]*gt[5] - gt[2]*gt[4]
+	if det == 0 {
+		return math.NaN(), math.NaN()
+	}
+	dx, dy := lon-gt[0], lat-gt[3]
+	px = (dx*gt[5] - dy*gt[2]) / det
+	py = (dy*gt[1] - dx*gt[4]) / det
+	return px, py
+}
+
+// DistanceMetres returns the great-circle (haversine) distance between two
+// lon/lat points in metres.
+func DistanceMetres(lon1, lat1, lon2, lat2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// PixelSizeMetres returns the ground size of one pixel along x and y at the
+// given pixel position of a lon/lat geotransform.
+func PixelSizeMetres(gt [6]float64, px, py float64) (dx, dy float64) {
+	lon, lat := PixelToLonLat(gt, px, py)
+	lonX, latX := PixelToLonLat(gt, px+1, py)
+	lonY, latY := PixelToLonLat(gt, px, py+1)
+	return DistanceMetres(lon, lat, lonX, latX), DistanceMetres(lon, lat, lonY, latY)
+}
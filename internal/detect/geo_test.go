@@ -54,3 +54,39 @@ func TestPixelToLonLat(t *testing.T) {
 		})
 	}
 }
+
+func TestLonLatToPixelInvertsPixelToLonLat(t *testing.T) {
+	gt := [6]float64{10, 2, 0.5, 20, -1, 3}
+	lon, lat := PixelToLonLat(gt, 5, 7)
+	px, py := LonLatToPixel(gt, lon, lat)
+	if math.Abs(px-5) > pixelToGeoEps || math.Abs(py-7) > pixelToGeoEps {
+		t.Fatalf("expected pixel (5, 7), got (%v, %v)", px, py)
+	}
+	if px, _ := LonLatToPixel([6]float64{}, 1, 1); !math.IsNaN(px) {
+		t.Fatalf("expected NaN for degenerate geotransform, got %v", px)
+	}
+}
+
+func TestDistanceMetres(t *testing.T) {
+	// One degree of latitude is ~111.2 km on the mean-radius sphere.
+	got := DistanceMetres(103.8, 1.0, 103.8, 2.0)
+	if math.Abs(got-111195) > 1 {
+		t.Fatalf("expected ~111195 m, got %v", got)
+	}
+
+	if got := DistanceMetres(103.8, 1.2, 103.8, 1.2); got != 0 {
+		t.Fatalf("expected zero distance, got %v", got)
+	}
+}
+
+func TestPixelSizeMetres(t *testing.T) {
+	gt := [6]float64{0, 0.0001, 0, 60, 0, -0.0001}
+	dx, dy := PixelSizeMetres(gt, 0, 0)
+	// At 60 degrees a degree of longitude is half a degree of latitude.
+	if math.Abs(dx-dy/2) > 0.01 {
+		t.Fatalf("expected dx half of dy, got dx=%v dy=%v", dx, dy)
+	}
+	if math.Abs(dy-11.1195) > 0.001 {
+		t.Fatalf("expected dy ~11.12 m, got %v", dy)
+	}
+}
@@ -0,0 +1,258 @@
+package detect
+
+import (
+	"fmt"
+	"math"
+
+	"boatdetect/internal/gdal"
+)
+
+const (
+	defaultIncidenceBinDeg = 0.5
+	minIncidenceBinPixels  = 64
+)
+
+// IncidencePoint is a ground position with a known incidence angle.
+type IncidencePoint struct {
+	Lon      float64
+	Lat      float64
+	AngleDeg float64
+}
+
+// IncidenceConfig configures range normalization of backscatter. Angles come
+// from RasterPath when set, otherwise from a plane fitted to Points.
+type IncidenceConfig struct {
+	Enabled bool
+	Points  []IncidencePoint
+	// RasterPath is a raster of incidence angles in degrees; it is warped onto
+	// the detection grid.
+	RasterPath string
+	// BinDeg is the width of the incidence angle bins used to estimate clutter.
+	BinDeg float64
+}
+
+// IncidencePlane is a least-squares fit angle = A + B*lon + C*lat, which
+// follows the near-linear variation of incidence across a SAR swath.
+type IncidencePlane struct {
+	A float64
+	B float64
+	C float64
+}
+
+// FitIncidencePlane fits an incidence plane to tie points.
+func FitIncidencePlane(points []IncidencePoint) (IncidencePlane, error) {
+	if len(points) < 3 {
+		return IncidencePlane{}, fmt.Errorf("need at least 3 incidence points, got %d", len(points))
+	}
+
+	// Centre coordinates to keep the normal equations well conditioned.
+	lon0, lat0 := 0.0, 0.0
+	for _, p := range points {
+		lon0 += p.Lon
+		lat0 += p.Lat
+	}
+	lon0 /= float64(len(points))
+	lat0 /= float64(len(points))
+
+	var m [3][4]float64
+	for _, p := range points {
+		row := [3]float64{1, p.Lon - lon0, p.Lat - lat0}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				m[i][j] += row[i] * row[j]
+			}
+			m[i][3] += row[i] * p.AngleDeg
+		}
+	}
+
+	coef, err := solve3(m)
+	if err != nil {
+		return IncidencePlane{}, fmt.Errorf("fit incidence plane: %w", err)
+	}
+
+	return IncidencePlane{
+		A: coef[0] - coef[1]*lon0 - coef[2]*lat0,
+		B: coef[1],
+		C: coef[2],
+	}, nil
+}
+
+// Angle returns the fitted incidence angle at lon/lat.
+func (p IncidencePlane) Angle(lon, lat float64) float64 {
+	return p.A + p.B*lon + p.C*lat
+}
+
+// AngleGrid evaluates the plane at every pixel of a width x height grid.
+func (p IncidencePlane) AngleGrid(gt [6]float64, width, height int) []float64 {
+	return p.AngleWindow(gt, gdal.Window{Width: width, Height: height})
+}
+
+// AngleWindow evaluates the plane at every pixel of one window of the grid.
+func (p IncidencePlane) AngleWindow(gt [6]float64, win gdal.Window) []float64 {
+	angles := make([]float64, win.Width*win.Height)
+	for y := 0; y < win.Height; y++ {
+		for x := 0; x < win.Width; x++ {
+			lon, lat := PixelToLonLat(gt, float64(win.X+x), float64(win.Y+y))
+			angles[y*win.Width+x] = p.Angle(lon, lat)
+		}
+	}
+	return angles
+}
+
+// solve3 solves a 3x3 augmented system with partial pivoting.
+func solve3(m [3][4]float64) ([3]float64, error) {
+	for col := 0; col < 3; col++ {
+		pivot := col
+		for row := col + 1; row < 3; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return [3]float64{}, fmt.Errorf("singular system")
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+
+		for row := col + 1; row < 3; row++ {
+			f := m[row][col] / m[col][col]
+			for k := col; k < 4; k++ {
+				m[row][k] -= f * m[col][k]
+			}
+		}
+	}
+
+	var x [3]float64
+	for row := 2; row >= 0; row-- {
+		sum := m[row][3]
+		for k := row + 1; k < 3; k++ {
+			sum -= m[row][k] * x[k]
+		}
+		x[row] = sum / m[row][row]
+	}
+	return x, nil
+}
+
+// NormalizeIncidence flattens the range dependence of sea clutter. Valid
+// pixels are grouped into incidence angle bins of binDeg degrees and each is
+// scaled by globalMean/binMean, so one global threshold gives the same false
+// alarm rate at near and far range. Bins with too few pixels are left as is.
+func NormalizeIncidence(grid gdal.Grid, angles []float64, binDeg float64) (gdal.Grid, error) {
+	expected := grid.Width * grid.Height
+	if len(angles) != expected || len(grid.Data) < expected {
+		return gdal.Grid{}, fmt.Errorf("incidence grid size %d does not match grid %dx%d", len(angles), grid.Width, grid.Height)
+	}
+	binDeg, err := incidenceBinDeg(binDeg)
+	if err != nil {
+		return gdal.Grid{}, err
+	}
+
+	sums := newIncidenceSums()
+	sums.add(grid, angles, binDeg)
+	return sums.means().apply(grid, angles, binDeg), nil
+}
+
+func incidenceBinDeg(binDeg float64) (float64, error) {
+	if binDeg == 0 {
+		return defaultIncidenceBinDeg, nil
+	}
+	if binDeg < 0 {
+		return 0, fmt.Errorf("incidence bin must be positive, got %v", binDeg)
+	}
+	return binDeg, nil
+}
+
+func incidenceBin(angle, binDeg float64) int {
+	return int(math.Floor(angle / binDeg))
+}
+
+// incidenceSums accumulates valid pixel values per incidence angle bin and
+// over all bins.
+type incidenceSums struct {
+	bins  map[int]*incidenceBinSum
+	sum   float64
+	count int
+}
+
+type incidenceBinSum struct {
+	sum   float64
+	count int
+}
+
+func newIncidenceSums() incidenceSums {
+	return incidenceSums{bins: make(map[int]*incidenceBinSum)}
+}
+
+func (s *incidenceSums) add(grid gdal.Grid, angles []float64, binDeg float64) {
+	for idx := 0; idx < grid.Width*grid.Height; idx++ {
+		v := grid.Data[idx]
+		if !isValidPixel(v, grid.NoData) || math.IsNaN(angles[idx]) {
+			continue
+		}
+		s.addBin(incidenceBin(angles[idx], binDeg), v, 1)
+	}
+}
+
+func (s *incidenceSums) addBin(key int, sum float64, count int) {
+	bin := s.bins[key]
+	if bin == nil {
+		bin = &incidenceBinSum{}
+		s.bins[key] = bin
+	}
+	bin.sum += sum
+	bin.count += count
+	s.sum += sum
+	s.count += count
+}
+
+func (s *incidenceSums) merge(o incidenceSums) {
+	for key, bin := range o.bins {
+		s.addBin(key, bin.sum, bin.count)
+	}
+}
+
+// means returns the global mean and the mean of every bin with enough pixels
+// to be normalized.
+func (s incidenceSums) means() incidenceMeans {
+	means := incidenceMeans{bins: make(map[int]float64)}
+	if s.count == 0 {
+		return means
+	}
+	means.global = s.sum / float64(s.count)
+	for key, bin := range s.bins {
+		if bin.count < minIncidenceBinPixels || bin.sum == 0 {
+			continue
+		}
+		means.bins[key] = bin.sum / float64(bin.count)
+	}
+	return means
+}
+
+// incidenceMeans holds the clutter means NormalizeIncidence scales by.
+type incidenceMeans struct {
+	global float64
+	bins   map[int]float64
+}
+
+// apply returns a copy of grid with every valid pixel in a normalized bin
+// scaled by global/binMean.
+func (m incidenceMeans) apply(grid gdal.Grid, angles []float64, binDeg float64) gdal.Grid {
+	expected := grid.Width * grid.Height
+	out := gdal.Grid{
+		Width:  grid.Width,
+		Height: grid.Height,
+		NoData: grid.NoData,
+		Data:   append([]float64(nil), grid.Data[:expected]...),
+	}
+	for idx := 0; idx < expected; idx++ {
+		v := out.Data[idx]
+		if !isValidPixel(v, grid.NoData) || math.IsNaN(angles[idx]) {
+			continue
+		}
+		binMean, ok := m.bins[incidenceBin(angles[idx], binDeg)]
+		if !ok {
+			continue
+		}
+		out.Data[idx] = v * m.global / binMean
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package detect
+
+import (
+	"math"
+	"testing"
+
+	"boatdetect/internal/gdal"
+)
+
+func TestFitIncidencePlane(t *testing.T) {
+	points := []IncidencePoint{
+		{Lon: 103.0, Lat: 1.0, AngleDeg: 30},
+		{Lon: 104.0, Lat: 1.0, AngleDeg: 37},
+		{Lon: 103.0, Lat: 2.0, AngleDeg: 29},
+		{Lon: 104.0, Lat: 2.0, AngleDeg: 36},
+		{Lon: 103.5, Lat: 1.5, AngleDeg: 33},
+	}
+
+	plane, err := FitIncidencePlane(points)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, p := range points {
+		assertFloatClose(t, plane.Angle(p.Lon, p.Lat), p.AngleDeg)
+	}
+
+	if _, err := FitIncidencePlane(points[:2]); err == nil {
+		t.Fatalf("expected error for too few points")
+	}
+	collinear := []IncidencePoint{{103, 1, 30}, {103, 2, 31}, {103, 3, 32}}
+	if _, err := FitIncidencePlane(collinear); err == nil {
+		t.Fatalf("expected error for degenerate points")
+	}
+}
+
+func TestNormalizeIncidenceUniformFalseAlarms(t *testing.T) {
+	const width, height = 300, 200
+	gt := [6]float64{103, 0.001, 0, 2, 0, -0.001}
+	plane := IncidencePlane{A: 30 - 103*15/0.3, B: 15 / 0.3}
+	angles := plane.AngleGrid(gt, width, height)
+
+	// Sea clutter falls by ~10 dB from 30 to 45 degrees of incidence.
+	clutter := func(x, y int) float64 {
+		return 200 * math.Pow(10, -(angles[y*width+x]-30)/15)
+	}
+	grid := speckledGrid(width, height, clutter, 4)
+
+	for _, invert := range []bool{false, true} {
+		raw := thirdsFalseAlarms(t, grid, invert)
+		if raw[0] < 5*raw[2] && raw[2] < 5*raw[0] {
+			t.Fatalf("invert=%v: expected range ramp to skew raw false alarms, got %v", invert, raw)
+		}
+
+		normalized, err := NormalizeIncidence(grid, angles, 0.5)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		got := thirdsFalseAlarms(t, normalized, invert)
+		mean := float64(got[0]+got[1]+got[2]) / 3
+		for i, n := range got {
+			if math.Abs(float64(n)-mean) > 0.25*mean {
+				t.Fatalf("invert=%v: expected uniform false alarms across range, third %d has %d of %v", invert, i, n, got)
+			}
+		}
+	}
+}
+
+func TestNormalizeIncidenceKeepsNoData(t *testing.T) {
+	grid := gdal.Grid{Width: 2, Height: 1, NoData: -9999, Data: []float64{-9999, 10}}
+	got, err := NormalizeIncidence(grid, []float64{30, math.NaN()}, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Data[0] != -9999 || got.Data[1] != 10 {
+		t.Fatalf("expected nodata and unknown angles untouched, got %v", got.Data)
+	}
+
+	if _, err := NormalizeIncidence(grid, []float64{30}, 1); err == nil {
+		t.Fatalf("expected size mismatch error")
+	}
+}
+
+// thirdsFalseAlarms counts threshold exceedances at the scene's 99.5th
+// percentile in the near, mid and far range thirds.
+func thirdsFalseAlarms(t *testing.T, grid gdal.Grid, invert bool) [3]int {
+	t.Helper()
+	threshold, err := calculateThreshold(grid, 0, 99.5, invert)
+	if err != nil {
+		t.Fatalf("threshold: %v", err)
+	}
+
+	var counts [3]int
+	mask := ThresholdMask(grid, threshold, invert)
+	for idx, hit := range mask {
+		if hit {
+			counts[(idx%grid.Width)*3/grid.Width]++
+		}
+	}
+	return counts
+}
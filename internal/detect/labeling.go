@@ -0,0 +1,217 @@
+package detect
+
+import (
+	"context"
+	"runtime"
+	"sort"
+
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/parallel"
+)
+
+// LabelComponents extracts 4-neighborhood connected components from a binary
+// mask with two-pass union-find labeling over row bands processed by up to
+// workers goroutines (zero uses one per CPU). It returns the same components
+// as MaskComponents, in the same order, and a label image holding the
+// 1-based index of each pixel's component, or 0 for background and for
+// components smaller than minAreaPx. It stops early when ctx is cancelled.
+func LabelComponents(ctx context.Context, grid gdal.Grid, mask []bool, minAreaPx int, workers int) ([]Component, []int32, error) {
+	if grid.Width <= 0 || grid.Height <= 0 {
+		return nil, nil, nil
+	}
+
+	expected := grid.Width * grid.Height
+	if len(grid.Data) < expected || len(mask) < expected {
+		return nil, nil, nil
+	}
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	bands := splitBands(grid.Height, workers)
+	labels := make([]int32, expected)
+
+	// Pass 1: provisional labels and local equivalences per band.
+	localRoots := make([][]int32, len(bands))
+	err := forEachBand(ctx, bands, func(b int, band [2]int) {
+		localRoots[b] = labelBand(grid.Width, band, mask, labels)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	offsets := make([]int, len(bands))
+	total := 0
+	for b := range bands {
+		offsets[b] = total
+		total += len(localRoots[b])
+	}
+
+	parent := make([]int, total)
+	for b, roots := range localRoots {
+		for i, root := range roots {
+			parent[offsets[b]+i] = offsets[b] + int(root)
+		}
+	}
+
+	// Join components that continue across band boundaries.
+	for b := 1; b < len(bands); b++ {
+		y := bands[b][0]
+		for x := 0; x < grid.Width; x++ {
+			above := labels[(y-1)*grid.Width+x]
+			below := labels[y*grid.Width+x]
+			if above != 0 && below != 0 {
+				union(parent, offsets[b-1]+int(above)-1, offsets[b]+int(below)-1)
+			}
+		}
+	}
+
+	// Pass 2: accumulate statistics per provisional label.
+	parts := make([]partialComponent, total)
+	err = forEachBand(ctx, bands, func(b int, band [2]int) {
+		accumulateBand(grid, band, labels, parts[offsets[b]:offsets[b]+len(localRoots[b])])
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roots := make([]int, 0)
+	for g := range parts {
+		root := find(parent, g)
+		if root == g {
+			roots = append(roots, g)
+			continue
+		}
+		mergePartial(&parts[root], parts[g])
+	}
+
+	kept := roots[:0]
+	for _, root := range roots {
+		if parts[root].area >= minAreaPx {
+			kept = append(kept, root)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return parts[kept[i]].firstIdx < parts[kept[j]].firstIdx })
+
+	finalID := make([]int32, total)
+	components := make([]Component, 0, len(kept))
+	for i, root := range kept {
+		finalID[root] = int32(i + 1)
+		components = append(components, parts[root].component())
+	}
+	for g := range finalID {
+		finalID[g] = finalID[find(parent, g)]
+	}
+
+	// Pass 3: rewrite provisional labels to final component ids.
+	err = forEachBand(ctx, bands, func(b int, band [2]int) {
+		for idx := band[0] * grid.Width; idx < band[1]*grid.Width; idx++ {
+			if l := labels[idx]; l != 0 {
+				labels[idx] = finalID[offsets[b]+int(l)-1]
+			}
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return components, labels, nil
+}
+
+func splitBands(height, workers int) [][2]int {
+	n := min(workers, height)
+	bands := make([][2]int, 0, n)
+	for b := 0; b < n; b++ {
+		bands = append(bands, [2]int{b * height / n, (b + 1) * height / n})
+	}
+	return bands
+}
+
+// forEachBand calls fn for every band on its own goroutine; see
+// parallel.ForEach.
+func forEachBand(ctx context.Context, bands [][2]int, fn func(b int, band [2]int)) error {
+	return parallel.ForEach(ctx, len(bands), len(bands), func(_ context.Context, b int) error {
+		fn(b, bands[b])
+		return nil
+	})
+}
+
+// labelBand writes 1-based provisional labels for the rows of one band and
+// returns the 0-based root of every provisional label.
+func labelBand(width int, band [2]int, mask []bool, labels []int32) []int32 {
+	parent := make([]int32, 0)
+	findLocal := func(i int32) int32 {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	for y := band[0]; y < band[1]; y++ {
+		for x := 0; x < width; x++ {
+			idx := y*width + x
+			if !mask[idx] {
+				continue
+			}
+
+			var left, up int32
+			if x > 0 {
+				left = labels[idx-1]
+			}
+			if y > band[0] {
+				up = labels[idx-width]
+			}
+
+			switch {
+			case left == 0 && up == 0:
+				parent = append(parent, int32(len(parent)))
+				labels[idx] = int32(len(parent))
+			case up == 0:
+				labels[idx] = left
+			case left == 0:
+				labels[idx] = up
+			default:
+				labels[idx] = left
+				ra, rb := findLocal(left-1), findLocal(up-1)
+				if ra < rb {
+					parent[rb] = ra
+				} else if rb < ra {
+					parent[ra] = rb
+				}
+			}
+		}
+	}
+
+	for i := range parent {
+		parent[i] = findLocal(int32(i))
+	}
+	return parent
+}
+
+func accumulateBand(grid gdal.Grid, band [2]int, labels []int32, parts []partialComponent) {
+	for i := range parts {
+		parts[i] = partialComponent{minX: grid.Width, minY: grid.Height, maxX: -1, maxY: -1, firstIdx: len(grid.Data)}
+	}
+
+	for y := band[0]; y < band[1]; y++ {
+		for x := 0; x < grid.Width; x++ {
+			idx := y*grid.Width + x
+			l := labels[idx]
+			if l == 0 {
+				continue
+			}
+			part := &parts[l-1]
+			part.area++
+			part.sum += grid.Data[idx]
+			part.sumX += float64(x)
+			part.sumY += float64(y)
+			part.sumXX += float64(x * x)
+			part.sumYY += float64(y * y)
+			part.sumXY += float64(x * y)
+			part.minX, part.minY = min(part.minX, x), min(part.minY, y)
+			part.maxX, part.maxY = max(part.maxX, x), max(part.maxY, y)
+			part.firstIdx = min(part.firstIdx, idx)
+		}
+	}
+}
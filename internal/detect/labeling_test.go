@@ -0,0 +1,161 @@
+package detect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"boatdetect/internal/gdal"
+)
+
+func TestLabelComponentsMatchesFloodFill(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	for _, size := range []struct{ width, height int }{{1, 1}, {1, 30}, {30, 1}, {23, 17}, {128, 96}} {
+		grid := randomIntGrid(rng, size.width, size.height)
+		for _, threshold := range []float64{3, 5, 8} {
+			mask := ThresholdMask(grid, threshold, false)
+			want := MaskComponents(grid, mask, 2)
+
+			for _, workers := range []int{1, 2, 5, 64} {
+				name := fmt.Sprintf("%dx%d/t%v/w%d", size.width, size.height, threshold, workers)
+				got, labels, err := LabelComponents(context.Background(), grid, mask, 2, workers)
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+					t.Fatalf("%s: components differ\ngot  %+v\nwant %+v", name, got, want)
+				}
+				assertLabelImage(t, name, grid, mask, got, labels)
+			}
+		}
+	}
+}
+
+func TestLabelComponentsSpiralAcrossBands(t *testing.T) {
+	grid := gridFromRows(
+		"#########",
+		"#.......#",
+		"#.#####.#",
+		"#.#...#.#",
+		"#.#.#.#.#",
+		"#.#.#...#",
+		"#.#.#####",
+		"#.#......",
+		"#.#######",
+	)
+	mask := ThresholdMask(grid, 1, false)
+
+	got, labels, err := LabelComponents(context.Background(), grid, mask, 1, 9)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := MaskComponents(grid, mask, 1)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected spiral and centre as 2 components, got %d", len(got))
+	}
+	assertLabelImage(t, "spiral", grid, mask, got, labels)
+}
+
+func TestLabelComponentsMinAreaClearsLabels(t *testing.T) {
+	grid := gridFromRows(
+		"#..##",
+		"...##",
+	)
+	got, labels, err := LabelComponents(context.Background(), grid, ThresholdMask(grid, 1, false), 2, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(got) != 1 || got[0].Area != 4 {
+		t.Fatalf("expected one component of area 4, got %+v", got)
+	}
+	want := []int32{0, 0, 0, 1, 1, 0, 0, 0, 1, 1}
+	if !reflect.DeepEqual(labels, want) {
+		t.Fatalf("expected labels %v, got %v", want, labels)
+	}
+}
+
+func TestLabelComponentsCancelled(t *testing.T) {
+	grid := gridFromRows("#.#", "###")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := LabelComponents(ctx, grid, ThresholdMask(grid, 1, false), 1, 2); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+}
+
+// assertLabelImage checks that every labelled pixel is set in the mask and
+// that label areas agree with the returned components.
+func assertLabelImage(t *testing.T, name string, grid gdal.Grid, mask []bool, components []Component, labels []int32) {
+	t.Helper()
+	if len(labels) != grid.Width*grid.Height {
+		t.Fatalf("%s: expected %d labels, got %d", name, grid.Width*grid.Height, len(labels))
+	}
+	areas := make([]int, len(components)+1)
+	for idx, l := range labels {
+		if l != 0 && !mask[idx] {
+			t.Fatalf("%s: background pixel %d labelled %d", name, idx, l)
+		}
+		if int(l) > len(components) {
+			t.Fatalf("%s: label %d out of range", name, l)
+		}
+		areas[l]++
+	}
+	for i, c := range components {
+		if areas[i+1] != c.Area {
+			t.Fatalf("%s: component %d has area %d but %d labelled pixels", name, i+1, c.Area, areas[i+1])
+		}
+	}
+}
+
+// benchmarkGrid builds a speckle-like grid of BOATDETECT_BENCH_MEGAPIXELS
+// megapixels (default 4); set it to several hundred to reproduce full-scene
+// numbers.
+func benchmarkGrid(b *testing.B) (gdal.Grid, []bool) {
+	b.Helper()
+	megapixels := 4
+	if v := os.Getenv("BOATDETECT_BENCH_MEGAPIXELS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			b.Fatalf("invalid BOATDETECT_BENCH_MEGAPIXELS %q", v)
+		}
+		megapixels = n
+	}
+
+	width := 4096
+	height := megapixels * 1000000 / width
+	rng := rand.New(rand.NewSource(1))
+	data := make([]float64, width*height)
+	for i := range data {
+		data[i] = float64(rng.Intn(256))
+	}
+	grid := gdal.Grid{Width: width, Height: height, NoData: -9999, Data: data}
+	return grid, ThresholdMask(grid, 200, false)
+}
+
+func BenchmarkMaskComponents(b *testing.B) {
+	grid, mask := benchmarkGrid(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		MaskComponents(grid, mask, 2)
+	}
+}
+
+func BenchmarkLabelComponents(b *testing.B) {
+	grid, mask := benchmarkGrid(b)
+	for _, workers := range []int{1, runtime.NumCPU()} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				LabelComponents(context.Background(), grid, mask, 2, workers)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package detect
+
+import (
+	"fmt"
+	"strings"
+
+	"boatdetect/internal/gdal"
+)
+
+// MorphOp names a binary morphology operation applied to the detection mask.
+type MorphOp string
+
+const (
+	MorphErode  MorphOp = "erode"
+	MorphDilate MorphOp = "dilate"
+	MorphOpen   MorphOp = "open"
+	MorphClose  MorphOp = "close"
+)
+
+// ElementShape names the shape of a structuring element.
+type ElementShape string
+
+const (
+	ElementSquare ElementShape = "square"
+	ElementCross  ElementShape = "cross"
+	ElementDisk   ElementShape = "disk"
+)
+
+// StructuringElement is a symmetric neighbourhood of the given radius.
+type StructuringElement struct {
+	Shape  ElementShape
+	Radius int
+}
+
+// MorphologyConfig configures mask post-processing before labeling. Ops run
+// in order; FillHoles runs last.
+type MorphologyConfig struct {
+	Ops       []MorphOp
+	Element   StructuringElement
+	FillHoles bool
+}
+
+// ParseMorphOps parses a comma-separated list of operations such as "close,open".
+func ParseMorphOps(list string) ([]MorphOp, error) {
+	ops := make([]MorphOp, 0)
+	for _, part := range strings.Split(list, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" || name == "none" {
+			continue
+		}
+		switch op := MorphOp(name); op {
+		case MorphErode, MorphDilate, MorphOpen, MorphClose:
+			ops = append(ops, op)
+		default:
+			return nil, fmt.Errorf("unknown morphology operation %q", part)
+		}
+	}
+	return ops, nil
+}
+
+// ParseElementShape resolves a structuring element shape name.
+func ParseElementShape(name string) (ElementShape, error) {
+	switch shape := ElementShape(strings.ToLower(strings.TrimSpace(name))); shape {
+	case "":
+		return ElementSquare, nil
+	case ElementSquare, ElementCross, ElementDisk:
+		return shape, nil
+	default:
+		return "", fmt.Errorf("unknown structuring element %q", name)
+	}
+}
+
+// ThresholdMask marks pixels that pass the threshold, skipping NoData and NaN.
+func ThresholdMask(grid gdal.Grid, threshold float64, invert bool) []bool {
+	expected := grid.Width * grid.Height
+	if grid.Width <= 0 || grid.Height <= 0 || len(grid.Data) < expected {
+		return nil
+	}
+
+	mask := make([]bool, expected)
+	for idx := 0; idx < expected; idx++ {
+		v := grid.Data[idx]
+		if !isValidPixel(v, grid.NoData) {
+			continue
+		}
+		if invert {
+			mask[idx] = v <= threshold
+		} else {
+			mask[idx] = v >= threshold
+		}
+	}
+	return mask
+}
+
+// ApplyMorphology runs the configured operations on a width x height mask and
+// returns the result. NoData and NaN pixels of grid never end up in the mask.
+func ApplyMorphology(grid gdal.Grid, mask []bool, cfg MorphologyConfig) ([]bool, error) {
+	if len(cfg.Ops) == 0 && !cfg.FillHoles {
+		return mask, nil
+	}
+	if len(mask) != grid.Width*grid.Height {
+		return nil, fmt.Errorf("mask size %d does not match grid %dx%d", len(mask), grid.Width, grid.Height)
+	}
+
+	offsets, err := elementOffsets(cfg.Element)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run on a background-padded copy so the image behaves as a window onto
+	// an empty plane: closing does not smear shapes along the border.
+	pad := elementRadius(offsets) * 2 * len(cfg.Ops)
+	width := grid.Width + 2*pad
+	height := grid.Height + 2*pad
+	out := padMask(mask, grid.Width, grid.Height, pad)
+	for _, op := range cfg.Ops {
+		switch op {
+		case MorphErode:
+			out = erode(out, width, height, offsets)
+		case MorphDilate:
+			out = dilate(out, width, height, offsets)
+		case MorphOpen:
+			out = dilate(erode(out, width, height, offsets), width, height, offsets)
+		case MorphClose:
+			out = erode(dilate(out, width, height, offsets), width, height, offsets)
+		default:
+			return nil, fmt.Errorf("unknown morphology operation %q", op)
+		}
+	}
+	out = cropMask(out, grid.Width, grid.Height, pad)
+	clearNoData(grid, out)
+
+	if cfg.FillHoles {
+		out = fillHoles(out, grid.Width, grid.Height)
+		clearNoData(grid, out)
+	}
+	return out, nil
+}
+
+func clearNoData(grid gdal.Grid, mask []bool) {
+	for idx, v := range grid.Data[:len(mask)] {
+		if !isValidPixel(v, grid.NoData) {
+			mask[idx] = false
+		}
+	}
+}
+
+func elementOffsets(se StructuringElement) ([][2]int, error) {
+	radius := se.Radius
+	if radius == 0 {
+		radius = 1
+	}
+	if radius < 0 {
+		return nil, fmt.Errorf("structuring element radius must be positive, got %d", se.Radius)
+	}
+
+	shape := se.Shape
+	if shape == "" {
+		shape = ElementSquare
+	}
+
+	offsets := make([][2]int, 0, (2*radius+1)*(2*radius+1))
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			switch shape {
+			case ElementSquare:
+			case ElementCross:
+				if dx != 0 && dy != 0 {
+					continue
+				}
+			case ElementDisk:
+				if dx*dx+dy*dy > radius*radius {
+					continue
+				}
+			default:
+				return nil, fmt.Errorf("unknown structuring element %q", se.Shape)
+			}
+			offsets = append(offsets, [2]int{dx, dy})
+		}
+	}
+	return offsets, nil
+}
+
+func elementRadius(offsets [][2]int) int {
+	radius := 0
+	for _, off := range offsets {
+		radius = max(radius, off[0], off[1])
+	}
+	return radius
+}
+
+func padMask(mask []bool, width, height, pad int) []bool {
+	stride := width + 2*pad
+	out := make([]bool, stride*(height+2*pad))
+	for y := 0; y < height; y++ {
+		copy(out[(y+pad)*stride+pad:], mask[y*width:(y+1)*width])
+	}
+	return out
+}
+
+func cropMask(padded []bool, width, height, pad int) []bool {
+	stride := width + 2*pad
+	out := make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		copy(out[y*width:(y+1)*width], padded[(y+pad)*stride+pad:])
+	}
+	return out
+}
+
+// erode keeps a pixel only if every element neighbour is set; pixels outside
+// the mask count as background.
+func erode(mask []bool, width, height int, offsets [][2]int) []bool {
+	out := make([]bool, len(mask))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if !mask[y*width+x] {
+				continue
+			}
+			out[y*width+x] = allNeighbors(mask, width, height, x, y, offsets)
+		}
+	}
+	return out
+}
+
+// dilate sets a pixel if any element neighbour is set.
+func dilate(mask []bool, width, height int, offsets [][2]int) []bool {
+	out := make([]bool, len(mask))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			out[y*width+x] = mask[y*width+x] || anyNeighbor(mask, width, height, x, y, offsets)
+		}
+	}
+	return out
+}
+
+func allNeighbors(mask []bool, width, height, x, y int, offsets [][2]int) bool {
+	for _, off := range offsets {
+		nx, ny := x+off[0], y+off[1]
+		if nx < 0 || ny < 0 || nx >= width || ny >= height {
+			return false
+		}
+		if !mask[ny*width+nx] {
+			return false
+		}
+	}
+	return true
+}
+
+func anyNeighbor(mask []bool, width, height, x, y int, offsets [][2]int) bool {
+	for _, off := range offsets {
+		nx, ny := x+off[0], y+off[1]
+		if nx < 0 || ny < 0 || nx >= width || ny >= height {
+			continue
+		}
+		if mask[ny*width+nx] {
+			return true
+		}
+	}
+	return false
+}
+
+// fillHoles sets every background pixel that is not 4-connected to the grid
+// border, so enclosed gaps become part of the surrounding component.
+func fillHoles(mask []bool, width, height int) []bool {
+	outside := make([]bool, len(mask))
+	stack := make([]int, 0)
+	push := func(idx int) {
+		if mask[idx] || outside[idx] {
+			return
+		}
+		outside[idx] = true
+		stack = append(stack, idx)
+	}
+
+	for x := 0; x < width; x++ {
+		push(x)
+		push((height-1)*width + x)
+	}
+	for y := 0; y < height; y++ {
+		push(y * width)
+		push(y*width + width - 1)
+	}
+
+	for len(stack) > 0 {
+		n := len(stack) - 1
+		cur := stack[n]
+		stack = stack[:n]
+
+		x := cur % width
+		y := cur / width
+		if x > 0 {
+			push(cur - 1)
+		}
+		if x+1 < width {
+			push(cur + 1)
+		}
+		if y > 0 {
+			push(cur - width)
+		}
+		if y+1 < height {
+			push(cur + width)
+		}
+	}
+
+	out := make([]bool, len(mask))
+	for idx := range mask {
+		out[idx] = mask[idx] || !outside[idx]
+	}
+	return out
+}
@@ -0,0 +1,190 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+
+	"boatdetect/internal/gdal"
+)
+
+func TestApplyMorphologyFillHolesMakesHullOneComponent(t *testing.T) {
+	grid := gridFromRows(
+		".......",
+		".#####.",
+		".#...#.",
+		".#.#.#.",
+		".#...#.",
+		".#####.",
+		".......",
+	)
+
+	mask := ThresholdMask(grid, 1, false)
+	if got := MaskComponents(grid, mask, 1); len(got) != 2 {
+		t.Fatalf("expected hull and superstructure as 2 components, got %d", len(got))
+	}
+
+	filled, err := ApplyMorphology(grid, mask, MorphologyConfig{FillHoles: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got := MaskComponents(grid, filled, 1)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 component, got %d", len(got))
+	}
+	if got[0].Area != 25 {
+		t.Fatalf("expected filled area 25, got %d", got[0].Area)
+	}
+	assertFloatClose(t, got[0].Cx, 3)
+	assertFloatClose(t, got[0].Cy, 3)
+}
+
+func TestApplyMorphologyCloseJoinsSplitShip(t *testing.T) {
+	grid := gridFromRows(
+		"..........",
+		".###.####.",
+		".###.####.",
+		"..........",
+	)
+
+	mask := ThresholdMask(grid, 1, false)
+	if got := MaskComponents(grid, mask, 1); len(got) != 2 {
+		t.Fatalf("expected split ship as 2 components, got %d", len(got))
+	}
+
+	closed, err := ApplyMorphology(grid, mask, MorphologyConfig{Ops: []MorphOp{MorphClose}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got := MaskComponents(grid, closed, 1)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 component, got %d", len(got))
+	}
+	if got[0].Area != 16 {
+		t.Fatalf("expected closed area 16, got %d", got[0].Area)
+	}
+}
+
+func TestApplyMorphologyOpenRemovesSpeckle(t *testing.T) {
+	grid := gridFromRows(
+		"#.......",
+		"...###..",
+		"...###.#",
+		"...###..",
+		".#......",
+	)
+
+	opened, err := ApplyMorphology(grid, ThresholdMask(grid, 1, false), MorphologyConfig{Ops: []MorphOp{MorphOpen}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := ThresholdMask(gridFromRows(
+		"........",
+		"...###..",
+		"...###..",
+		"...###..",
+		"........",
+	), 1, false)
+	if !reflect.DeepEqual(opened, want) {
+		t.Fatalf("unexpected opened mask: %v", opened)
+	}
+}
+
+func TestApplyMorphologyElements(t *testing.T) {
+	grid := gridFromRows(
+		".....",
+		".....",
+		"..#..",
+		".....",
+		".....",
+	)
+	mask := ThresholdMask(grid, 1, false)
+
+	tests := []struct {
+		element StructuringElement
+		area    int
+	}{
+		{StructuringElement{Shape: ElementSquare, Radius: 1}, 9},
+		{StructuringElement{Shape: ElementCross, Radius: 1}, 5},
+		{StructuringElement{Shape: ElementCross, Radius: 2}, 9},
+		{StructuringElement{Shape: ElementDisk, Radius: 2}, 13},
+	}
+	for _, tt := range tests {
+		dilated, err := ApplyMorphology(grid, mask, MorphologyConfig{Ops: []MorphOp{MorphDilate}, Element: tt.element})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got := countSet(dilated); got != tt.area {
+			t.Fatalf("%+v: expected dilated area %d, got %d", tt.element, tt.area, got)
+		}
+
+		eroded, err := ApplyMorphology(grid, dilated, MorphologyConfig{Ops: []MorphOp{MorphErode}, Element: tt.element})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !reflect.DeepEqual(eroded, mask) {
+			t.Fatalf("%+v: expected erosion to undo dilation", tt.element)
+		}
+	}
+}
+
+func TestApplyMorphologyKeepsNoDataOut(t *testing.T) {
+	grid := gdal.Grid{
+		Width:  3,
+		Height: 1,
+		NoData: -9999,
+		Data:   []float64{1, -9999, 1},
+	}
+
+	got, err := ApplyMorphology(grid, ThresholdMask(grid, 1, false), MorphologyConfig{Ops: []MorphOp{MorphDilate}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, []bool{true, false, true}) {
+		t.Fatalf("expected nodata pixel to stay unset, got %v", got)
+	}
+}
+
+func TestParseMorphOps(t *testing.T) {
+	got, err := ParseMorphOps("close, Open")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, []MorphOp{MorphClose, MorphOpen}) {
+		t.Fatalf("unexpected ops: %v", got)
+	}
+
+	if got, err := ParseMorphOps(""); err != nil || len(got) != 0 {
+		t.Fatalf("expected no ops, got %v (%v)", got, err)
+	}
+	if _, err := ParseMorphOps("close,tophat"); err == nil {
+		t.Fatalf("expected error for unknown operation")
+	}
+	if _, err := ParseElementShape("diamond"); err == nil {
+		t.Fatalf("expected error for unknown element")
+	}
+}
+
+// gridFromRows builds a grid where '#' is 1 and any other rune is 0.
+func gridFromRows(rows ...string) gdal.Grid {
+	grid := gdal.Grid{Width: len(rows[0]), Height: len(rows), NoData: -9999}
+	for _, row := range rows {
+		for _, r := range row {
+			v := 0.0
+			if r == '#' {
+				v = 1
+			}
+			grid.Data = append(grid.Data, v)
+		}
+	}
+	return grid
+}
+
+func countSet(mask []bool) int {
+	count := 0
+	for _, v := range mask {
+		if v {
+			count++
+		}
+	}
+	return count
+}
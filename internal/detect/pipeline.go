@@ -3,7 +3,7 @@ package detect
 import (
 	"context"
 	"fmt"
-	"os"
+	"math"
 	"path/filepath"
 
 	"boatdetect/internal/gdal"
@@ -15,65 +15,252 @@ type Candidate struct {
 	Lat    float64
 	Score  float64
 	AreaPx int
+	// Reason is set when the candidate is flagged as a likely false alarm.
+	Reason string
+	// Footprint holds the traced footprint when Config.Footprint is set.
+	Footprint []Polygon
+	Shape     Shape
 }
 
-// DetectCandidates runs the candidate detection pipeline for a GeoTIFF.
-func DetectCandidates(ctx context.Context, byteTifPath string, k float64, percentile float64, invert bool, minAreaPx int) ([]Candidate, error) {
+// Config holds the tunable parameters of the detection pipeline.
+type Config struct {
+	K          float64
+	Percentile float64
+	Invert     bool
+	MinAreaPx  int
+	Speckle    SpeckleConfig
+	Morphology MorphologyConfig
+	Cluster    ClusterConfig
+	Ambiguity  AmbiguityConfig
+	Incidence  IncidenceConfig
+	Tiles      TileConfig
+	// LabelWorkers bounds the goroutines used for whole-scene labeling (0 uses
+	// one per CPU), within any parallel.Limiter in the context.
+	LabelWorkers int
+	// Footprint selects the footprint geometry traced for each candidate.
+	Footprint FootprintKind
+}
+
+// Result is the outcome of running the pipeline on one raster.
+type Result struct {
+	Candidates []Candidate
+	// Threshold is the intensity threshold actually applied to the grid.
+	Threshold float64
+	Width     int
+	Height    int
+	// GeoTransform maps raster pixels to lon/lat.
+	GeoTransform [6]float64
+}
+
+// DetectCandidates runs the candidate detection pipeline for a GeoTIFF,
+// keeping intermediate grids in the workspace.
+func DetectCandidates(ctx context.Context, ws *gdal.Workspace, byteTifPath string, cfg Config) ([]Candidate, error) {
+	result, err := Detect(ctx, ws, byteTifPath, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return result.Candidates, nil
+}
+
+// Detect runs the candidate detection pipeline like DetectCandidates and also
+// reports the threshold and grid size used. With Config.Tiles set, the raster
+// is streamed tile by tile instead of being read whole.
+func Detect(ctx context.Context, ws *gdal.Workspace, byteTifPath string, cfg Config) (Result, error) {
 	info, err := gdal.GetInfo(ctx, byteTifPath)
 	if err != nil {
-		return nil, fmt.Errorf("get raster info: %w", err)
+		return Result{}, fmt.Errorf("get raster info: %w", err)
+	}
+
+	tempDir, cleanup, err := ws.TempDir("detect-")
+	if err != nil {
+		return Result{}, err
+	}
+	defer cleanup()
+
+	if cfg.Tiles.Size > 0 {
+		return detectTiled(ctx, tempDir, byteTifPath, info, cfg)
 	}
 
-	// Create temp file in current working directory (accessible by Docker container)
-	tempDir := filepath.Join(".tmp", "temp")
-	if err := os.MkdirAll(tempDir, 0o755); err != nil {
-		return nil, fmt.Errorf("create temp dir: %w", err)
+	grid, err := gdal.ReadGrid(ctx, tempDir, byteTifPath)
+	if err != nil {
+		return Result{}, err
 	}
-	defer os.RemoveAll(".tmp")
 
-	ascFile, err := os.CreateTemp(tempDir, "*.asc")
+	grid, err = FilterSpeckle(grid, cfg.Speckle)
 	if err != nil {
-		return nil, fmt.Errorf("create temp grid: %w", err)
+		return Result{}, fmt.Errorf("speckle filter: %w", err)
 	}
-	ascPath := ascFile.Name()
-	if err := ascFile.Close(); err != nil {
-		return nil, fmt.Errorf("close temp grid: %w", err)
+
+	grid, err = normalizeIncidence(ctx, tempDir, grid, info, cfg.Incidence)
+	if err != nil {
+		return Result{}, fmt.Errorf("incidence normalization: %w", err)
 	}
-	defer os.Remove(ascPath)
 
-	if err := gdal.ToAAIGrid(ctx, byteTifPath, ascPath); err != nil {
-		return nil, fmt.Errorf("convert to ascii grid: %w", err)
+	threshold, err := calculateThreshold(grid, cfg.K, cfg.Percentile, cfg.Invert)
+	if err != nil {
+		return Result{}, err
 	}
 
-	gridFile, err := os.Open(ascPath)
+	mask, err := ApplyMorphology(grid, ThresholdMask(grid, threshold, cfg.Invert), cfg.Morphology)
 	if err != nil {
-		return nil, fmt.Errorf("open ascii grid: %w", err)
+		return Result{}, fmt.Errorf("morphology: %w", err)
+	}
+	components, _, err := LabelComponents(ctx, grid, mask, cfg.MinAreaPx, cfg.LabelWorkers)
+	if err != nil {
+		return Result{}, fmt.Errorf("labeling: %w", err)
+	}
+
+	return buildResult(components, info, threshold, cfg, func(c Component) ([]Polygon, error) {
+		return ComponentFootprint(mask, grid.Width, c, info.GeoTransform, cfg.Footprint), nil
+	})
+}
+
+// detectTiled runs the pipeline on windows of the raster read one tile plus
+// its halo at a time. Each stage that needs scene-wide values (incidence bin
+// means, threshold statistics, holes) takes its own pass over the tiles, so
+// memory is bounded by the tiles in flight at the cost of re-reading them.
+func detectTiled(ctx context.Context, tempDir, byteTifPath string, info gdal.RasterInfo, cfg Config) (Result, error) {
+	scene := Scene{
+		Width:  info.Width,
+		Height: info.Height,
+		Read: func(ctx context.Context, win gdal.Window) (gdal.Grid, error) {
+			return gdal.ReadWindow(ctx, tempDir, byteTifPath, win)
+		},
+		Speckle: cfg.Speckle,
 	}
-	defer gridFile.Close()
 
-	grid, err := gdal.ParseAAIGrid(gridFile)
+	if cfg.Incidence.Enabled {
+		angles, err := incidenceWindows(ctx, tempDir, info, cfg.Incidence)
+		if err != nil {
+			return Result{}, fmt.Errorf("incidence normalization: %w", err)
+		}
+		scene, err = scene.WithIncidence(ctx, angles, cfg.Incidence.BinDeg, cfg.Tiles)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	threshold, err := scene.Threshold(ctx, cfg.K, cfg.Percentile, cfg.Invert, cfg.Tiles)
 	if err != nil {
-		return nil, fmt.Errorf("parse ascii grid: %w", err)
+		return Result{}, err
 	}
 
-	threshold, err := calculateThreshold(grid, k, percentile, invert)
+	components, err := TiledComponents(ctx, scene, threshold, cfg.Invert, cfg.MinAreaPx, cfg.Morphology, cfg.Tiles)
 	if err != nil {
-		return nil, err
+		return Result{}, fmt.Errorf("tiled labeling: %w", err)
 	}
 
-	components := Components(grid, threshold, invert, minAreaPx)
+	return buildResult(components, info, threshold, cfg, func(c Component) ([]Polygon, error) {
+		return TiledFootprint(ctx, scene, c, info.GeoTransform, threshold, cfg.Invert, cfg.Morphology, cfg.Tiles, cfg.Footprint)
+	})
+}
+
+// buildResult merges components into filtered candidates, tracing each
+// footprint with footprint.
+func buildResult(components []Component, info gdal.RasterInfo, threshold float64, cfg Config, footprint func(Component) ([]Polygon, error)) (Result, error) {
+	components = MergeComponents(components, info.GeoTransform, cfg.Cluster.MergeDistanceM)
+
 	candidates := make([]Candidate, 0, len(components))
 	for _, component := range components {
+		polygons, err := footprint(component)
+		if err != nil {
+			return Result{}, fmt.Errorf("footprint: %w", err)
+		}
 		lon, lat := PixelToLonLat(info.GeoTransform, component.Cx, component.Cy)
 		candidates = append(candidates, Candidate{
-			Lon:    lon,
-			Lat:    lat,
-			Score:  component.Sum / float64(component.Area),
-			AreaPx: component.Area,
+			Lon:       lon,
+			Lat:       lat,
+			Score:     component.Sum / float64(component.Area),
+			AreaPx:    component.Area,
+			Footprint: polygons,
+			Shape:     ComponentShape(component, info.GeoTransform),
 		})
 	}
 
-	return candidates, nil
+	candidates = SuppressNonMaxima(candidates, cfg.Cluster.NMSRadiusM)
+	return Result{
+		Candidates:   FilterAmbiguities(candidates, cfg.Ambiguity),
+		Threshold:    threshold,
+		Width:        info.Width,
+		Height:       info.Height,
+		GeoTransform: info.GeoTransform,
+	}, nil
+}
+
+func normalizeIncidence(ctx context.Context, tempDir string, grid gdal.Grid, info gdal.RasterInfo, cfg IncidenceConfig) (gdal.Grid, error) {
+	if !cfg.Enabled {
+		return grid, nil
+	}
+
+	angles, err := incidenceAngles(ctx, tempDir, grid, info, cfg)
+	if err != nil {
+		return gdal.Grid{}, err
+	}
+
+	return NormalizeIncidence(grid, angles, cfg.BinDeg)
+}
+
+func incidenceAngles(ctx context.Context, tempDir string, grid gdal.Grid, info gdal.RasterInfo, cfg IncidenceConfig) ([]float64, error) {
+	if cfg.RasterPath == "" {
+		plane, err := FitIncidencePlane(cfg.Points)
+		if err != nil {
+			return nil, err
+		}
+		return plane.AngleGrid(info.GeoTransform, grid.Width, grid.Height), nil
+	}
+
+	warped := filepath.Join(tempDir, "incidence.tif")
+	if err := gdal.WarpToGrid(ctx, cfg.RasterPath, warped, info); err != nil {
+		return nil, fmt.Errorf("warp incidence raster: %w", err)
+	}
+
+	angleGrid, err := gdal.ReadGrid(ctx, tempDir, warped)
+	if err != nil {
+		return nil, err
+	}
+	if angleGrid.Width != grid.Width || angleGrid.Height != grid.Height {
+		return nil, fmt.Errorf("incidence raster is %dx%d, want %dx%d", angleGrid.Width, angleGrid.Height, grid.Width, grid.Height)
+	}
+
+	return validAngles(angleGrid), nil
+}
+
+// incidenceWindows returns the incidence angles of any window of the
+// detection grid, from the fitted plane or from the incidence raster warped
+// onto the grid once.
+func incidenceWindows(ctx context.Context, tempDir string, info gdal.RasterInfo, cfg IncidenceConfig) (func(context.Context, gdal.Window) ([]float64, error), error) {
+	if cfg.RasterPath == "" {
+		plane, err := FitIncidencePlane(cfg.Points)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ context.Context, win gdal.Window) ([]float64, error) {
+			return plane.AngleWindow(info.GeoTransform, win), nil
+		}, nil
+	}
+
+	warped := filepath.Join(tempDir, "incidence.tif")
+	if err := gdal.WarpToGrid(ctx, cfg.RasterPath, warped, info); err != nil {
+		return nil, fmt.Errorf("warp incidence raster: %w", err)
+	}
+	return func(ctx context.Context, win gdal.Window) ([]float64, error) {
+		angleGrid, err := gdal.ReadWindow(ctx, tempDir, warped, win)
+		if err != nil {
+			return nil, err
+		}
+		return validAngles(angleGrid), nil
+	}, nil
+}
+
+// validAngles returns the angles of a grid with NoData replaced by NaN.
+func validAngles(angleGrid gdal.Grid) []float64 {
+	angles := angleGrid.Data[:angleGrid.Width*angleGrid.Height]
+	for i, v := range angles {
+		if !isValidPixel(v, angleGrid.NoData) {
+			angles[i] = math.NaN()
+		}
+	}
+	return angles
 }
 
 func calculateThreshold(grid gdal.Grid, k, percentile float64, invert bool) (float64, error) {
@@ -96,9 +283,9 @@ func calculatePercentileThreshold(grid gdal.Grid, percentile float64, invert boo
 }
 
 func calculateStdDevThreshold(grid gdal.Grid, k float64, invert bool) float64 {
-	mean, std := MeanStd(grid.Data, grid.NoData)
+	summary, _ := Summarize(grid.Data, grid.NoData)
 	if invert {
-		return mean - k*std
+		return summary.Mean - k*summary.Std
 	}
-	return mean + k*std
+	return summary.Mean + k*summary.Std
 }
@@ -5,54 +5,65 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+
+	"boatdetect/internal/gdal"
 )
 
 func TestDetectCandidates(t *testing.T) {
 	t.Setenv("BOATDETECT_GDAL_MODE", "local")
 
 	ctx := context.Background()
-
-	tempDir := t.TempDir()
-
-	infoPath := filepath.Join(tempDir, "gdalinfo")
-	writeScript(t, infoPath, `#!/bin/sh
-cat <<'EOF'
-{"size":[3,2],"geoTransform":[10,2,0,20,0,-2]}
-EOF
-`)
-
-	translatePath := filepath.Join(tempDir, "gdal_translate")
-	writeScript(t, translatePath, "#!/bin/sh\n"+
-		"cat > \"$4\" <<'EOF'\n"+
-		"ncols 3\n"+
-		"nrows 2\n"+
-		"xllcorner 0\n"+
-		"yllcorner 0\n"+
-		"cellsize 1\n"+
-		"NODATA_value -9999\n"+
-		"1 2 3\n"+
-		"4 5 6\n"+
-		"EOF\n")
-
-	prependPath(t, tempDir)
-
-	candidates, err := DetectCandidates(ctx, "/tmp/input.tif", 0.5, 0, false, 1)
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
-	}
-	if len(candidates) != 1 {
-		t.Fatalf("expected 1 candidate, got %d", len(candidates))
+	writeDetectScripts(t)
+
+	for _, tiles := range []TileConfig{{}, {Size: 2, Workers: 2}} {
+		result, err := Detect(ctx, testWorkspace(t), "/tmp/input.tif", Config{K: 0.5, MinAreaPx: 1, Tiles: tiles})
+		if err != nil {
+			t.Fatalf("tiles %+v: expected no error, got %v", tiles, err)
+		}
+		// Mean 3.5 plus half the population standard deviation of 1..6.
+		assertFloatClose(t, result.Threshold, 3.5+0.5*math.Sqrt(35.0/12))
+		if result.Width != 3 || result.Height != 2 {
+			t.Fatalf("tiles %+v: expected 3x2 grid, got %dx%d", tiles, result.Width, result.Height)
+		}
+
+		candidates := result.Candidates
+		if len(candidates) != 1 {
+			t.Fatalf("tiles %+v: expected 1 candidate, got %d", tiles, len(candidates))
+		}
+
+		got := candidates[0]
+		assertFloatClose(t, got.Lon, 13)
+		assertFloatClose(t, got.Lat, 18)
+		assertFloatClose(t, got.Score, 5.5)
+		if got.AreaPx != 2 {
+			t.Fatalf("tiles %+v: expected area 2, got %d", tiles, got.AreaPx)
+		}
 	}
+}
 
-	got := candidates[0]
-	assertFloatClose(t, got.Lon, 13)
-	assertFloatClose(t, got.Lat, 18)
-	assertFloatClose(t, got.Score, 5.5)
-	if got.AreaPx != 2 {
-		t.Fatalf("expected area 2, got %d", got.AreaPx)
+func TestDetectCandidatesFootprint(t *testing.T) {
+	t.Setenv("BOATDETECT_GDAL_MODE", "local")
+	writeDetectScripts(t)
+
+	for _, tiles := range []TileConfig{{}, {Size: 2, Workers: 2}} {
+		cfg := Config{K: 0.5, MinAreaPx: 1, Footprint: FootprintOutline, Tiles: tiles}
+		candidates, err := DetectCandidates(context.Background(), testWorkspace(t), "/tmp/input.tif", cfg)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(candidates) != 1 {
+			t.Fatalf("expected 1 candidate, got %d", len(candidates))
+		}
+
+		// Pixels (1,1) and (2,1) span lon 11..15 and lat 17..19.
+		want := []Polygon{{{{11, 19}, {11, 17}, {15, 17}, {15, 19}, {11, 19}}}}
+		if !reflect.DeepEqual(candidates[0].Footprint, want) {
+			t.Fatalf("tiles %+v: expected footprint %v, got %v", tiles, want, candidates[0].Footprint)
+		}
 	}
 }
 
@@ -69,7 +80,7 @@ func TestDetectCandidatesPropagatesInfoError(t *testing.T) {
 
 	prependPath(t, tempDir)
 
-	_, err := DetectCandidates(ctx, "/tmp/input.tif", 0.5, 0, false, 1)
+	_, err := DetectCandidates(ctx, testWorkspace(t), "/tmp/input.tif", Config{K: 0.5, MinAreaPx: 1})
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
@@ -78,6 +89,40 @@ func TestDetectCandidatesPropagatesInfoError(t *testing.T) {
 	}
 }
 
+// writeDetectScripts stubs gdalinfo and gdal_translate for a 3x2 scene with
+// values 1..6.
+func writeDetectScripts(t *testing.T) {
+	t.Helper()
+	tempDir := t.TempDir()
+
+	infoPath := filepath.Join(tempDir, "gdalinfo")
+	writeScript(t, infoPath, `#!/bin/sh
+cat <<'EOF'
+{"size":[3,2],"geoTransform":[10,2,0,20,0,-2]}
+EOF
+`)
+
+	// With -srcwin the script serves that window of the scene.
+	translatePath := filepath.Join(tempDir, "gdal_translate")
+	writeScript(t, translatePath, `#!/bin/sh
+x=0 y=0 w=3 h=2
+if [ "$3" = "-srcwin" ]; then
+  x=$4 y=$5 w=$6 h=$7
+fi
+for out; do :; done
+awk -v x="$x" -v y="$y" -v w="$w" -v h="$h" 'BEGIN {
+  printf "ncols %d\nnrows %d\nxllcorner 0\nyllcorner 0\ncellsize 1\nNODATA_value -9999\n", w, h
+  for (r = y; r < y + h; r++) {
+    line = ""
+    for (c = x; c < x + w; c++) line = line " " (r * 3 + c + 1)
+    print line
+  }
+}' > "$out"
+`)
+
+	prependPath(t, tempDir)
+}
+
 func assertFloatClose(t *testing.T, got, want float64) {
 	t.Helper()
 	if math.Abs(got-want) > 1e-9 {
@@ -100,3 +145,13 @@ func prependPath(t *testing.T, dir string) {
 	old := os.Getenv("PATH")
 	t.Setenv("PATH", dir+string(os.PathListSeparator)+old)
 }
+
+func testWorkspace(t *testing.T) *gdal.Workspace {
+	t.Helper()
+	ws, err := gdal.NewWorkspace(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("create workspace: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
@@ -0,0 +1,318 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"boatdetect/internal/gdal"
+)
+
+// maxSelectedValues bounds the values a streamed percentile collects before
+// selecting; larger candidate ranges are narrowed with another histogram pass.
+const maxSelectedValues = 1 << 20
+
+// WindowReader returns the raw pixels of one window of a scene.
+type WindowReader func(ctx context.Context, win gdal.Window) (gdal.Grid, error)
+
+// Scene is a width x height raster read window by window. Each window gets
+// the per-pixel preprocessing of the pipeline, speckle filtering and incidence
+// normalization, so tiled runs never hold the whole raster.
+type Scene struct {
+	Width  int
+	Height int
+	Read   WindowReader
+	// Speckle is applied over a halo around each window wide enough that the
+	// window equals the same part of the filtered scene.
+	Speckle   SpeckleConfig
+	incidence *incidenceScale
+}
+
+// GridScene serves windows of an in-memory grid with no preprocessing.
+func GridScene(grid gdal.Grid) Scene {
+	return Scene{
+		Width:  grid.Width,
+		Height: grid.Height,
+		Read: func(_ context.Context, win gdal.Window) (gdal.Grid, error) {
+			return subGrid(grid, tile{x0: win.X, y0: win.Y, x1: win.X + win.Width, y1: win.Y + win.Height}), nil
+		},
+	}
+}
+
+// window returns the preprocessed pixels of rectangle r.
+func (s Scene) window(ctx context.Context, r tile) (gdal.Grid, error) {
+	halo, err := speckleHalo(s.Speckle)
+	if err != nil {
+		return gdal.Grid{}, err
+	}
+	ext := s.expand(r, halo)
+
+	width, height := ext.x1-ext.x0, ext.y1-ext.y0
+	grid, err := s.Read(ctx, gdal.Window{X: ext.x0, Y: ext.y0, Width: width, Height: height})
+	if err != nil {
+		return gdal.Grid{}, err
+	}
+	if grid.Width != width || grid.Height != height || len(grid.Data) < width*height {
+		return gdal.Grid{}, fmt.Errorf("window at %d,%d is %dx%d with %d values, want %dx%d", ext.x0, ext.y0, grid.Width, grid.Height, len(grid.Data), width, height)
+	}
+	grid, err = FilterSpeckle(grid, s.Speckle)
+	if err != nil {
+		return gdal.Grid{}, fmt.Errorf("speckle filter: %w", err)
+	}
+	if ext != r {
+		grid = subGrid(grid, tile{x0: r.x0 - ext.x0, y0: r.y0 - ext.y0, x1: r.x1 - ext.x0, y1: r.y1 - ext.y0})
+	}
+
+	if s.incidence != nil {
+		grid, err = s.incidence.apply(ctx, grid, r)
+		if err != nil {
+			return gdal.Grid{}, fmt.Errorf("incidence normalization: %w", err)
+		}
+	}
+	return grid, nil
+}
+
+// expand grows r by margin pixels on every side, clipped to the scene.
+func (s Scene) expand(r tile, margin int) tile {
+	return tile{
+		x0: max(r.x0-margin, 0),
+		y0: max(r.y0-margin, 0),
+		x1: min(r.x1+margin, s.Width),
+		y1: min(r.y1+margin, s.Height),
+	}
+}
+
+// eachWindow reads the preprocessed pixels of every tile on up to workers
+// goroutines and passes them to fn.
+func (s Scene) eachWindow(ctx context.Context, tiles []tile, workers int, fn func(i int, grid gdal.Grid) error) error {
+	return forEachTile(ctx, tiles, workers, func(i int, t tile) error {
+		grid, err := s.window(ctx, t)
+		if err != nil {
+			return err
+		}
+		return fn(i, grid)
+	})
+}
+
+// speckleHalo is how far the speckle filter reads around a pixel.
+func speckleHalo(cfg SpeckleConfig) (int, error) {
+	if cfg.Filter == SpeckleNone {
+		return 0, nil
+	}
+	cfg, err := normalizeSpeckleConfig(cfg)
+	if err != nil {
+		return 0, err
+	}
+	return cfg.Window / 2, nil
+}
+
+// Threshold computes the detection threshold like the whole-scene pipeline,
+// streaming the scene tile by tile: mean ± k·std in one pass, or an exact
+// percentile in a few more.
+func (s Scene) Threshold(ctx context.Context, k, percentile float64, invert bool, cfg TileConfig) (float64, error) {
+	if cfg.Size <= 0 {
+		return 0, fmt.Errorf("tile size must be positive, got %d", cfg.Size)
+	}
+	if percentile >= 100 {
+		return 0, fmt.Errorf("percentile threshold: invalid percentile %v", percentile)
+	}
+	tiles := splitTiles(s.Width, s.Height, cfg.Size)
+
+	scans := make([]valueScan, len(tiles))
+	err := s.eachWindow(ctx, tiles, cfg.Workers, func(i int, grid gdal.Grid) error {
+		scans[i] = scanValues(grid.Data, grid.NoData)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var scan valueScan
+	for _, tileScan := range scans {
+		scan = scan.merge(tileScan)
+	}
+
+	if percentile <= 0 {
+		if invert {
+			return scan.mean - k*scan.std(), nil
+		}
+		return scan.mean + k*scan.std(), nil
+	}
+
+	if invert {
+		percentile = 100 - percentile
+	}
+	if scan.count == 0 {
+		return 0, fmt.Errorf("percentile threshold: no valid values")
+	}
+	return s.selectValue(ctx, tiles, cfg.Workers, scan, percentileIndex(percentile, scan.count))
+}
+
+// selectValue returns the value at sorted index idx of the valid pixels.
+// Each pass counts the values within [lo, hi] into histogram bins and
+// narrows the range to the bin holding idx, until the range holds a single
+// value or few enough values to collect and select from.
+func (s Scene) selectValue(ctx context.Context, tiles []tile, workers int, scan valueScan, idx int) (float64, error) {
+	lo, hi, n := scan.min, scan.max, scan.count
+	for lo != hi && n > maxSelectedValues {
+		// Counts and extremes merge exactly in any order, so tiles are
+		// folded in as they finish instead of holding one histogram each.
+		hist := newRangeHistogram(lo, hi)
+		var mu sync.Mutex
+		err := s.eachWindow(ctx, tiles, workers, func(_ int, grid gdal.Grid) error {
+			tileHist := newRangeHistogram(lo, hi)
+			tileHist.add(grid.Data, grid.NoData)
+			mu.Lock()
+			defer mu.Unlock()
+			hist.merge(tileHist)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		lo, hi, n, idx = hist.narrow(idx)
+	}
+	if lo == hi {
+		return lo, nil
+	}
+
+	parts := make([][]float64, len(tiles))
+	err := s.eachWindow(ctx, tiles, workers, func(i int, grid gdal.Grid) error {
+		for _, v := range grid.Data {
+			if isValidPixel(v, grid.NoData) && v >= lo && v <= hi {
+				parts[i] = append(parts[i], v)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	values := make([]float64, 0, n)
+	for _, part := range parts {
+		values = append(values, part...)
+	}
+	selectKth(values, idx)
+	return values[idx], nil
+}
+
+// rangeHistogram counts the valid values within [lo, hi] in equal-width bins
+// and tracks the smallest and largest value seen in each.
+type rangeHistogram struct {
+	lo, hi float64
+	counts []int
+	mins   []float64
+	maxs   []float64
+}
+
+func newRangeHistogram(lo, hi float64) rangeHistogram {
+	h := rangeHistogram{
+		lo:     lo,
+		hi:     hi,
+		counts: make([]int, maxHistogramBins),
+		mins:   make([]float64, maxHistogramBins),
+		maxs:   make([]float64, maxHistogramBins),
+	}
+	for i := range h.mins {
+		h.mins[i] = math.Inf(1)
+		h.maxs[i] = math.Inf(-1)
+	}
+	return h
+}
+
+func (h rangeHistogram) add(data []float64, nodata float64) {
+	scale := float64(len(h.counts)) / (h.hi - h.lo)
+	for _, v := range data {
+		if !isValidPixel(v, nodata) || v < h.lo || v > h.hi {
+			continue
+		}
+		bin := min(int((v-h.lo)*scale), len(h.counts)-1)
+		h.counts[bin]++
+		h.mins[bin] = math.Min(h.mins[bin], v)
+		h.maxs[bin] = math.Max(h.maxs[bin], v)
+	}
+}
+
+func (h rangeHistogram) merge(o rangeHistogram) {
+	for i := range h.counts {
+		h.counts[i] += o.counts[i]
+		h.mins[i] = math.Min(h.mins[i], o.mins[i])
+		h.maxs[i] = math.Max(h.maxs[i], o.maxs[i])
+	}
+}
+
+// narrow returns the value range and count of the bin holding sorted index
+// idx, and idx relative to that bin.
+func (h rangeHistogram) narrow(idx int) (lo, hi float64, n, binIdx int) {
+	seen := 0
+	for bin, c := range h.counts {
+		if seen+c > idx {
+			return h.mins[bin], h.maxs[bin], c, idx - seen
+		}
+		seen += c
+	}
+	// Unreachable while idx is below the number of values counted.
+	return h.lo, h.hi, seen, idx
+}
+
+// incidenceScale normalizes windows of a scene with bin means taken over the
+// whole scene, as NormalizeIncidence does for a whole grid.
+type incidenceScale struct {
+	angles func(ctx context.Context, r tile) ([]float64, error)
+	binDeg float64
+	means  incidenceMeans
+}
+
+func (s *incidenceScale) apply(ctx context.Context, grid gdal.Grid, r tile) (gdal.Grid, error) {
+	angles, err := s.angles(ctx, r)
+	if err != nil {
+		return gdal.Grid{}, err
+	}
+	return s.means.apply(grid, angles, s.binDeg), nil
+}
+
+// WithIncidence returns the scene with incidence normalization. angles
+// returns the incidence angle of every pixel of a window, NaN where unknown.
+// The bin means are gathered in one pass over the speckle-filtered tiles.
+func (s Scene) WithIncidence(ctx context.Context, angles func(ctx context.Context, win gdal.Window) ([]float64, error), binDeg float64, cfg TileConfig) (Scene, error) {
+	if cfg.Size <= 0 {
+		return Scene{}, fmt.Errorf("tile size must be positive, got %d", cfg.Size)
+	}
+	binDeg, err := incidenceBinDeg(binDeg)
+	if err != nil {
+		return Scene{}, err
+	}
+
+	tileAngles := func(ctx context.Context, r tile) ([]float64, error) {
+		values, err := angles(ctx, gdal.Window{X: r.x0, Y: r.y0, Width: r.x1 - r.x0, Height: r.y1 - r.y0})
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != (r.x1-r.x0)*(r.y1-r.y0) {
+			return nil, fmt.Errorf("incidence window has %d angles, want %dx%d", len(values), r.x1-r.x0, r.y1-r.y0)
+		}
+		return values, nil
+	}
+
+	tiles := splitTiles(s.Width, s.Height, cfg.Size)
+	sums := make([]incidenceSums, len(tiles))
+	err = s.eachWindow(ctx, tiles, cfg.Workers, func(i int, grid gdal.Grid) error {
+		angles, err := tileAngles(ctx, tiles[i])
+		if err != nil {
+			return err
+		}
+		sums[i] = newIncidenceSums()
+		sums[i].add(grid, angles, binDeg)
+		return nil
+	})
+	if err != nil {
+		return Scene{}, fmt.Errorf("incidence normalization: %w", err)
+	}
+
+	total := newIncidenceSums()
+	for _, tileSums := range sums {
+		total.merge(tileSums)
+	}
+	s.incidence = &incidenceScale{angles: tileAngles, binDeg: binDeg, means: total.means()}
+	return s, nil
+}
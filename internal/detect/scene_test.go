@@ -0,0 +1,124 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"boatdetect/internal/gdal"
+)
+
+func TestSceneWindowMatchesWholeSceneSpeckle(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	grid := randomIntGrid(rng, 37, 29)
+
+	filters := []SpeckleConfig{
+		{Filter: SpeckleBoxcar},
+		{Filter: SpeckleMedian, Window: 3},
+		{Filter: SpeckleLee},
+		{Filter: SpeckleRefinedLee},
+		{Filter: SpeckleFrost, Window: 7},
+	}
+	for _, speckle := range filters {
+		want, err := FilterSpeckle(grid, speckle)
+		if err != nil {
+			t.Fatalf("%s: whole scene: %v", speckle.Filter, err)
+		}
+
+		scene := GridScene(grid)
+		scene.Speckle = speckle
+		for _, r := range splitTiles(grid.Width, grid.Height, 8) {
+			got, err := scene.window(context.Background(), r)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got %v", speckle.Filter, err)
+			}
+			if !reflect.DeepEqual(got, subGrid(want, r)) {
+				t.Fatalf("%s: window %+v differs from the filtered scene", speckle.Filter, r)
+			}
+		}
+	}
+}
+
+func TestSceneThresholdMatchesWholeScene(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	small := randomIntGrid(rng, 64, 48)
+
+	// Over maxSelectedValues float values, so the percentile is narrowed by
+	// histogram passes before selecting.
+	large := gdal.Grid{Width: 1100, Height: 1000, NoData: -9999, Data: make([]float64, 1100*1000)}
+	for i := range large.Data {
+		large.Data[i] = rng.NormFloat64() * 10
+		if rng.Intn(50) == 0 {
+			large.Data[i] = -9999
+		}
+	}
+
+	for name, grid := range map[string]gdal.Grid{"small": small, "large": large} {
+		scene := GridScene(grid)
+		cfg := TileConfig{Size: 300, Workers: 3}
+		if name == "small" {
+			cfg.Size = 7
+		}
+		for _, percentile := range []float64{0, 50, 99.5} {
+			for _, invert := range []bool{false, true} {
+				label := fmt.Sprintf("%s/p%v/invert=%v", name, percentile, invert)
+				want, err := calculateThreshold(grid, 2, percentile, invert)
+				if err != nil {
+					t.Fatalf("%s: whole scene: %v", label, err)
+				}
+				got, err := scene.Threshold(context.Background(), 2, percentile, invert, cfg)
+				if err != nil {
+					t.Fatalf("%s: expected no error, got %v", label, err)
+				}
+				if math.Abs(got-want) > 1e-9 {
+					t.Fatalf("%s: expected threshold %v, got %v", label, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestSceneWithIncidenceMatchesNormalizeIncidence(t *testing.T) {
+	const width, height = 90, 60
+	gt := [6]float64{103, 0.001, 0, 2, 0, -0.001}
+	plane := IncidencePlane{A: 30 - 103*15/0.09, B: 15 / 0.09}
+	angles := plane.AngleGrid(gt, width, height)
+	grid := speckledGrid(width, height, func(x, y int) float64 {
+		return 200 * math.Pow(10, -(angles[y*width+x]-30)/15)
+	}, 4)
+
+	want, err := NormalizeIncidence(grid, angles, 1)
+	if err != nil {
+		t.Fatalf("whole scene: %v", err)
+	}
+
+	scene, err := GridScene(grid).WithIncidence(context.Background(), func(_ context.Context, win gdal.Window) ([]float64, error) {
+		return plane.AngleWindow(gt, win), nil
+	}, 1, TileConfig{Size: 16, Workers: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, r := range splitTiles(width, height, 16) {
+		got, err := scene.window(context.Background(), r)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for i, v := range subGrid(want, r).Data {
+			if math.Abs(got.Data[i]-v) > 1e-9*math.Abs(v) {
+				t.Fatalf("window %+v pixel %d: expected %v, got %v", r, i, v, got.Data[i])
+			}
+		}
+	}
+}
+
+func TestSceneWindowRejectsWrongSize(t *testing.T) {
+	scene := Scene{Width: 4, Height: 4, Read: func(_ context.Context, win gdal.Window) (gdal.Grid, error) {
+		return gdal.Grid{Width: 1, Height: 1, Data: []float64{1}}, nil
+	}}
+	if _, err := scene.Threshold(context.Background(), 2, 0, false, TileConfig{Size: 2}); err == nil {
+		t.Fatalf("expected error for a window of the wrong size")
+	}
+}
@@ -0,0 +1,42 @@
+package detect
+
+import "math"
+
+// Shape summarises the extent of a candidate from the second moments of its
+// pixels.
+type Shape struct {
+	// LengthM and WidthM are the sides of the rectangle with the same second
+	// moments as the pixels, along and across the major axis.
+	LengthM float64
+	WidthM  float64
+	// OrientationDeg is the bearing of the major axis, clockwise from north,
+	// in [0, 180). It assumes a north-up geotransform.
+	OrientationDeg float64
+}
+
+// ComponentShape returns the shape of c in metres. Each pixel contributes
+// the variance of a unit square so that a single pixel is one pixel long.
+func ComponentShape(c Component, gt [6]float64) Shape {
+	if c.Area == 0 {
+		return Shape{}
+	}
+	pxW, pxH := PixelSizeMetres(gt, c.Cx, c.Cy)
+	n := float64(c.Area)
+	varX := (c.SumXX/n - c.Cx*c.Cx + 1.0/12) * pxW * pxW
+	varY := (c.SumYY/n - c.Cy*c.Cy + 1.0/12) * pxH * pxH
+	covXY := (c.SumXY/n - c.Cx*c.Cy) * pxW * pxH
+
+	mean := (varX + varY) / 2
+	spread := math.Hypot((varX-varY)/2, covXY)
+	major, minor := mean+spread, math.Max(mean-spread, 0)
+
+	// The major axis angle is measured from +x (east) towards +y (south).
+	angle := 0.5 * math.Atan2(2*covXY, varX-varY)
+	bearing := math.Mod(90+angle*180/math.Pi+180, 180)
+
+	return Shape{
+		LengthM:        math.Sqrt(12 * major),
+		WidthM:         math.Sqrt(12 * minor),
+		OrientationDeg: bearing,
+	}
+}
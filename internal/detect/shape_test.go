@@ -0,0 +1,53 @@
+package detect
+
+import (
+	"math"
+	"testing"
+)
+
+func shapeOf(t *testing.T, rows ...string) (Shape, float64) {
+	t.Helper()
+	grid := gridFromRows(rows...)
+	components := MaskComponents(grid, ThresholdMask(grid, 1, false), 1)
+	if len(components) != 1 {
+		t.Fatalf("expected one component, got %d", len(components))
+	}
+	gt := [6]float64{0, 1e-5, 0, 0, 0, -1e-5}
+	px, _ := PixelSizeMetres(gt, 0, 0)
+	return ComponentShape(components[0], gt), px
+}
+
+func TestComponentShapeSinglePixel(t *testing.T) {
+	shape, px := shapeOf(t, "#")
+	if math.Abs(shape.LengthM-px) > 1e-6 || math.Abs(shape.WidthM-px) > 1e-6 {
+		t.Fatalf("expected %vm square, got %+v", px, shape)
+	}
+}
+
+func TestComponentShapeOrientation(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    []string
+		bearing float64
+		length  float64
+		tol     float64
+	}{
+		{name: "east-west", rows: []string{"#####"}, bearing: 90, length: 5, tol: 1e-3},
+		{name: "north-south", rows: []string{"#", "#", "#", "#"}, bearing: 0, length: 4, tol: 1e-3},
+		{name: "north-west to south-east", rows: []string{"##...", ".##..", "..##.", "...##"}, bearing: 135, tol: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shape, px := shapeOf(t, tt.rows...)
+			if math.Abs(shape.OrientationDeg-tt.bearing) > tt.tol {
+				t.Fatalf("expected bearing %v, got %v", tt.bearing, shape.OrientationDeg)
+			}
+			if tt.length > 0 && math.Abs(shape.LengthM-tt.length*px) > 1e-3 {
+				t.Fatalf("expected length %v, got %v", tt.length*px, shape.LengthM)
+			}
+			if shape.WidthM > shape.LengthM {
+				t.Fatalf("expected width %v not to exceed length %v", shape.WidthM, shape.LengthM)
+			}
+		})
+	}
+}
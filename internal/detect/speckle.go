@@ -0,0 +1,399 @@
+package detect
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"boatdetect/internal/gdal"
+)
+
+// SpeckleFilter names a speckle reduction filter applied before thresholding.
+type SpeckleFilter string
+
+const (
+	SpeckleNone       SpeckleFilter = ""
+	SpeckleBoxcar     SpeckleFilter = "boxcar"
+	SpeckleMedian     SpeckleFilter = "median"
+	SpeckleLee        SpeckleFilter = "lee"
+	SpeckleRefinedLee SpeckleFilter = "refined-lee"
+	SpeckleFrost      SpeckleFilter = "frost"
+)
+
+const (
+	defaultSpeckleWindow    = 5
+	defaultSpeckleLooks     = 1.0
+	defaultFrostDamping     = 2.0
+	minRefinedLeeWindow     = 5
+	defaultRefinedLeeWindow = 7
+)
+
+// SpeckleConfig configures the optional speckle filter stage.
+type SpeckleConfig struct {
+	Filter SpeckleFilter
+	// Window is the odd side length of the square filter window in pixels.
+	Window int
+	// Looks is the equivalent number of looks used by the Lee filters.
+	Looks float64
+	// Damping is the Frost damping factor.
+	Damping float64
+}
+
+// ParseSpeckleFilter resolves a filter name as accepted on the command line.
+func ParseSpeckleFilter(name string) (SpeckleFilter, error) {
+	switch filter := SpeckleFilter(strings.ToLower(strings.TrimSpace(name))); filter {
+	case SpeckleNone, "none":
+		return SpeckleNone, nil
+	case SpeckleBoxcar, SpeckleMedian, SpeckleLee, SpeckleRefinedLee, SpeckleFrost:
+		return filter, nil
+	default:
+		return SpeckleNone, fmt.Errorf("unknown speckle filter %q", name)
+	}
+}
+
+// FilterSpeckle returns a copy of grid smoothed with the configured speckle filter.
+// NoData and NaN pixels are preserved and excluded from every window.
+func FilterSpeckle(grid gdal.Grid, cfg SpeckleConfig) (gdal.Grid, error) {
+	if cfg.Filter == SpeckleNone {
+		return grid, nil
+	}
+
+	cfg, err := normalizeSpeckleConfig(cfg)
+	if err != nil {
+		return gdal.Grid{}, err
+	}
+
+	expected := grid.Width * grid.Height
+	if grid.Width <= 0 || grid.Height <= 0 || len(grid.Data) < expected {
+		return gdal.Grid{}, fmt.Errorf("invalid grid %dx%d with %d values", grid.Width, grid.Height, len(grid.Data))
+	}
+
+	out := gdal.Grid{
+		Width:  grid.Width,
+		Height: grid.Height,
+		NoData: grid.NoData,
+		Data:   make([]float64, expected),
+	}
+
+	switch cfg.Filter {
+	case SpeckleBoxcar:
+		boxcarFilter(grid, out.Data, cfg.Window)
+	case SpeckleMedian:
+		medianFilter(grid, out.Data, cfg.Window)
+	case SpeckleLee:
+		leeFilter(grid, out.Data, cfg.Window, cfg.Looks)
+	case SpeckleRefinedLee:
+		refinedLeeFilter(grid, out.Data, cfg.Window, cfg.Looks)
+	case SpeckleFrost:
+		frostFilter(grid, out.Data, cfg.Window, cfg.Damping)
+	}
+
+	return out, nil
+}
+
+func normalizeSpeckleConfig(cfg SpeckleConfig) (SpeckleConfig, error) {
+	if cfg.Window == 0 {
+		cfg.Window = defaultSpeckleWindow
+		if cfg.Filter == SpeckleRefinedLee {
+			cfg.Window = defaultRefinedLeeWindow
+		}
+	}
+	if cfg.Window < 3 || cfg.Window%2 == 0 {
+		return SpeckleConfig{}, fmt.Errorf("speckle window must be an odd size >= 3, got %d", cfg.Window)
+	}
+	if cfg.Filter == SpeckleRefinedLee && cfg.Window < minRefinedLeeWindow {
+		return SpeckleConfig{}, fmt.Errorf("refined lee window must be >= %d, got %d", minRefinedLeeWindow, cfg.Window)
+	}
+	if cfg.Looks == 0 {
+		cfg.Looks = defaultSpeckleLooks
+	}
+	if cfg.Looks < 0 {
+		return SpeckleConfig{}, fmt.Errorf("speckle looks must be positive, got %v", cfg.Looks)
+	}
+	if cfg.Damping == 0 {
+		cfg.Damping = defaultFrostDamping
+	}
+	if cfg.Damping < 0 {
+		return SpeckleConfig{}, fmt.Errorf("frost damping must be positive, got %v", cfg.Damping)
+	}
+	return cfg, nil
+}
+
+func isValidPixel(v, nodata float64) bool {
+	if math.IsNaN(v) {
+		return false
+	}
+	return math.IsNaN(nodata) || v != nodata
+}
+
+// windowSums is a summed-area table of value, squared value and valid pixel
+// count, giving O(1) window statistics for the box-shaped filters.
+type windowSums struct {
+	width int
+	sum   []float64
+	sumSq []float64
+	count []int
+}
+
+func newWindowSums(grid gdal.Grid) windowSums {
+	stride := grid.Width + 1
+	size := stride * (grid.Height + 1)
+	ws := windowSums{
+		width: grid.Width,
+		sum:   make([]float64, size),
+		sumSq: make([]float64, size),
+		count: make([]int, size),
+	}
+
+	for y := 0; y < grid.Height; y++ {
+		rowSum, rowSumSq, rowCount := 0.0, 0.0, 0
+		for x := 0; x < grid.Width; x++ {
+			v := grid.Data[y*grid.Width+x]
+			if isValidPixel(v, grid.NoData) {
+				rowSum += v
+				rowSumSq += v * v
+				rowCount++
+			}
+			idx := (y+1)*stride + x + 1
+			ws.sum[idx] = ws.sum[idx-stride] + rowSum
+			ws.sumSq[idx] = ws.sumSq[idx-stride] + rowSumSq
+			ws.count[idx] = ws.count[idx-stride] + rowCount
+		}
+	}
+
+	return ws
+}
+
+// meanVar returns the mean and population variance of valid pixels in the
+// inclusive rectangle [x0,x1]x[y0,y1].
+func (ws windowSums) meanVar(x0, y0, x1, y1 int) (mean, variance float64, count int) {
+	stride := ws.width + 1
+	a := y0*stride + x0
+	b := y0*stride + x1 + 1
+	c := (y1+1)*stride + x0
+	d := (y1+1)*stride + x1 + 1
+
+	count = ws.count[d] - ws.count[b] - ws.count[c] + ws.count[a]
+	if count == 0 {
+		return 0, 0, 0
+	}
+	sum := ws.sum[d] - ws.sum[b] - ws.sum[c] + ws.sum[a]
+	sumSq := ws.sumSq[d] - ws.sumSq[b] - ws.sumSq[c] + ws.sumSq[a]
+
+	mean = sum / float64(count)
+	variance = sumSq/float64(count) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return mean, variance, count
+}
+
+func clampedWindow(x, y, radius int, grid gdal.Grid) (x0, y0, x1, y1 int) {
+	x0 = max(x-radius, 0)
+	y0 = max(y-radius, 0)
+	x1 = min(x+radius, grid.Width-1)
+	y1 = min(y+radius, grid.Height-1)
+	return x0, y0, x1, y1
+}
+
+func boxcarFilter(grid gdal.Grid, out []float64, window int) {
+	ws := newWindowSums(grid)
+	radius := window / 2
+	forEachValidPixel(grid, out, func(x, y int, v float64) float64 {
+		mean, _, _ := ws.meanVar(clampedWindow(x, y, radius, grid))
+		return mean
+	})
+}
+
+func medianFilter(grid gdal.Grid, out []float64, window int) {
+	radius := window / 2
+	values := make([]float64, 0, window*window)
+	forEachValidPixel(grid, out, func(x, y int, v float64) float64 {
+		values = values[:0]
+		x0, y0, x1, y1 := clampedWindow(x, y, radius, grid)
+		for wy := y0; wy <= y1; wy++ {
+			for wx := x0; wx <= x1; wx++ {
+				if wv := grid.Data[wy*grid.Width+wx]; isValidPixel(wv, grid.NoData) {
+					values = append(values, wv)
+				}
+			}
+		}
+		sort.Float64s(values)
+		n := len(values)
+		if n%2 == 1 {
+			return values[n/2]
+		}
+		return (values[n/2-1] + values[n/2]) / 2
+	})
+}
+
+func leeFilter(grid gdal.Grid, out []float64, window int, looks float64) {
+	ws := newWindowSums(grid)
+	radius := window / 2
+	noiseVar := 1 / looks
+	forEachValidPixel(grid, out, func(x, y int, v float64) float64 {
+		mean, variance, _ := ws.meanVar(clampedWindow(x, y, radius, grid))
+		return leeEstimate(v, mean, variance, noiseVar)
+	})
+}
+
+// leeEstimate applies the Lee MMSE weighting for a multiplicative noise model
+// with normalized noise variance noiseVar (1/ENL).
+func leeEstimate(v, mean, variance, noiseVar float64) float64 {
+	if mean == 0 || variance == 0 {
+		return mean
+	}
+	ci2 := variance / (mean * mean)
+	weight := 1 - noiseVar/ci2
+	if weight < 0 {
+		weight = 0
+	}
+	return mean + weight*(v-mean)
+}
+
+// refinedLeeFilter implements the edge-aligned Lee filter: the window is split
+// into 3x3 sub-window means, the strongest edge direction is found from their
+// gradients, and Lee statistics are taken from the half window on the side of
+// the edge that matches the centre pixel.
+func refinedLeeFilter(grid gdal.Grid, out []float64, window int, looks float64) {
+	ws := newWindowSums(grid)
+	radius := window / 2
+	subRadius := radius / 3
+	offset := radius - subRadius
+	noiseVar := 1 / looks
+
+	forEachValidPixel(grid, out, func(x, y int, v float64) float64 {
+		var means [9]float64
+		for i := 0; i < 9; i++ {
+			cx := x + (i%3-1)*offset
+			cy := y + (i/3-1)*offset
+			means[i] = subWindowMean(ws, grid, cx, cy, subRadius, v)
+		}
+
+		inHalf := refinedLeeMask(means)
+		count := 0
+		mean := 0.0
+		m2 := 0.0
+		x0, y0, x1, y1 := clampedWindow(x, y, radius, grid)
+		for wy := y0; wy <= y1; wy++ {
+			for wx := x0; wx <= x1; wx++ {
+				if !inHalf(wx-x, wy-y) {
+					continue
+				}
+				wv := grid.Data[wy*grid.Width+wx]
+				if !isValidPixel(wv, grid.NoData) {
+					continue
+				}
+				count++
+				delta := wv - mean
+				mean += delta / float64(count)
+				m2 += delta * (wv - mean)
+			}
+		}
+
+		return leeEstimate(v, mean, m2/float64(count), noiseVar)
+	})
+}
+
+func subWindowMean(ws windowSums, grid gdal.Grid, cx, cy, radius int, fallback float64) float64 {
+	cx = min(max(cx, 0), grid.Width-1)
+	cy = min(max(cy, 0), grid.Height-1)
+	mean, _, count := ws.meanVar(clampedWindow(cx, cy, radius, grid))
+	if count == 0 {
+		return fallback
+	}
+	return mean
+}
+
+// refinedLeeMask picks one of the eight directional half windows from the
+// row-major 3x3 sub-window means.
+func refinedLeeMask(m [9]float64) func(dx, dy int) bool {
+	gradients := [4]float64{
+		math.Abs((m[2] + m[5] + m[8]) - (m[0] + m[3] + m[6])),
+		math.Abs((m[6] + m[7] + m[8]) - (m[0] + m[1] + m[2])),
+		math.Abs((m[5] + m[7] + m[8]) - (m[0] + m[1] + m[3])),
+		math.Abs((m[1] + m[2] + m[5]) - (m[3] + m[6] + m[7])),
+	}
+	direction := 0
+	for i := 1; i < len(gradients); i++ {
+		if gradients[i] > gradients[direction] {
+			direction = i
+		}
+	}
+
+	centre := m[4]
+	closer := func(a, b float64) bool {
+		return math.Abs(centre-a/3) <= math.Abs(centre-b/3)
+	}
+
+	switch direction {
+	case 0:
+		if closer(m[0]+m[3]+m[6], m[2]+m[5]+m[8]) {
+			return func(dx, dy int) bool { return dx <= 0 }
+		}
+		return func(dx, dy int) bool { return dx >= 0 }
+	case 1:
+		if closer(m[0]+m[1]+m[2], m[6]+m[7]+m[8]) {
+			return func(dx, dy int) bool { return dy <= 0 }
+		}
+		return func(dx, dy int) bool { return dy >= 0 }
+	case 2:
+		if closer(m[0]+m[1]+m[3], m[5]+m[7]+m[8]) {
+			return func(dx, dy int) bool { return dx+dy <= 0 }
+		}
+		return func(dx, dy int) bool { return dx+dy >= 0 }
+	default:
+		if closer(m[1]+m[2]+m[5], m[3]+m[6]+m[7]) {
+			return func(dx, dy int) bool { return dx-dy >= 0 }
+		}
+		return func(dx, dy int) bool { return dx-dy <= 0 }
+	}
+}
+
+// frostFilter weights each window pixel by exp(-damping * Ci^2 * distance),
+// where Ci is the local coefficient of variation.
+func frostFilter(grid gdal.Grid, out []float64, window int, damping float64) {
+	ws := newWindowSums(grid)
+	radius := window / 2
+	forEachValidPixel(grid, out, func(x, y int, v float64) float64 {
+		x0, y0, x1, y1 := clampedWindow(x, y, radius, grid)
+		mean, variance, _ := ws.meanVar(x0, y0, x1, y1)
+		if mean == 0 {
+			return mean
+		}
+		alpha := damping * variance / (mean * mean)
+
+		weighted := 0.0
+		weights := 0.0
+		for wy := y0; wy <= y1; wy++ {
+			for wx := x0; wx <= x1; wx++ {
+				wv := grid.Data[wy*grid.Width+wx]
+				if !isValidPixel(wv, grid.NoData) {
+					continue
+				}
+				dist := math.Hypot(float64(wx-x), float64(wy-y))
+				w := math.Exp(-alpha * dist)
+				weighted += w * wv
+				weights += w
+			}
+		}
+		return weighted / weights
+	})
+}
+
+// forEachValidPixel writes fn's result for every valid pixel and copies
+// NoData/NaN pixels through unchanged.
+func forEachValidPixel(grid gdal.Grid, out []float64, fn func(x, y int, v float64) float64) {
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			idx := y*grid.Width + x
+			v := grid.Data[idx]
+			if !isValidPixel(v, grid.NoData) {
+				out[idx] = v
+				continue
+			}
+			out[idx] = fn(x, y, v)
+		}
+	}
+}
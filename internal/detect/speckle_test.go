@@ -0,0 +1,191 @@
+package detect
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"boatdetect/internal/gdal"
+)
+
+func TestFilterSpeckleBoxcarAndMedian(t *testing.T) {
+	grid := gdal.Grid{
+		Width:  3,
+		Height: 3,
+		NoData: -9999,
+		Data: []float64{
+			1, 2, 3,
+			4, 50, 6,
+			7, 8, -9999,
+		},
+	}
+
+	boxcar, err := FilterSpeckle(grid, SpeckleConfig{Filter: SpeckleBoxcar, Window: 3})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	assertFloatClose(t, boxcar.Data[4], 81.0/8.0)
+	assertFloatClose(t, boxcar.Data[0], (1+2+4+50)/4.0)
+	if boxcar.Data[8] != -9999 {
+		t.Fatalf("expected nodata to be preserved, got %v", boxcar.Data[8])
+	}
+
+	median, err := FilterSpeckle(grid, SpeckleConfig{Filter: SpeckleMedian, Window: 3})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	assertFloatClose(t, median.Data[4], 5)
+	if grid.Data[4] != 50 {
+		t.Fatalf("expected input grid to be left untouched, got %v", grid.Data[4])
+	}
+}
+
+func TestFilterSpeckleReducesVariance(t *testing.T) {
+	const reflectivity = 100.0
+	grid := speckledGrid(64, 64, func(x, y int) float64 { return reflectivity }, 1)
+	_, rawStd := MeanStd(grid.Data, grid.NoData)
+
+	for _, filter := range []SpeckleFilter{SpeckleBoxcar, SpeckleMedian, SpeckleLee, SpeckleRefinedLee, SpeckleFrost} {
+		t.Run(string(filter), func(t *testing.T) {
+			got, err := FilterSpeckle(grid, SpeckleConfig{Filter: filter, Window: 7})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			mean, std := MeanStd(got.Data, got.NoData)
+			if std > rawStd*2/3 {
+				t.Fatalf("expected std to drop by a third from %v, got %v", rawStd, std)
+			}
+			// The median of an exponential distribution sits at ln(2) of its mean.
+			wantMean := reflectivity
+			if filter == SpeckleMedian {
+				wantMean = reflectivity * math.Ln2
+			}
+			if math.Abs(mean-wantMean) > 0.15*wantMean {
+				t.Fatalf("expected mean near %v, got %v", wantMean, mean)
+			}
+		})
+	}
+}
+
+func TestFilterSpeckleRefinedLeePreservesEdges(t *testing.T) {
+	step := func(x, y int) float64 {
+		if x < 32 {
+			return 20
+		}
+		return 200
+	}
+	grid := speckledGrid(64, 64, step, 16)
+
+	boxcar, err := FilterSpeckle(grid, SpeckleConfig{Filter: SpeckleBoxcar, Window: 7})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	refined, err := FilterSpeckle(grid, SpeckleConfig{Filter: SpeckleRefinedLee, Window: 7, Looks: 16})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	boxcarErr := edgeError(boxcar, step)
+	refinedErr := edgeError(refined, step)
+	if refinedErr >= boxcarErr/2 {
+		t.Fatalf("expected refined lee edge error %v to be well below boxcar %v", refinedErr, boxcarErr)
+	}
+}
+
+func TestFilterSpeckleRemovesSmallFalseComponents(t *testing.T) {
+	grid := speckledGrid(128, 128, func(x, y int) float64 { return 100 }, 1)
+	threshold := 300.0
+
+	raw := Components(grid, threshold, false, 2)
+	filtered, err := FilterSpeckle(grid, SpeckleConfig{Filter: SpeckleLee, Window: 5})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got := Components(filtered, threshold, false, 2)
+
+	if len(raw) == 0 {
+		t.Fatalf("expected speckle to produce false components")
+	}
+	if len(got) >= len(raw)/4 {
+		t.Fatalf("expected filtering to remove most false components, got %d from %d", len(got), len(raw))
+	}
+}
+
+func TestFilterSpeckleValidatesConfig(t *testing.T) {
+	grid := gdal.Grid{Width: 1, Height: 1, NoData: -9999, Data: []float64{1}}
+
+	tests := []SpeckleConfig{
+		{Filter: SpeckleLee, Window: 4},
+		{Filter: SpeckleLee, Window: 1},
+		{Filter: SpeckleRefinedLee, Window: 3},
+		{Filter: SpeckleLee, Looks: -1},
+		{Filter: SpeckleFrost, Damping: -1},
+	}
+	for _, cfg := range tests {
+		if _, err := FilterSpeckle(grid, cfg); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+
+	got, err := FilterSpeckle(grid, SpeckleConfig{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Data[0] != 1 {
+		t.Fatalf("expected no-op filter, got %v", got.Data)
+	}
+}
+
+func TestParseSpeckleFilter(t *testing.T) {
+	tests := map[string]SpeckleFilter{
+		"":            SpeckleNone,
+		"none":        SpeckleNone,
+		"Lee":         SpeckleLee,
+		"refined-lee": SpeckleRefinedLee,
+		" frost ":     SpeckleFrost,
+	}
+	for name, want := range tests {
+		got, err := ParseSpeckleFilter(name)
+		if err != nil {
+			t.Fatalf("parse %q: expected no error, got %v", name, err)
+		}
+		if got != want {
+			t.Fatalf("parse %q: expected %q, got %q", name, want, got)
+		}
+	}
+
+	if _, err := ParseSpeckleFilter("gamma"); err == nil {
+		t.Fatalf("expected error for unknown filter")
+	}
+}
+
+// speckledGrid multiplies the reflectivity field by gamma distributed speckle
+// with the given number of looks.
+func speckledGrid(width, height int, reflectivity func(x, y int) float64, looks int) gdal.Grid {
+	rng := rand.New(rand.NewSource(42))
+	data := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			speckle := 0.0
+			for i := 0; i < looks; i++ {
+				speckle += rng.ExpFloat64()
+			}
+			data[y*width+x] = reflectivity(x, y) * speckle / float64(looks)
+		}
+	}
+	return gdal.Grid{Width: width, Height: height, NoData: -9999, Data: data}
+}
+
+// edgeError is the mean absolute error against the true reflectivity in the
+// columns adjacent to the edge, ignoring the grid border.
+func edgeError(grid gdal.Grid, reflectivity func(x, y int) float64) float64 {
+	sum := 0.0
+	count := 0
+	for y := 4; y < grid.Height-4; y++ {
+		for x := 29; x < 35; x++ {
+			sum += math.Abs(grid.Data[y*grid.Width+x] - reflectivity(x, y))
+			count++
+		}
+	}
+	return sum / float64(count)
+}
@@ -6,66 +6,290 @@ import (
 	"sort"
 )
 
+// maxHistogramBins bounds the counting histogram used for data on a common
+// step, which covers Byte and UInt16 rasters and floats quantized to a fixed
+// increment, such as dB values rounded to 0.01.
+const maxHistogramBins = 1 << 16
+
+// Summary holds statistics of the valid values of a grid.
+type Summary struct {
+	Count int
+	Mean  float64
+	Std   float64
+	Min   float64
+	Max   float64
+	// Percentiles holds one value per requested percentile, in request order.
+	Percentiles []float64
+}
+
 // MeanStd returns the mean and population standard deviation, ignoring nodata and NaN values.
 func MeanStd(data []float64, nodata float64) (mean, std float64) {
-	count := 0
-	mean = 0
-	m2 := 0.0
+	// Without percentiles Summarize cannot fail.
+	summary, _ := Summarize(data, nodata)
+	return summary.Mean, summary.Std
+}
 
-	checkNoData := !math.IsNaN(nodata)
-	for _, v := range data {
-		if math.IsNaN(v) {
-			continue
+// Percentile returns the pth percentile value, ignoring nodata and NaN values.
+// p must be in the range (0, 100).
+func Percentile(data []float64, nodata float64, p float64) (float64, error) {
+	summary, err := Summarize(data, nodata, p)
+	if err != nil {
+		return 0, err
+	}
+	return summary.Percentiles[0], nil
+}
+
+// Summarize computes count, mean, standard deviation, range and the given
+// percentiles of the valid values. The moments and range take one scan;
+// percentiles, when requested, take a second pass. Percentiles follow the
+// Percentile definition: the value at sorted index ceil(p/100*n)-1. Data
+// whose values are the minimum plus multiples of a common step, with at most
+// maxHistogramBins steps in its range, is counted in a histogram; other data
+// is copied once and partially ordered with quickselect. The step of integer
+// data is taken as 1; for other data it is found in an extra pass that stops
+// as soon as the step gets too fine.
+func Summarize(data []float64, nodata float64, percentiles ...float64) (Summary, error) {
+	for _, p := range percentiles {
+		if p <= 0 || p >= 100 {
+			return Summary{}, fmt.Errorf("invalid percentile %v", p)
 		}
-		if checkNoData && v == nodata {
-			continue
+	}
+
+	scan := scanValues(data, nodata)
+	summary := Summary{Count: scan.count, Mean: scan.mean, Std: scan.std(), Min: scan.min, Max: scan.max}
+	if len(percentiles) == 0 {
+		return summary, nil
+	}
+	if scan.count == 0 {
+		return Summary{}, fmt.Errorf("no valid values")
+	}
+
+	indices := make([]int, len(percentiles))
+	for i, p := range percentiles {
+		indices[i] = percentileIndex(p, scan.count)
+	}
+
+	step := 1.0
+	if !scan.integral {
+		step = quantizationStep(data, nodata, scan.min, scan.max)
+	}
+	if step > 0 && (scan.max-scan.min)/step < maxHistogramBins {
+		if values, ok := histogramPercentiles(data, nodata, scan.min, scan.max, step, indices); ok {
+			summary.Percentiles = values
+			return summary, nil
 		}
+	}
+	summary.Percentiles = selectPercentiles(data, nodata, scan.count, indices)
+	return summary, nil
+}
 
-		count++
-		delta := v - mean
-		mean += delta / float64(count)
-		delta2 := v - mean
-		m2 += delta * delta2
+type valueScan struct {
+	count    int
+	mean     float64
+	m2       float64
+	min      float64
+	max      float64
+	integral bool
+}
+
+func (s valueScan) std() float64 {
+	if s.count == 0 {
+		return 0
 	}
+	return math.Sqrt(s.m2 / float64(s.count))
+}
 
-	if count == 0 {
-		return 0, 0
+// merge combines the scans of two disjoint sets of values.
+func (s valueScan) merge(o valueScan) valueScan {
+	if o.count == 0 {
+		return s
+	}
+	if s.count == 0 {
+		return o
 	}
 
-	variance := m2 / float64(count)
-	return mean, math.Sqrt(variance)
+	n := s.count + o.count
+	delta := o.mean - s.mean
+	return valueScan{
+		count:    n,
+		mean:     s.mean + delta*float64(o.count)/float64(n),
+		m2:       s.m2 + o.m2 + delta*delta*float64(s.count)*float64(o.count)/float64(n),
+		min:      math.Min(s.min, o.min),
+		max:      math.Max(s.max, o.max),
+		integral: s.integral && o.integral,
+	}
 }
 
-// Percentile returns the pth percentile value, ignoring nodata and NaN values.
-// p must be in the range (0, 100).
-func Percentile(data []float64, nodata float64, p float64) (float64, error) {
-	if p <= 0 || p >= 100 {
-		return 0, fmt.Errorf("invalid percentile %v", p)
+func scanValues(data []float64, nodata float64) valueScan {
+	scan := valueScan{integral: true}
+	for _, v := range data {
+		if !isValidPixel(v, nodata) {
+			continue
+		}
+
+		if scan.count == 0 {
+			scan.min, scan.max = v, v
+		}
+		scan.count++
+		delta := v - scan.mean
+		scan.mean += delta / float64(scan.count)
+		scan.m2 += delta * (v - scan.mean)
+		scan.min = math.Min(scan.min, v)
+		scan.max = math.Max(scan.max, v)
+		if scan.integral && v != math.Trunc(v) {
+			scan.integral = false
+		}
 	}
+	return scan
+}
+
+func percentileIndex(p float64, n int) int {
+	idx := int(math.Ceil((p/100.0)*float64(n))) - 1
+	return min(max(idx, 0), n-1)
+}
 
-	values := make([]float64, 0, len(data))
-	checkNoData := !math.IsNaN(nodata)
+// quantizationStep returns the common step of the valid values' offsets from
+// lo, their greatest common divisor up to rounding, or 0 once it is too fine
+// for maxHistogramBins bins to span [lo, hi].
+func quantizationStep(data []float64, nodata, lo, hi float64) float64 {
+	if hi == lo {
+		return 1
+	}
+	tol := (hi - lo) * 1e-9
+	minStep := (hi - lo) / maxHistogramBins
+	step := 0.0
 	for _, v := range data {
-		if math.IsNaN(v) {
+		if !isValidPixel(v, nodata) || v == lo {
 			continue
 		}
-		if checkNoData && v == nodata {
+		next := floatGCD(step, v-lo, tol)
+		if next <= minStep {
+			return 0
+		}
+		if next != step {
+			// hi-lo is a multiple of the step, so dividing it keeps rounding
+			// errors from accumulating over successive divisors.
+			step = (hi - lo) / math.Round((hi-lo)/next)
+		}
+	}
+	return step
+}
+
+// floatGCD returns the greatest common divisor of a and b, treating
+// remainders within tol of 0 or of the divisor as exact.
+func floatGCD(a, b, tol float64) float64 {
+	for b > tol {
+		r := math.Mod(a, b)
+		if b-r <= tol {
+			r = 0
+		}
+		a, b = b, r
+	}
+	return a
+}
+
+// histogramPercentiles counts the valid values in bins of width step from lo
+// and returns the value at each sorted index. Each bin keeps the value it
+// counts, so results are exact; it reports false when two distinct values
+// share a bin because the step was only approximate.
+func histogramPercentiles(data []float64, nodata, lo, hi, step float64, indices []int) ([]float64, bool) {
+	bins := int(math.Round((hi-lo)/step)) + 1
+	counts := make([]int, bins)
+	values := make([]float64, bins)
+	for _, v := range data {
+		if !isValidPixel(v, nodata) {
 			continue
 		}
-		values = append(values, v)
+		bin := min(int(math.Round((v-lo)/step)), bins-1)
+		if counts[bin] > 0 && values[bin] != v {
+			return nil, false
+		}
+		counts[bin]++
+		values[bin] = v
 	}
 
-	if len(values) == 0 {
-		return 0, fmt.Errorf("no valid values")
+	out := make([]float64, len(indices))
+	for i, idx := range indices {
+		seen := 0
+		for bin, c := range counts {
+			seen += c
+			if seen > idx {
+				out[i] = values[bin]
+				break
+			}
+		}
 	}
+	return out, true
+}
+
+func selectPercentiles(data []float64, nodata float64, count int, indices []int) []float64 {
+	values := make([]float64, 0, count)
+	for _, v := range data {
+		if isValidPixel(v, nodata) {
+			values = append(values, v)
+		}
+	}
+
+	order := make([]int, len(indices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return indices[order[a]] < indices[order[b]] })
+
+	// Each selection leaves smaller values to its left, so later, larger
+	// indices only need to search the remainder.
+	out := make([]float64, len(indices))
+	lo := 0
+	for _, i := range order {
+		k := indices[i]
+		selectKth(values[lo:], k-lo)
+		out[i] = values[k]
+		lo = k
+	}
+	return out
+}
+
+// selectKth partially orders values so that values[k] holds the value it
+// would have after sorting, with no larger value before it.
+func selectKth(values []float64, k int) {
+	lo, hi := 0, len(values)-1
+	for lo < hi {
+		pivot := medianOfThree(values[lo], values[lo+(hi-lo)/2], values[hi])
+
+		// Three-way partition keeps runs of equal values from degrading to
+		// quadratic time.
+		lt, i, gt := lo, lo, hi
+		for i <= gt {
+			switch v := values[i]; {
+			case v < pivot:
+				values[lt], values[i] = values[i], values[lt]
+				lt++
+				i++
+			case v > pivot:
+				values[i], values[gt] = values[gt], values[i]
+				gt--
+			default:
+				i++
+			}
+		}
+
+		switch {
+		case k < lt:
+			hi = lt - 1
+		case k > gt:
+			lo = gt + 1
+		default:
+			return
+		}
+	}
+}
 
-	sort.Float64s(values)
-	idx := int(math.Ceil((p/100.0)*float64(len(values)))) - 1
-	if idx < 0 {
-		return values[0], nil
+func medianOfThree(a, b, c float64) float64 {
+	if a > b {
+		a, b = b, a
 	}
-	if idx >= len(values) {
-		return values[len(values)-1], nil
+	if b > c {
+		b = c
 	}
-	return values[idx], nil
+	return math.Max(a, b)
 }
@@ -1,7 +1,10 @@
 package detect
 
 import (
+	"errors"
 	"math"
+	"math/rand"
+	"sort"
 	"testing"
 )
 
@@ -27,3 +30,153 @@ func TestMeanStdAllIgnored(t *testing.T) {
 		t.Fatalf("expected zero stats, got mean=%v std=%v", mean, std)
 	}
 }
+
+func TestPercentileMatchesSortedDefinition(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	generators := map[string]func() float64{
+		"byte":      func() float64 { return float64(rng.Intn(256)) },
+		"quantized": func() float64 { return float64(rng.Intn(5000) - 2500) },
+		"float":     func() float64 { return rng.NormFloat64() * 10 },
+		"repeated":  func() float64 { return float64(rng.Intn(4)) / 3 },
+		"wide":      func() float64 { return float64(rng.Int63n(1 << 40)) },
+		"decibels":  func() float64 { return float64(rng.Intn(4000))*0.01 - 25 },
+		"stepped":   func() float64 { return 3.7 + float64(rng.Intn(300))*0.25 },
+	}
+
+	for name, gen := range generators {
+		for _, n := range []int{1, 2, 7, 1000} {
+			data := make([]float64, n)
+			for i := range data {
+				data[i] = gen()
+				if rng.Intn(20) == 0 {
+					data[i] = -9999
+				}
+			}
+			data = append(data, math.NaN())
+
+			for _, p := range []float64{0.1, 1, 25, 50, 99.5, 99.99} {
+				want, wantErr := sortedPercentile(data, -9999, p)
+				got, err := Percentile(data, -9999, p)
+				if (err != nil) != (wantErr != nil) {
+					t.Fatalf("%s n=%d p=%v: expected error %v, got %v", name, n, p, wantErr, err)
+				}
+				if got != want {
+					t.Fatalf("%s n=%d p=%v: expected %v, got %v", name, n, p, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestQuantizationStep(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	decibels := make([]float64, 1000)
+	floats := make([]float64, 1000)
+	for i := range decibels {
+		decibels[i] = float64(rng.Intn(4000))*0.01 - 25
+		floats[i] = rng.NormFloat64()
+	}
+	decibels[0], decibels[1] = -25, 14.99
+
+	step := quantizationStep(decibels, -9999, -25, 14.99)
+	if math.Abs(step-0.01) > 1e-9 {
+		t.Fatalf("expected step 0.01, got %v", step)
+	}
+	if _, ok := histogramPercentiles(decibels, -9999, -25, 14.99, step, []int{500}); !ok {
+		t.Fatalf("expected every value in its own bin at step %v", step)
+	}
+	lo, hi := floats[0], floats[0]
+	for _, v := range floats {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if step := quantizationStep(floats, -9999, lo, hi); step != 0 {
+		t.Fatalf("expected no step for continuous floats, got %v", step)
+	}
+}
+
+func TestPercentileErrors(t *testing.T) {
+	if _, err := Percentile([]float64{1, 2}, -1, 0); err == nil {
+		t.Fatalf("expected error for percentile 0")
+	}
+	if _, err := Percentile([]float64{1, 2}, -1, 100); err == nil {
+		t.Fatalf("expected error for percentile 100")
+	}
+	if _, err := Percentile([]float64{-1, math.NaN()}, -1, 50); err == nil {
+		t.Fatalf("expected error for no valid values")
+	}
+}
+
+func TestSummarizeSinglePass(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for _, integral := range []bool{true, false} {
+		data := make([]float64, 5000)
+		for i := range data {
+			data[i] = rng.Float64() * 100
+			if integral {
+				data[i] = math.Floor(data[i])
+			}
+		}
+		data[17] = -9999
+
+		ps := []float64{99, 1, 50, 50, 90}
+		summary, err := Summarize(data, -9999, ps...)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		mean, std := MeanStd(data, -9999)
+		if summary.Count != 4999 || summary.Mean != mean || summary.Std != std {
+			t.Fatalf("expected count 4999 mean %v std %v, got %+v", mean, std, summary)
+		}
+		for i, p := range ps {
+			want, _ := sortedPercentile(data, -9999, p)
+			if summary.Percentiles[i] != want {
+				t.Fatalf("integral=%v p=%v: expected %v, got %v", integral, p, want, summary.Percentiles[i])
+			}
+		}
+
+		lo, _ := sortedPercentile(data, -9999, 1e-9)
+		if summary.Min != lo {
+			t.Fatalf("expected min %v, got %v", lo, summary.Min)
+		}
+	}
+}
+
+// sortedPercentile is the reference full-sort definition of Percentile.
+func sortedPercentile(data []float64, nodata, p float64) (float64, error) {
+	values := make([]float64, 0, len(data))
+	for _, v := range data {
+		if !math.IsNaN(v) && v != nodata {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return 0, errors.New("no valid values")
+	}
+	sort.Float64s(values)
+	idx := int(math.Ceil(p/100*float64(len(values)))) - 1
+	return values[min(max(idx, 0), len(values)-1)], nil
+}
+
+func BenchmarkPercentile(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]float64, 4000000)
+	for i := range data {
+		data[i] = float64(rng.Intn(256))
+	}
+	floats := make([]float64, len(data))
+	for i := range floats {
+		floats[i] = rng.ExpFloat64()
+	}
+
+	b.Run("byte", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Percentile(data, -9999, 99.5)
+		}
+	})
+	b.Run("float", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Percentile(floats, -9999, 99.5)
+		}
+	})
+}
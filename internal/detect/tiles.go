@@ -0,0 +1,460 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/parallel"
+)
+
+// TileConfig configures tiled processing. A zero Size processes the scene as
+// a single grid. With tiles, the scene is read, filtered, thresholded and
+// labeled one tile plus its halo at a time, so memory is bounded by the tile
+// size and the number of workers rather than the scene size.
+type TileConfig struct {
+	// Size is the side length of a tile core in pixels.
+	Size int
+	// Overlap is the minimum margin read around each core. It is raised to
+	// the reach of the morphology operations so tile cores match whole-scene
+	// processing exactly.
+	Overlap int
+	// Workers bounds the number of tiles processed concurrently; zero uses
+	// one per CPU. A parallel.Limiter in the context bounds them together
+	// with the caller's other pools.
+	Workers int
+}
+
+// tile is a core rectangle of the scene; x1 and y1 are exclusive.
+type tile struct {
+	x0, y0, x1, y1 int
+}
+
+// partialComponent accumulates the part of a component inside one tile.
+type partialComponent struct {
+	area     int
+	sum      float64
+	sumX     float64
+	sumY     float64
+	sumXX    float64
+	sumYY    float64
+	sumXY    float64
+	minX     int
+	minY     int
+	maxX     int
+	maxY     int
+	firstIdx int
+	border   bool
+}
+
+// tileLabels is the labeling of one tile core. Edge slices hold the 1-based
+// local label of each pixel along the core border, or 0 for unset pixels.
+type tileLabels struct {
+	parts  []partialComponent
+	top    []int32
+	bottom []int32
+	left   []int32
+	right  []int32
+}
+
+// TiledComponents thresholds, post-processes and labels scene tile by tile on
+// concurrent workers, stitching components that cross tile borders. The
+// result equals MaskComponents over the whole-scene mask, in the same order.
+func TiledComponents(ctx context.Context, scene Scene, threshold float64, invert bool, minAreaPx int, morph MorphologyConfig, cfg TileConfig) ([]Component, error) {
+	if scene.Width <= 0 || scene.Height <= 0 {
+		return nil, nil
+	}
+	if cfg.Size <= 0 {
+		return nil, fmt.Errorf("tile size must be positive, got %d", cfg.Size)
+	}
+
+	offsets, err := elementOffsets(morph.Element)
+	if err != nil {
+		return nil, err
+	}
+	margin := max(cfg.Overlap, morphologyReach(morph.Ops, elementRadius(offsets)))
+
+	tiles := splitTiles(scene.Width, scene.Height, cfg.Size)
+	opsOnly := MorphologyConfig{Ops: morph.Ops, Element: morph.Element}
+	coreMask := func(ctx context.Context, t tile) ([]bool, gdal.Grid, error) {
+		return tileMask(ctx, scene, t, margin, threshold, invert, opsOnly)
+	}
+
+	var holes [][]bool
+	if morph.FillHoles {
+		holes, err = findHoles(ctx, scene, tiles, coreMask, cfg.Workers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]tileLabels, len(tiles))
+	err = forEachTile(ctx, tiles, cfg.Workers, func(i int, t tile) error {
+		mask, core, err := coreMask(ctx, t)
+		if err != nil {
+			return err
+		}
+		if morph.FillHoles {
+			fillTileHoles(scene, core, t, mask, holes[i])
+		}
+		results[i], _ = labelTile(scene, core, t, mask, true)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stitchComponents(tiles, results, minAreaPx), nil
+}
+
+// TiledFootprint returns the footprint of c like ComponentFootprint over the
+// whole-scene mask, but rebuilds the mask only for a window one pixel
+// larger than the component's bounding box, so tiled runs never hold a
+// whole-scene mask. Every pixel of c, including filled holes, lies inside
+// its bounding box, so the window agrees with the scene mask on them.
+func TiledFootprint(ctx context.Context, scene Scene, c Component, gt [6]float64, threshold float64, invert bool, morph MorphologyConfig, cfg TileConfig, kind FootprintKind) ([]Polygon, error) {
+	if kind == FootprintNone || c.Area == 0 || len(c.Seeds) == 0 {
+		return nil, nil
+	}
+
+	offsets, err := elementOffsets(morph.Element)
+	if err != nil {
+		return nil, err
+	}
+	margin := max(cfg.Overlap, morphologyReach(morph.Ops, elementRadius(offsets)))
+
+	window := tile{
+		x0: max(c.MinX-1, 0),
+		y0: max(c.MinY-1, 0),
+		x1: min(c.MaxX+2, scene.Width),
+		y1: min(c.MaxY+2, scene.Height),
+	}
+	mask, core, err := tileMask(ctx, scene, window, margin, threshold, invert, MorphologyConfig{Ops: morph.Ops, Element: morph.Element})
+	if err != nil {
+		return nil, err
+	}
+	width := window.x1 - window.x0
+	if morph.FillHoles {
+		// Background enclosed within the window is enclosed in the scene, and
+		// the holes of c never reach the window border.
+		mask = fillHoles(mask, width, window.y1-window.y0)
+		clearNoData(core, mask)
+	}
+
+	local := c
+	local.MinX, local.MaxX = c.MinX-window.x0, c.MaxX-window.x0
+	local.MinY, local.MaxY = c.MinY-window.y0, c.MaxY-window.y0
+	local.Cx, local.Cy = c.Cx-float64(window.x0), c.Cy-float64(window.y0)
+	local.Seeds = make([]int, len(c.Seeds))
+	for i, seed := range c.Seeds {
+		local.Seeds[i] = (seed/scene.Width-window.y0)*width + seed%scene.Width - window.x0
+	}
+	lon, lat := PixelToLonLat(gt, float64(window.x0), float64(window.y0))
+	localGT := [6]float64{lon, gt[1], gt[2], lat, gt[4], gt[5]}
+
+	return ComponentFootprint(mask, width, local, localGT, kind), nil
+}
+
+// morphologyReach is how far, in pixels, a mask change can propagate through
+// the operation sequence.
+func morphologyReach(ops []MorphOp, radius int) int {
+	reach := 0
+	for _, op := range ops {
+		switch op {
+		case MorphOpen, MorphClose:
+			reach += 2 * radius
+		default:
+			reach += radius
+		}
+	}
+	return reach
+}
+
+func splitTiles(width, height, size int) []tile {
+	tiles := make([]tile, 0, ((width+size-1)/size)*((height+size-1)/size))
+	for y := 0; y < height; y += size {
+		for x := 0; x < width; x += size {
+			tiles = append(tiles, tile{x0: x, y0: y, x1: min(x+size, width), y1: min(y+size, height)})
+		}
+	}
+	return tiles
+}
+
+// forEachTile calls fn for every tile on up to workers goroutines; see
+// parallel.ForEach.
+func forEachTile(ctx context.Context, tiles []tile, workers int, fn func(i int, t tile) error) error {
+	return parallel.ForEach(ctx, len(tiles), workers, func(_ context.Context, i int) error {
+		return fn(i, tiles[i])
+	})
+}
+
+// tileMask returns the thresholded, morphology-processed mask of the tile
+// core and the core's pixels. It is computed over the core plus margin so
+// that operations near the core edge see the same neighbourhood as on the
+// whole scene.
+func tileMask(ctx context.Context, scene Scene, t tile, margin int, threshold float64, invert bool, morph MorphologyConfig) ([]bool, gdal.Grid, error) {
+	ext := scene.expand(t, margin)
+	sub, err := scene.window(ctx, ext)
+	if err != nil {
+		return nil, gdal.Grid{}, err
+	}
+	mask, err := ApplyMorphology(sub, ThresholdMask(sub, threshold, invert), morph)
+	if err != nil {
+		return nil, gdal.Grid{}, err
+	}
+
+	coreW := t.x1 - t.x0
+	core := make([]bool, coreW*(t.y1-t.y0))
+	for y := t.y0; y < t.y1; y++ {
+		src := (y-ext.y0)*sub.Width + (t.x0 - ext.x0)
+		copy(core[(y-t.y0)*coreW:(y-t.y0+1)*coreW], mask[src:src+coreW])
+	}
+	coreGrid := subGrid(sub, tile{x0: t.x0 - ext.x0, y0: t.y0 - ext.y0, x1: t.x1 - ext.x0, y1: t.y1 - ext.y0})
+	return core, coreGrid, nil
+}
+
+func subGrid(grid gdal.Grid, r tile) gdal.Grid {
+	width := r.x1 - r.x0
+	height := r.y1 - r.y0
+	data := make([]float64, width*height)
+	for y := r.y0; y < r.y1; y++ {
+		copy(data[(y-r.y0)*width:], grid.Data[y*grid.Width+r.x0:y*grid.Width+r.x1])
+	}
+	return gdal.Grid{Width: width, Height: height, NoData: grid.NoData, Data: data}
+}
+
+// labelTile labels set (or, with foreground false, unset) pixels of a core
+// mask and accumulates scene-coordinate statistics for each local component
+// from the core's pixels. It also returns the per-pixel local labels of the
+// core.
+func labelTile(scene Scene, core gdal.Grid, t tile, mask []bool, foreground bool) (tileLabels, []int32) {
+	width := t.x1 - t.x0
+	height := t.y1 - t.y0
+	labels := make([]int32, width*height)
+	parts := make([]partialComponent, 0)
+	stack := make([]int, 0)
+
+	for start := range labels {
+		if labels[start] != 0 || mask[start] != foreground {
+			continue
+		}
+
+		label := int32(len(parts) + 1)
+		part := partialComponent{minX: scene.Width, minY: scene.Height, maxX: -1, maxY: -1, firstIdx: scene.Width * scene.Height}
+		labels[start] = label
+		stack = append(stack[:0], start)
+
+		for len(stack) > 0 {
+			cur := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			lx := cur % width
+			ly := cur / width
+			x := t.x0 + lx
+			y := t.y0 + ly
+			idx := y*scene.Width + x
+
+			part.area++
+			part.sum += core.Data[cur]
+			part.sumX += float64(x)
+			part.sumY += float64(y)
+			part.sumXX += float64(x * x)
+			part.sumYY += float64(y * y)
+			part.sumXY += float64(x * y)
+			part.minX, part.minY = min(part.minX, x), min(part.minY, y)
+			part.maxX, part.maxY = max(part.maxX, x), max(part.maxY, y)
+			part.firstIdx = min(part.firstIdx, idx)
+			if x == 0 || y == 0 || x == scene.Width-1 || y == scene.Height-1 {
+				part.border = true
+			}
+
+			visit := func(n int) {
+				if labels[n] == 0 && mask[n] == foreground {
+					labels[n] = label
+					stack = append(stack, n)
+				}
+			}
+			if lx > 0 {
+				visit(cur - 1)
+			}
+			if lx+1 < width {
+				visit(cur + 1)
+			}
+			if ly > 0 {
+				visit(cur - width)
+			}
+			if ly+1 < height {
+				visit(cur + width)
+			}
+		}
+
+		parts = append(parts, part)
+	}
+
+	result := tileLabels{
+		parts:  parts,
+		top:    append([]int32(nil), labels[:width]...),
+		bottom: append([]int32(nil), labels[(height-1)*width:]...),
+		left:   make([]int32, height),
+		right:  make([]int32, height),
+	}
+	for y := 0; y < height; y++ {
+		result.left[y] = labels[y*width]
+		result.right[y] = labels[y*width+width-1]
+	}
+	return result, labels
+}
+
+// stitchTiles unions local components that touch across tile borders. It
+// returns the union-find parents over global ids and each tile's id offset.
+func stitchTiles(tiles []tile, results []tileLabels) ([]int, []int) {
+	offsets := make([]int, len(tiles))
+	total := 0
+	byOrigin := make(map[[2]int]int, len(tiles))
+	for i, t := range tiles {
+		offsets[i] = total
+		total += len(results[i].parts)
+		byOrigin[[2]int{t.x0, t.y0}] = i
+	}
+
+	parent := make([]int, total)
+	for i := range parent {
+		parent[i] = i
+	}
+
+	link := func(ti int, a int32, tj int, b int32) {
+		if a != 0 && b != 0 {
+			union(parent, offsets[ti]+int(a)-1, offsets[tj]+int(b)-1)
+		}
+	}
+
+	for i, t := range tiles {
+		if j, ok := byOrigin[[2]int{t.x1, t.y0}]; ok {
+			for y := range results[i].right {
+				link(i, results[i].right[y], j, results[j].left[y])
+			}
+		}
+		if j, ok := byOrigin[[2]int{t.x0, t.y1}]; ok {
+			for x := range results[i].bottom {
+				link(i, results[i].bottom[x], j, results[j].top[x])
+			}
+		}
+	}
+
+	return parent, offsets
+}
+
+// mergePartials combines the stitched partial components by root.
+func mergePartials(tiles []tile, results []tileLabels, parent, offsets []int) map[int]*partialComponent {
+	merged := make(map[int]*partialComponent)
+	for i := range tiles {
+		for l, part := range results[i].parts {
+			root := find(parent, offsets[i]+l)
+			acc, ok := merged[root]
+			if !ok {
+				p := part
+				merged[root] = &p
+				continue
+			}
+			mergePartial(acc, part)
+		}
+	}
+	return merged
+}
+
+func mergePartial(acc *partialComponent, part partialComponent) {
+	acc.area += part.area
+	acc.sum += part.sum
+	acc.sumX += part.sumX
+	acc.sumY += part.sumY
+	acc.sumXX += part.sumXX
+	acc.sumYY += part.sumYY
+	acc.sumXY += part.sumXY
+	acc.minX, acc.minY = min(acc.minX, part.minX), min(acc.minY, part.minY)
+	acc.maxX, acc.maxY = max(acc.maxX, part.maxX), max(acc.maxY, part.maxY)
+	acc.firstIdx = min(acc.firstIdx, part.firstIdx)
+	acc.border = acc.border || part.border
+}
+
+func (p partialComponent) component() Component {
+	return Component{
+		Area:  p.area,
+		Sum:   p.sum,
+		Cx:    p.sumX / float64(p.area),
+		Cy:    p.sumY / float64(p.area),
+		SumXX: p.sumXX,
+		SumYY: p.sumYY,
+		SumXY: p.sumXY,
+		MinX:  p.minX,
+		MinY:  p.minY,
+		MaxX:  p.maxX,
+		MaxY:  p.maxY,
+		Seeds: []int{p.firstIdx},
+	}
+}
+
+func stitchComponents(tiles []tile, results []tileLabels, minAreaPx int) []Component {
+	parent, offsets := stitchTiles(tiles, results)
+	merged := mergePartials(tiles, results, parent, offsets)
+
+	parts := make([]*partialComponent, 0, len(merged))
+	for _, part := range merged {
+		if part.area < minAreaPx {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].firstIdx < parts[j].firstIdx })
+
+	components := make([]Component, 0, len(parts))
+	for _, part := range parts {
+		components = append(components, part.component())
+	}
+	return components
+}
+
+// findHoles labels the background of every tile and reports, per tile and
+// local label, whether the background component is a hole: one that does not
+// reach the scene border once stitched.
+func findHoles(ctx context.Context, scene Scene, tiles []tile, coreMask func(context.Context, tile) ([]bool, gdal.Grid, error), workers int) ([][]bool, error) {
+	results := make([]tileLabels, len(tiles))
+	err := forEachTile(ctx, tiles, workers, func(i int, t tile) error {
+		mask, core, err := coreMask(ctx, t)
+		if err != nil {
+			return err
+		}
+		results[i], _ = labelTile(scene, core, t, mask, false)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	parent, offsets := stitchTiles(tiles, results)
+	merged := mergePartials(tiles, results, parent, offsets)
+
+	holes := make([][]bool, len(tiles))
+	for i := range tiles {
+		holes[i] = make([]bool, len(results[i].parts))
+		for l := range results[i].parts {
+			holes[i][l] = !merged[find(parent, offsets[i]+l)].border
+		}
+	}
+	return holes, nil
+}
+
+// fillTileHoles sets the core background pixels whose local background
+// component is a hole, then clears NoData as ApplyMorphology does. Labeling
+// is deterministic, so local labels match those seen by findHoles.
+func fillTileHoles(scene Scene, core gdal.Grid, t tile, mask []bool, holes []bool) {
+	_, labels := labelTile(scene, core, t, mask, false)
+	for idx, label := range labels {
+		if label != 0 && holes[label-1] {
+			mask[idx] = true
+		}
+		if !isValidPixel(core.Data[idx], core.NoData) {
+			mask[idx] = false
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package detect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"boatdetect/internal/gdal"
+)
+
+func TestTiledComponentsMatchesWholeScene(t *testing.T) {
+	morphs := []MorphologyConfig{
+		{},
+		{FillHoles: true},
+		{Ops: []MorphOp{MorphClose}},
+		{Ops: []MorphOp{MorphOpen, MorphDilate}, Element: StructuringElement{Shape: ElementDisk, Radius: 2}},
+		{Ops: []MorphOp{MorphClose, MorphErode}, Element: StructuringElement{Shape: ElementCross, Radius: 1}, FillHoles: true},
+	}
+	sizes := []struct{ width, height int }{{1, 1}, {17, 9}, {64, 48}, {101, 73}}
+	tileSizes := []int{2, 7, 16, 200}
+
+	rng := rand.New(rand.NewSource(7))
+	for _, size := range sizes {
+		grid := randomIntGrid(rng, size.width, size.height)
+		for mi, morph := range morphs {
+			for _, invert := range []bool{false, true} {
+				threshold := 6.0
+				if invert {
+					threshold = 3
+				}
+
+				mask, err := ApplyMorphology(grid, ThresholdMask(grid, threshold, invert), morph)
+				if err != nil {
+					t.Fatalf("whole scene: %v", err)
+				}
+				want := MaskComponents(grid, mask, 2)
+
+				for _, tileSize := range tileSizes {
+					name := fmt.Sprintf("%dx%d/morph%d/invert=%v/tile%d", size.width, size.height, mi, invert, tileSize)
+					got, err := TiledComponents(context.Background(), GridScene(grid), threshold, invert, 2, morph, TileConfig{Size: tileSize, Workers: 3})
+					if err != nil {
+						t.Fatalf("%s: expected no error, got %v", name, err)
+					}
+					if len(got) == 0 && len(want) == 0 {
+						continue
+					}
+					if !reflect.DeepEqual(got, want) {
+						t.Fatalf("%s: tiled components differ from whole scene\ngot  %+v\nwant %+v", name, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestTiledFootprintMatchesWholeScene(t *testing.T) {
+	morphs := []MorphologyConfig{
+		{},
+		{FillHoles: true},
+		{Ops: []MorphOp{MorphClose}, Element: StructuringElement{Shape: ElementSquare, Radius: 1}, FillHoles: true},
+	}
+	gt := [6]float64{10, 1e-4, 0, 50, 0, -1e-4}
+
+	rng := rand.New(rand.NewSource(11))
+	grid := randomIntGrid(rng, 40, 30)
+	for mi, morph := range morphs {
+		mask, err := ApplyMorphology(grid, ThresholdMask(grid, 6, false), morph)
+		if err != nil {
+			t.Fatalf("whole scene: %v", err)
+		}
+		components := MergeComponents(MaskComponents(grid, mask, 2), gt, 15)
+		if len(components) == 0 {
+			t.Fatalf("morph%d: expected components to trace", mi)
+		}
+		for _, kind := range []FootprintKind{FootprintOutline, FootprintHull, FootprintRectangle} {
+			for i, c := range components {
+				want := ComponentFootprint(mask, grid.Width, c, gt, kind)
+				got, err := TiledFootprint(context.Background(), GridScene(grid), c, gt, 6, false, morph, TileConfig{Size: 8}, kind)
+				if err != nil {
+					t.Fatalf("morph%d/%s/%d: expected no error, got %v", mi, kind, i, err)
+				}
+				if !footprintsClose(got, want) {
+					t.Fatalf("morph%d/%s/%d: tiled footprint differs\ngot  %v\nwant %v", mi, kind, i, got, want)
+				}
+			}
+		}
+	}
+}
+
+// footprintsClose compares footprints up to floating-point rounding of the
+// shifted geotransform.
+func footprintsClose(a, b []Polygon) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if len(a[i][j]) != len(b[i][j]) {
+				return false
+			}
+			for k := range a[i][j] {
+				if math.Abs(a[i][j][k][0]-b[i][j][k][0]) > 1e-9 || math.Abs(a[i][j][k][1]-b[i][j][k][1]) > 1e-9 {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func TestTiledComponentsStitchesAcrossTiles(t *testing.T) {
+	grid := gridFromRows(
+		"#####.....",
+		"....#.....",
+		"....######",
+		".........#",
+	)
+
+	got, err := TiledComponents(context.Background(), GridScene(grid), 1, false, 1, MorphologyConfig{}, TileConfig{Size: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(got) != 1 || got[0].Area != 13 {
+		t.Fatalf("expected one snake component of area 13, got %+v", got)
+	}
+}
+
+func TestTiledComponentsErrors(t *testing.T) {
+	grid := gridFromRows("#.#", "###")
+
+	if _, err := TiledComponents(context.Background(), GridScene(grid), 1, false, 1, MorphologyConfig{}, TileConfig{}); err == nil {
+		t.Fatalf("expected error for zero tile size")
+	}
+
+	bad := MorphologyConfig{Ops: []MorphOp{MorphClose}, Element: StructuringElement{Shape: "hexagon"}}
+	if _, err := TiledComponents(context.Background(), GridScene(grid), 1, false, 1, bad, TileConfig{Size: 2}); err == nil {
+		t.Fatalf("expected error for unknown element")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := TiledComponents(ctx, GridScene(grid), 1, false, 1, MorphologyConfig{}, TileConfig{Size: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+}
+
+// randomIntGrid returns small integer values, so component sums are exact
+// regardless of accumulation order, with scattered NoData pixels.
+func randomIntGrid(rng *rand.Rand, width, height int) gdal.Grid {
+	data := make([]float64, width*height)
+	for i := range data {
+		data[i] = float64(rng.Intn(10))
+		if rng.Intn(40) == 0 {
+			data[i] = -9999
+		}
+	}
+	return gdal.Grid{Width: width, Height: height, NoData: -9999, Data: data}
+}
@@ -0,0 +1,299 @@
+// Package eval scores detections against hand-labelled vessel positions.
+package eval
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"boatdetect/internal/detect"
+)
+
+// Method selects how detections are matched to truth positions.
+type Method string
+
+const (
+	// MethodHungarian finds the most matches with the least total distance.
+	MethodHungarian Method = "hungarian"
+	// MethodGreedy repeatedly matches the closest remaining pair.
+	MethodGreedy Method = "greedy"
+)
+
+// ParseMethod parses a matching method name.
+func ParseMethod(name string) (Method, error) {
+	switch m := Method(strings.ToLower(name)); m {
+	case MethodHungarian, MethodGreedy:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown matching method %q", name)
+	}
+}
+
+// distanceUnits maps distance suffixes to metres.
+var distanceUnits = []struct {
+	suffix string
+	metres float64
+}{
+	{"nmi", 1852},
+	{"km", 1000},
+	{"nm", 1852},
+	{"m", 1},
+}
+
+// ParseDistance parses a distance such as "100m", "0.5km" or "1nmi" into
+// metres. A bare number is in metres.
+func ParseDistance(s string) (float64, error) {
+	value, scale := strings.TrimSpace(s), 1.0
+	for _, unit := range distanceUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, scale = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.metres
+			break
+		}
+	}
+	d, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(d) || math.IsInf(d, 0) {
+		return 0, fmt.Errorf("invalid distance %q", s)
+	}
+	return d * scale, nil
+}
+
+// Config controls matching.
+type Config struct {
+	Method Method
+	// RadiusM is the largest distance at which a detection matches a truth
+	// position.
+	RadiusM float64
+	// TimeWindow is the largest time difference at which a detection matches
+	// a truth position when both carry a time; 0 ignores times.
+	TimeWindow time.Duration
+}
+
+// Match pairs a detection with a truth position by index.
+type Match struct {
+	Detection int     `json:"detection"`
+	Truth     int     `json:"truth"`
+	DistanceM float64 `json:"distance_m"`
+}
+
+// Score holds the detection counts and derived metrics of a set of scenes.
+// Precision, recall and F1 are 0 when undefined.
+type Score struct {
+	SceneID      string     `json:"scene_id,omitempty"`
+	TP           int        `json:"tp"`
+	FP           int        `json:"fp"`
+	FN           int        `json:"fn"`
+	Precision    float64    `json:"precision"`
+	Recall       float64    `json:"recall"`
+	F1           float64    `json:"f1"`
+	Localization ErrorStats `json:"localization_error_m"`
+}
+
+// ErrorStats summarizes the distances of matched pairs in metres.
+type ErrorStats struct {
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+	Max    float64 `json:"max"`
+	RMSE   float64 `json:"rmse"`
+}
+
+// Result is the outcome of an evaluation.
+type Result struct {
+	Method     Method  `json:"method"`
+	RadiusM    float64 `json:"radius_m"`
+	Detections int     `json:"detections"`
+	Truth      int     `json:"truth"`
+	Overall    Score   `json:"overall"`
+	// Scenes breaks the score down by scene, sorted by ID. Detections and
+	// truth positions without a scene count towards the scene of their
+	// match, or towards the scene with an empty ID when unmatched.
+	Scenes  []Score `json:"scenes"`
+	Matches []Match `json:"matches"`
+}
+
+// Evaluate matches detections to truth positions and scores the result.
+func Evaluate(detections, truth []Point, cfg Config) (Result, error) {
+	if cfg.RadiusM <= 0 {
+		return Result{}, fmt.Errorf("radius must be positive")
+	}
+
+	pairs := candidatePairs(detections, truth, cfg)
+	var matches []Match
+	switch cfg.Method {
+	case MethodGreedy:
+		matches = matchGreedy(pairs)
+	case MethodHungarian, "":
+		matches = matchHungarian(pairs, len(detections), cfg.RadiusM)
+	default:
+		return Result{}, fmt.Errorf("unknown matching method %q", cfg.Method)
+	}
+	if matches == nil {
+		matches = []Match{}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Detection < matches[j].Detection })
+
+	method := cfg.Method
+	if method == "" {
+		method = MethodHungarian
+	}
+	return Result{
+		Method:     method,
+		RadiusM:    cfg.RadiusM,
+		Detections: len(detections),
+		Truth:      len(truth),
+		Overall:    score("", len(detections), len(truth), distances(matches)),
+		Scenes:     sceneScores(detections, truth, matches),
+		Matches:    matches,
+	}, nil
+}
+
+// candidatePairs returns every detection and truth pair that may match.
+func candidatePairs(detections, truth []Point, cfg Config) []Match {
+	var pairs []Match
+	for i, d := range detections {
+		for j, t := range truth {
+			if !sameScene(d, t) || !withinTime(d, t, cfg.TimeWindow) {
+				continue
+			}
+			if dist := detect.DistanceMetres(d.Lon, d.Lat, t.Lon, t.Lat); dist <= cfg.RadiusM {
+				pairs = append(pairs, Match{Detection: i, Truth: j, DistanceM: dist})
+			}
+		}
+	}
+	return pairs
+}
+
+// sameScene reports whether a and b may belong to the same scene; a point
+// without a scene may match any.
+func sameScene(a, b Point) bool {
+	return a.SceneID == "" || b.SceneID == "" || a.SceneID == b.SceneID
+}
+
+func withinTime(a, b Point, window time.Duration) bool {
+	if window <= 0 || a.Time.IsZero() || b.Time.IsZero() {
+		return true
+	}
+	return a.Time.Sub(b.Time).Abs() <= window
+}
+
+// matchGreedy takes pairs closest first, skipping those whose detection or
+// truth position is already matched.
+func matchGreedy(pairs []Match) []Match {
+	sorted := append([]Match(nil), pairs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].DistanceM < sorted[j].DistanceM })
+
+	usedDetection := make(map[int]bool)
+	usedTruth := make(map[int]bool)
+	var matches []Match
+	for _, pair := range sorted {
+		if usedDetection[pair.Detection] || usedTruth[pair.Truth] {
+			continue
+		}
+		usedDetection[pair.Detection], usedTruth[pair.Truth] = true, true
+		matches = append(matches, pair)
+	}
+	return matches
+}
+
+func distances(matches []Match) []float64 {
+	d := make([]float64, len(matches))
+	for i, m := range matches {
+		d[i] = m.DistanceM
+	}
+	return d
+}
+
+// sceneScores scores each scene of the detections and truth positions. A
+// point without a scene is credited to the scene of its match, so both
+// sides of a match always count towards the same scene.
+func sceneScores(detections, truth []Point, matches []Match) []Score {
+	detectionScene := make([]string, len(detections))
+	for i, d := range detections {
+		detectionScene[i] = d.SceneID
+	}
+	truthScene := make([]string, len(truth))
+	for j, t := range truth {
+		truthScene[j] = t.SceneID
+	}
+	matched := make(map[string][]float64)
+	for _, m := range matches {
+		scene := detectionScene[m.Detection]
+		if scene == "" {
+			scene = truthScene[m.Truth]
+		}
+		detectionScene[m.Detection], truthScene[m.Truth] = scene, scene
+		matched[scene] = append(matched[scene], m.DistanceM)
+	}
+
+	nDetections := make(map[string]int)
+	nTruth := make(map[string]int)
+	for _, scene := range detectionScene {
+		nDetections[scene]++
+	}
+	for _, scene := range truthScene {
+		nTruth[scene]++
+	}
+
+	scenes := make([]string, 0, len(nDetections)+len(nTruth))
+	for scene := range nDetections {
+		scenes = append(scenes, scene)
+	}
+	for scene := range nTruth {
+		if _, ok := nDetections[scene]; !ok {
+			scenes = append(scenes, scene)
+		}
+	}
+	sort.Strings(scenes)
+
+	scores := make([]Score, len(scenes))
+	for i, scene := range scenes {
+		scores[i] = score(scene, nDetections[scene], nTruth[scene], matched[scene])
+	}
+	return scores
+}
+
+// score derives the counts and metrics of nDetections detections and nTruth
+// truth positions, of which the pairs at the given distances matched.
+func score(sceneID string, nDetections, nTruth int, matched []float64) Score {
+	s := Score{
+		SceneID:      sceneID,
+		TP:           len(matched),
+		FP:           nDetections - len(matched),
+		FN:           nTruth - len(matched),
+		Localization: errorStats(matched),
+	}
+	if nDetections > 0 {
+		s.Precision = float64(s.TP) / float64(nDetections)
+	}
+	if nTruth > 0 {
+		s.Recall = float64(s.TP) / float64(nTruth)
+	}
+	if s.Precision+s.Recall > 0 {
+		s.F1 = 2 * s.Precision * s.Recall / (s.Precision + s.Recall)
+	}
+	return s
+}
+
+func errorStats(distances []float64) ErrorStats {
+	if len(distances) == 0 {
+		return ErrorStats{}
+	}
+	summary, err := detect.Summarize(distances, math.NaN(), 50, 90)
+	if err != nil {
+		return ErrorStats{}
+	}
+	sumSquares := 0.0
+	for _, d := range distances {
+		sumSquares += d * d
+	}
+	return ErrorStats{
+		Mean:   summary.Mean,
+		Median: summary.Percentiles[0],
+		P90:    summary.Percentiles[1],
+		Max:    summary.Max,
+		RMSE:   math.Sqrt(sumSquares / float64(len(distances))),
+	}
+}
@@ -0,0 +1,197 @@
+package eval
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"boatdetect/internal/geojson"
+)
+
+// metresPerDegree is the length of one degree of longitude at the equator.
+const metresPerDegree = 6371008.8 * math.Pi / 180
+
+// at returns a point x metres east of 0,0 on the equator.
+func at(sceneID string, x float64) Point {
+	return Point{SceneID: sceneID, Lon: x / metresPerDegree}
+}
+
+func TestParseDistance(t *testing.T) {
+	for s, want := range map[string]float64{"100": 100, "100m": 100, "0.5km": 500, "2 nmi": 3704, "1nm": 1852} {
+		got, err := ParseDistance(s)
+		if err != nil || math.Abs(got-want) > 1e-9 {
+			t.Fatalf("%q: expected %v, got %v (%v)", s, want, got, err)
+		}
+	}
+	for _, s := range []string{"", "m", "ten metres", "NaNm"} {
+		if _, err := ParseDistance(s); err == nil {
+			t.Fatalf("%q: expected error", s)
+		}
+	}
+}
+
+func TestEvaluateHungarianBeatsGreedy(t *testing.T) {
+	// The closest pair (d1, t0) leaves d0 and t1 without a partner.
+	detections := []Point{at("", 0), at("", 100)}
+	truth := []Point{at("", 55), at("", 150)}
+
+	greedy, err := Evaluate(detections, truth, Config{Method: MethodGreedy, RadiusM: 60})
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if greedy.Overall.TP != 1 || greedy.Overall.FP != 1 || greedy.Overall.FN != 1 {
+		t.Fatalf("expected greedy 1/1/1, got %+v", greedy.Overall)
+	}
+
+	optimal, err := Evaluate(detections, truth, Config{Method: MethodHungarian, RadiusM: 60})
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if optimal.Overall.TP != 2 || optimal.Overall.F1 != 1 {
+		t.Fatalf("expected two matches, got %+v", optimal.Overall)
+	}
+	if optimal.Matches[0].Truth != 0 || optimal.Matches[1].Truth != 1 {
+		t.Fatalf("expected d0-t0 and d1-t1, got %+v", optimal.Matches)
+	}
+	if loc := optimal.Overall.Localization; math.Abs(loc.Max-55) > 0.01 || math.Abs(loc.Mean-52.5) > 0.01 {
+		t.Fatalf("expected errors of 50 and 55 m, got %+v", loc)
+	}
+}
+
+func TestEvaluateScenesAndCounts(t *testing.T) {
+	detections := []Point{at("A", 0), at("A", 1000), at("B", 0)}
+	truth := []Point{at("A", 10), at("B", 2000), at("", 20)}
+
+	result, err := Evaluate(detections, truth, Config{RadiusM: 100})
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if result.Overall.TP != 2 || result.Overall.FP != 1 || result.Overall.FN != 1 {
+		t.Fatalf("expected 2/1/1 overall, got %+v", result.Overall)
+	}
+	if len(result.Scenes) != 2 {
+		t.Fatalf("expected scenes A and B, got %+v", result.Scenes)
+	}
+	a, b := result.Scenes[0], result.Scenes[1]
+	if a.SceneID != "A" || a.TP != 1 || a.FP != 1 || a.FN != 0 {
+		t.Fatalf("expected A 1/1/0, got %+v", a)
+	}
+	// The unscoped truth position matched the scene B detection.
+	if b.SceneID != "B" || b.TP != 1 || b.FP != 0 || b.FN != 1 || b.Precision != 1 || b.Recall != 0.5 {
+		t.Fatalf("expected B 1/0/1, got %+v", b)
+	}
+}
+
+func TestEvaluateCreditsUnscopedDetectionToTruthScene(t *testing.T) {
+	detections := []Point{at("", 0), at("", 5000)}
+	truth := []Point{at("A", 10)}
+
+	result, err := Evaluate(detections, truth, Config{RadiusM: 100})
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if len(result.Scenes) != 2 {
+		t.Fatalf("expected scenes \"\" and A, got %+v", result.Scenes)
+	}
+	unscoped, a := result.Scenes[0], result.Scenes[1]
+	if a.SceneID != "A" || a.TP != 1 || a.FP != 0 || a.FN != 0 || a.Precision != 1 {
+		t.Fatalf("expected A 1/0/0, got %+v", a)
+	}
+	if unscoped.SceneID != "" || unscoped.TP != 0 || unscoped.FP != 1 || unscoped.FN != 0 {
+		t.Fatalf("expected the unmatched detection alone without a scene, got %+v", unscoped)
+	}
+}
+
+func TestEvaluateTimeWindow(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	d := at("", 0)
+	d.Time = t0
+	truth := at("", 10)
+	truth.Time = t0.Add(2 * time.Hour)
+
+	for window, want := range map[time.Duration]int{0: 1, time.Hour: 0, 3 * time.Hour: 1} {
+		result, err := Evaluate([]Point{d}, []Point{truth}, Config{RadiusM: 100, TimeWindow: window})
+		if err != nil {
+			t.Fatalf("evaluate: %v", err)
+		}
+		if result.Overall.TP != want {
+			t.Fatalf("window %v: expected %d matches, got %d", window, want, result.Overall.TP)
+		}
+	}
+}
+
+func TestEvaluateRejectsZeroRadius(t *testing.T) {
+	if _, err := Evaluate(nil, nil, Config{}); err == nil {
+		t.Fatalf("expected error for zero radius")
+	}
+}
+
+func TestAssignMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 50; trial++ {
+		n := 1 + rng.Intn(4)
+		m := n + rng.Intn(3)
+		cost := make([][]float64, n)
+		for i := range cost {
+			cost[i] = make([]float64, m)
+			for j := range cost[i] {
+				cost[i][j] = float64(rng.Intn(20))
+			}
+		}
+
+		got := 0.0
+		for i, j := range assign(cost) {
+			got += cost[i][j]
+		}
+		if want := bruteForce(cost, 0, make([]bool, m)); got != want {
+			t.Fatalf("trial %d: expected cost %v, got %v for %v", trial, want, got, cost)
+		}
+	}
+}
+
+func bruteForce(cost [][]float64, row int, used []bool) float64 {
+	if row == len(cost) {
+		return 0
+	}
+	best := math.Inf(1)
+	for j := range used {
+		if used[j] {
+			continue
+		}
+		used[j] = true
+		best = math.Min(best, cost[row][j]+bruteForce(cost, row+1, used))
+		used[j] = false
+	}
+	return best
+}
+
+func TestPoints(t *testing.T) {
+	square := [][]geojson.Position{{{0, 0}, {2, 0}, {2, 2}, {0, 2}}}
+	fc := geojson.FeatureCollection{Features: []geojson.Feature{
+		geojson.NewFeature(geojson.NewGeometryCollection(geojson.NewPoint(5, 6), geojson.NewPolygon(square)),
+			geojson.Properties{"scene_id": "S1A_IW_GRDH_1SDV_20240101T052312_20240101T052337_051900_0644C5_1A2B"}),
+		geojson.NewFeature(geojson.NewPolygon(square), geojson.Properties{"time": "2024-03-01T10:00:00Z"}),
+	}}
+
+	points, err := Points(fc)
+	if err != nil {
+		t.Fatalf("points: %v", err)
+	}
+	if p := points[0]; p.Lon != 5 || p.Lat != 6 || !p.Time.Equal(time.Date(2024, 1, 1, 5, 23, 12, 0, time.UTC)) {
+		t.Fatalf("expected collection point and scene time, got %+v", p)
+	}
+	if p := points[1]; p.Lon != 1 || p.Lat != 1 || p.SceneID != "" || p.Time.Month() != time.March {
+		t.Fatalf("expected polygon mean and property time, got %+v", p)
+	}
+
+	for _, bad := range []geojson.Feature{
+		{},
+		geojson.NewFeature(geojson.Geometry{Type: geojson.PointType, Point: geojson.Position{}}, nil),
+		geojson.NewFeature(geojson.NewMultiPoint([]geojson.Position{{1, 2}, {3}}), nil),
+	} {
+		if _, err := Points(geojson.FeatureCollection{Features: []geojson.Feature{bad}}); err == nil {
+			t.Fatalf("expected error for %+v", bad.Geometry)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package eval
+
+import "math"
+
+// matchHungarian finds the largest set of pairs with the least total
+// distance. Pairs are split into connected groups, each solved as an
+// assignment problem in which a missing pair costs more than any number of
+// real ones, so the number of matches is maximised first.
+func matchHungarian(pairs []Match, nDetections int, radiusM float64) []Match {
+	var matches []Match
+	for _, group := range pairGroups(pairs, nDetections) {
+		matches = append(matches, assignGroup(group, radiusM)...)
+	}
+	return matches
+}
+
+// pairGroups splits pairs into groups sharing no detection or truth index.
+func pairGroups(pairs []Match, nDetections int) [][]Match {
+	parent := make(map[int]int)
+	var find func(int) int
+	find = func(i int) int {
+		if _, ok := parent[i]; !ok {
+			parent[i] = i
+		}
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	// Truth indices are offset past the detections so both share one set.
+	for _, pair := range pairs {
+		a, b := find(pair.Detection), find(nDetections+pair.Truth)
+		if a != b {
+			parent[a] = b
+		}
+	}
+
+	index := make(map[int]int)
+	var groups [][]Match
+	for _, pair := range pairs {
+		root := find(pair.Detection)
+		i, ok := index[root]
+		if !ok {
+			i = len(groups)
+			index[root] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], pair)
+	}
+	return groups
+}
+
+// assignGroup solves the assignment problem of one connected group.
+func assignGroup(group []Match, radiusM float64) []Match {
+	rowOf, colOf := make(map[int]int), make(map[int]int)
+	var rows, cols []int
+	for _, pair := range group {
+		if _, ok := rowOf[pair.Detection]; !ok {
+			rowOf[pair.Detection] = len(rows)
+			rows = append(rows, pair.Detection)
+		}
+		if _, ok := colOf[pair.Truth]; !ok {
+			colOf[pair.Truth] = len(cols)
+			cols = append(cols, pair.Truth)
+		}
+	}
+
+	transpose := len(rows) > len(cols)
+	n, m := len(rows), len(cols)
+	if transpose {
+		n, m = m, n
+	}
+	missing := radiusM*float64(n+1) + 1
+	cost := make([][]float64, n)
+	for i := range cost {
+		cost[i] = make([]float64, m)
+		for j := range cost[i] {
+			cost[i][j] = missing
+		}
+	}
+	byCell := make(map[[2]int]Match, len(group))
+	for _, pair := range group {
+		r, c := rowOf[pair.Detection], colOf[pair.Truth]
+		if transpose {
+			r, c = c, r
+		}
+		cost[r][c] = pair.DistanceM
+		byCell[[2]int{r, c}] = pair
+	}
+
+	var matches []Match
+	for r, c := range assign(cost) {
+		if pair, ok := byCell[[2]int{r, c}]; ok {
+			matches = append(matches, pair)
+		}
+	}
+	return matches
+}
+
+// assign returns the column assigned to each row of cost so that the total
+// cost is least, using the Hungarian method with potentials. cost must have
+// no more rows than columns.
+func assign(cost [][]float64) []int {
+	n, m := len(cost), len(cost[0])
+	// Rows and columns are 1-based below; column 0 is a sentinel.
+	u := make([]float64, n+1)
+	v := make([]float64, m+1)
+	rowOfCol := make([]int, m+1)
+	way := make([]int, m+1)
+	for i := 1; i <= n; i++ {
+		rowOfCol[0] = i
+		j0 := 0
+		minv := make([]float64, m+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		used := make([]bool, m+1)
+		for {
+			used[j0] = true
+			i0, delta, j1 := rowOfCol[j0], math.Inf(1), 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				if cur := cost[i0-1][j-1] - u[i0] - v[j]; cur < minv[j] {
+					minv[j], way[j] = cur, j0
+				}
+				if minv[j] < delta {
+					delta, j1 = minv[j], j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[rowOfCol[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if rowOfCol[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			rowOfCol[j0] = rowOfCol[j1]
+			j0 = j1
+		}
+	}
+
+	assigned := make([]int, n)
+	for j := 1; j <= m; j++ {
+		if rowOfCol[j] != 0 {
+			assigned[rowOfCol[j]-1] = j - 1
+		}
+	}
+	return assigned
+}
@@ -0,0 +1,113 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"boatdetect/internal/geojson"
+)
+
+// timeProperties are the feature properties read as the observation time,
+// in order of preference.
+var timeProperties = []string{"time", "datetime", "timestamp"}
+
+// sceneTimeLayout is the start time field of Sentinel-1 product names, as in
+// S1A_IW_GRDH_1SDV_20240101T052312_....
+const sceneTimeLayout = "20060102T150405"
+
+// Point is the position of a detection or truth vessel.
+type Point struct {
+	SceneID string
+	// Time is zero when the feature carries no time.
+	Time     time.Time
+	Lon, Lat float64
+}
+
+// Points returns the position of every feature of fc. The scene comes from
+// the scene_id property and the time from a time, datetime or timestamp
+// property, or else from the start time in a Sentinel-1 scene ID.
+func Points(fc geojson.FeatureCollection) ([]Point, error) {
+	points := make([]Point, 0, len(fc.Features))
+	for i, f := range fc.Features {
+		lon, lat, err := featurePosition(f)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+		t, err := featureTime(f.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+		points = append(points, Point{SceneID: f.Properties.String("scene_id"), Time: t, Lon: lon, Lat: lat})
+	}
+	return points, nil
+}
+
+// featurePosition returns the point of f, taking the first Point of a
+// collection and otherwise the mean of the geometry's positions.
+func featurePosition(f geojson.Feature) (lon, lat float64, err error) {
+	if f.Geometry == nil {
+		return 0, 0, fmt.Errorf("no geometry")
+	}
+	positions := f.Geometry.Positions()
+	if p := firstPoint(*f.Geometry); p != nil {
+		positions = []geojson.Position{p}
+	}
+	if len(positions) == 0 {
+		return 0, 0, fmt.Errorf("empty %s geometry", f.Geometry.Type)
+	}
+	for _, p := range positions {
+		if len(p) < 2 {
+			return 0, 0, fmt.Errorf("position has %d elements, want 2 or 3", len(p))
+		}
+	}
+	if len(positions) == 1 {
+		return positions[0].Lon(), positions[0].Lat(), nil
+	}
+	for _, p := range positions {
+		lon += p.Lon()
+		lat += p.Lat()
+	}
+	n := float64(len(positions))
+	return lon / n, lat / n, nil
+}
+
+func firstPoint(g geojson.Geometry) geojson.Position {
+	switch g.Type {
+	case geojson.PointType:
+		return g.Point
+	case geojson.GeometryCollectionType:
+		for _, member := range g.Geometries {
+			if p := firstPoint(member); p != nil {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+func featureTime(p geojson.Properties) (time.Time, error) {
+	for _, key := range timeProperties {
+		s := p.String(key)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse %s: %w", key, err)
+		}
+		return t, nil
+	}
+	return SceneTime(p.String("scene_id")), nil
+}
+
+// SceneTime returns the start time encoded in a Sentinel-1 scene ID, or the
+// zero time when it has none.
+func SceneTime(sceneID string) time.Time {
+	for _, field := range strings.Split(sceneID, "_") {
+		if t, err := time.Parse(sceneTimeLayout, field); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
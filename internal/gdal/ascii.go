@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // ToAAIGrid converts a GeoTIFF to an Arc/Info ASCII Grid.
@@ -20,6 +22,94 @@ func ToAAIGrid(ctx context.Context, inputTif, outputAsc string) error {
 	return nil
 }
 
+// Window is a rectangle of raster pixels.
+type Window struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// ReadGrid reads the first band of a raster through a temporary ASCII grid
+// in tempDir.
+func ReadGrid(ctx context.Context, tempDir, tifPath string) (Grid, error) {
+	ascPath, err := tempASCIIPath(tempDir)
+	if err != nil {
+		return Grid{}, err
+	}
+
+	if err := ToAAIGrid(ctx, tifPath, ascPath); err != nil {
+		return Grid{}, fmt.Errorf("convert to ascii grid: %w", err)
+	}
+
+	return parseASCIIFile(ascPath)
+}
+
+// ReadWindow reads one window of the first band of a raster like ReadGrid,
+// so a scene can be processed piece by piece. The temporary ASCII grid and
+// its sidecars are removed once parsed.
+func ReadWindow(ctx context.Context, tempDir, tifPath string, win Window) (Grid, error) {
+	ascPath, err := tempASCIIPath(tempDir)
+	if err != nil {
+		return Grid{}, err
+	}
+	defer removeASCIIFiles(ascPath)
+	if err := removeIfExists(ascPath); err != nil {
+		return Grid{}, err
+	}
+
+	_, _, err = Run(ctx, "gdal_translate", "-of", "AAIGrid",
+		"-srcwin", strconv.Itoa(win.X), strconv.Itoa(win.Y), strconv.Itoa(win.Width), strconv.Itoa(win.Height),
+		tifPath, ascPath)
+	if err != nil {
+		return Grid{}, fmt.Errorf("read window %+v: gdal_translate: %w", win, err)
+	}
+
+	grid, err := parseASCIIFile(ascPath)
+	if err != nil {
+		return Grid{}, err
+	}
+	if grid.Width != win.Width || grid.Height != win.Height {
+		return Grid{}, fmt.Errorf("read window %+v: got %dx%d grid", win, grid.Width, grid.Height)
+	}
+	return grid, nil
+}
+
+func tempASCIIPath(tempDir string) (string, error) {
+	ascFile, err := os.CreateTemp(tempDir, "*.asc")
+	if err != nil {
+		return "", fmt.Errorf("create temp grid: %w", err)
+	}
+	if err := ascFile.Close(); err != nil {
+		return "", fmt.Errorf("close temp grid: %w", err)
+	}
+	return ascFile.Name(), nil
+}
+
+// removeASCIIFiles removes an ASCII grid and the sidecars gdal_translate
+// writes next to it.
+func removeASCIIFiles(ascPath string) {
+	base := strings.TrimSuffix(ascPath, ".asc")
+	for _, path := range []string{ascPath, base + ".prj", ascPath + ".aux.xml"} {
+		os.Remove(path)
+	}
+}
+
+func parseASCIIFile(ascPath string) (Grid, error) {
+	gridFile, err := os.Open(ascPath)
+	if err != nil {
+		return Grid{}, fmt.Errorf("open ascii grid: %w", err)
+	}
+	defer gridFile.Close()
+
+	grid, err := ParseAAIGrid(gridFile)
+	if err != nil {
+		return Grid{}, fmt.Errorf("parse ascii grid: %w", err)
+	}
+
+	return grid, nil
+}
+
 func removeIfExists(path string) error {
 	_, err := os.Stat(path)
 	if err == nil {
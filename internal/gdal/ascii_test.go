@@ -65,3 +65,39 @@ func TestToAAIGridReturnsError(t *testing.T) {
 		t.Fatalf("expected gdal_translate error, got %v", err)
 	}
 }
+
+func TestReadWindowTranslatesWindowAndCleansUp(t *testing.T) {
+	useLocalGDAL(t)
+
+	ctx := context.Background()
+	binDir := t.TempDir()
+	writeScript(t, filepath.Join(binDir, "gdal_translate"), "#!/bin/sh\n"+
+		"if [ \"$3\" != \"-srcwin\" ] || [ \"$4 $5 $6 $7\" != \"4 2 2 1\" ]; then\n"+
+		"  echo \"bad args $*\" 1>&2\n"+
+		"  exit 3\n"+
+		"fi\n"+
+		"printf 'ncols 2\\nnrows 1\\nxllcorner 0\\nyllcorner 0\\ncellsize 1\\nNODATA_value -9999\\n7 8\\n' > \"$9\"\n"+
+		"touch \"${9%.asc}.prj\"\n")
+	prependPath(t, binDir)
+
+	tempDir := t.TempDir()
+	grid, err := ReadWindow(ctx, tempDir, "/tmp/input.tif", Window{X: 4, Y: 2, Width: 2, Height: 1})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if grid.Width != 2 || grid.Height != 1 || grid.Data[0] != 7 || grid.Data[1] != 8 {
+		t.Fatalf("expected 2x1 window [7 8], got %+v", grid)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected temp files removed, got %d entries", len(entries))
+	}
+
+	if _, err := ReadWindow(ctx, tempDir, "/tmp/input.tif", Window{X: 4, Y: 2, Width: 3, Height: 1}); err == nil {
+		t.Fatalf("expected error for a window of the wrong size")
+	}
+}
@@ -189,6 +189,21 @@ func (c *Client) ensureImage(ctx context.Context) error {
 	return formatDockerPullError(err, stderrBuf.String())
 }
 
+// imageDigest returns the repository digest of the local GDAL image, or an
+// empty string when it cannot be determined.
+func (c *Client) imageDigest(ctx context.Context) string {
+	cmd := exec.CommandContext(ctx, "docker", "image", "inspect", "--format", "{{join .RepoDigests \"\\n\"}}", GDALImage)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	digest, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if _, sum, ok := strings.Cut(digest, "@"); ok {
+		return sum
+	}
+	return digest
+}
+
 func formatDockerCommandError(command string, commandErr error, stderr string) error {
 	detail := strings.TrimSpace(stderr)
 	if detail == "" {
@@ -4,17 +4,129 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"os"
+	"math"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+
+	"boatdetect/internal/cache"
 )
 
-// Preprocess runs GDAL commands to warp to EPSG:4326 and produce a Byte-scaled GeoTIFF.
-func Preprocess(ctx context.Context, inputPath, outputDir string, bbox [4]float64) (byteTifPath string, err error) {
-	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		return "", fmt.Errorf("create output dir: %w", err)
+// preprocessRecipe identifies the steps run by Preprocess; change it when
+// they change so cached outputs are rebuilt.
+const preprocessRecipe = "gdalwarp -t_srs EPSG:4326 -r bilinear|gdal_translate -ot Byte -scale"
+
+// PreprocessCache reuses preprocessed rasters across runs. Entries are keyed
+// by the input fingerprint, bbox, preprocessing recipe and GDAL version.
+// Every entry returned by Preprocess is leased until Release, so concurrent
+// runs cannot prune it while it is read.
+type PreprocessCache struct {
+	store   *cache.Cache
+	version string
+
+	mu     sync.Mutex
+	leases []*cache.Lease
+}
+
+// NewPreprocessCache wraps store, querying the GDAL version once.
+func NewPreprocessCache(ctx context.Context, store *cache.Cache) (*PreprocessCache, error) {
+	version, err := Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &PreprocessCache{store: store, version: version}, nil
+}
+
+func (pc *PreprocessCache) key(inputPath string, bbox [4]float64) (string, error) {
+	fingerprint, err := pc.store.Fingerprint(inputPath)
+	if err != nil {
+		return "", err
+	}
+	extent := fmt.Sprintf("%.8f|%.8f|%.8f|%.8f", bbox[0], bbox[1], bbox[2], bbox[3])
+	return cache.Key("preprocess", preprocessRecipe, pc.version, fingerprint, extent), nil
+}
+
+// lookup returns the leased entry of key, if any.
+func (pc *PreprocessCache) lookup(key string) (string, bool, error) {
+	path, ok := pc.store.Lookup(key)
+	if !ok {
+		return "", false, nil
+	}
+	if err := pc.acquire(key); err != nil {
+		if errors.Is(err, cache.ErrNotCached) {
+			// Pruned since the lookup; rebuild it.
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return path, true, nil
+}
+
+func (pc *PreprocessCache) acquire(key string) error {
+	lease, err := pc.store.Acquire(key)
+	if err != nil {
+		return err
+	}
+	pc.mu.Lock()
+	pc.leases = append(pc.leases, lease)
+	pc.mu.Unlock()
+	return nil
+}
+
+// Release gives up the leases on every entry returned so far. Paths returned
+// by Preprocess may be pruned afterwards. It is safe on a nil cache and may
+// be called more than once.
+func (pc *PreprocessCache) Release() error {
+	if pc == nil {
+		return nil
+	}
+	pc.mu.Lock()
+	leases := pc.leases
+	pc.leases = nil
+	pc.mu.Unlock()
+
+	var errs []error
+	for _, lease := range leases {
+		errs = append(errs, lease.Release())
+	}
+	return errors.Join(errs...)
+}
+
+// Version returns the GDAL release reported by gdalinfo --version.
+func Version(ctx context.Context) (string, error) {
+	stdout, _, err := Run(ctx, "gdalinfo", "--version")
+	if err != nil {
+		return "", fmt.Errorf("gdal version: %w", err)
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// Preprocess runs GDAL commands to warp to EPSG:4326 and produce a Byte-scaled
+// GeoTIFF in the workspace. With a non-nil pc, a cached output is returned
+// when present and a new one is moved into the cache; either stays leased
+// until pc.Release.
+func Preprocess(ctx context.Context, ws *Workspace, pc *PreprocessCache, inputPath string, bbox [4]float64) (byteTifPath string, err error) {
+	var key string
+	if pc != nil {
+		key, err = pc.key(inputPath, bbox)
+		if err != nil {
+			return "", fmt.Errorf("cache key: %w", err)
+		}
+		path, ok, err := pc.lookup(key)
+		if err != nil {
+			return "", fmt.Errorf("cache lookup: %w", err)
+		}
+		if ok {
+			return path, nil
+		}
+	}
+
+	outputDir, err := ws.Subdir("preprocess")
+	if err != nil {
+		return "", err
 	}
 
 	base := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
@@ -47,6 +159,16 @@ func Preprocess(ctx context.Context, inputPath, outputDir string, bbox [4]float6
 		return "", fmt.Errorf("gdal_translate: %w", err)
 	}
 
+	if pc != nil {
+		cached, err := pc.store.Store(key, bytePath, inputPath)
+		if err != nil {
+			return "", fmt.Errorf("cache preprocessed raster: %w", err)
+		}
+		if err := pc.acquire(key); err != nil {
+			return "", fmt.Errorf("lease preprocessed raster: %w", err)
+		}
+		return cached, nil
+	}
 	return bytePath, nil
 }
 
@@ -55,3 +177,31 @@ func preprocessHash(inputPath string, bbox [4]float64) string {
 	sum := sha256.Sum256([]byte(key))
 	return hex.EncodeToString(sum[:8])
 }
+
+// WarpToGrid resamples inputPath onto the EPSG:4326 pixel grid described by
+// info, so its pixels line up one-to-one with the reference raster.
+func WarpToGrid(ctx context.Context, inputPath, outputPath string, info RasterInfo) error {
+	gt := info.GeoTransform
+	minX := gt[0]
+	maxX := gt[0] + float64(info.Width)*gt[1]
+	maxY := gt[3]
+	minY := gt[3] + float64(info.Height)*gt[5]
+
+	_, _, err := Run(ctx, "gdalwarp",
+		"-t_srs", "EPSG:4326",
+		"-te", strconv.FormatFloat(math.Min(minX, maxX), 'f', -1, 64),
+		strconv.FormatFloat(math.Min(minY, maxY), 'f', -1, 64),
+		strconv.FormatFloat(math.Max(minX, maxX), 'f', -1, 64),
+		strconv.FormatFloat(math.Max(minY, maxY), 'f', -1, 64),
+		"-ts", strconv.Itoa(info.Width), strconv.Itoa(info.Height),
+		"-r", "bilinear",
+		"-overwrite",
+		inputPath,
+		outputPath,
+	)
+	if err != nil {
+		return fmt.Errorf("gdalwarp: %w", err)
+	}
+
+	return nil
+}
@@ -1,6 +1,14 @@
 package gdal
 
-import "testing"
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"boatdetect/internal/cache"
+)
 
 func TestPreprocessHashStable(t *testing.T) {
 	bbox := [4]float64{-122.5, 37.7, -122.3, 37.9}
@@ -18,3 +26,84 @@ func TestPreprocessHashDiffersByBBox(t *testing.T) {
 		t.Fatalf("expected different hashes for different bbox, got %q", first)
 	}
 }
+
+func TestPreprocessReusesCachedOutput(t *testing.T) {
+	useLocalGDAL(t)
+	ctx := context.Background()
+
+	binDir := t.TempDir()
+	calls := filepath.Join(binDir, "calls")
+	writeScript(t, filepath.Join(binDir, "gdalinfo"), "#!/bin/sh\necho 'GDAL 3.9.0, released 2024/05/07'\n")
+	writeScript(t, filepath.Join(binDir, "gdalwarp"), "#!/bin/sh\necho warp >> "+calls+"\nfor a; do last=$a; done\necho warped > \"$last\"\n")
+	writeScript(t, filepath.Join(binDir, "gdal_translate"), "#!/bin/sh\nfor a; do last=$a; done\necho byte > \"$last\"\n")
+	prependPath(t, binDir)
+
+	input := filepath.Join(t.TempDir(), "scene.tif")
+	if err := os.WriteFile(input, []byte("raw"), 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+
+	store, err := cache.Open(filepath.Join(t.TempDir(), "cache"), false)
+	if err != nil {
+		t.Fatalf("open cache: %v", err)
+	}
+	pc, err := NewPreprocessCache(ctx, store)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	bbox := [4]float64{1, 2, 3, 4}
+	var paths []string
+	for run := 0; run < 2; run++ {
+		ws, err := NewWorkspace(t.TempDir(), false)
+		if err != nil {
+			t.Fatalf("create workspace: %v", err)
+		}
+		path, err := Preprocess(ctx, ws, pc, input, bbox)
+		if err != nil {
+			t.Fatalf("run %d: expected no error, got %v", run, err)
+		}
+		if err := ws.Close(); err != nil {
+			t.Fatalf("close workspace: %v", err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("run %d: expected output to outlive workspace, got %v", run, err)
+		}
+		paths = append(paths, path)
+	}
+	if paths[0] != paths[1] {
+		t.Fatalf("expected cached path reused, got %q and %q", paths[0], paths[1])
+	}
+	assertCalls(t, calls, 1)
+
+	ws, err := NewWorkspace(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("create workspace: %v", err)
+	}
+	defer ws.Close()
+	if _, err := Preprocess(ctx, ws, pc, input, [4]float64{1, 2, 3, 5}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	assertCalls(t, calls, 2)
+
+	if removed, err := store.Prune(1, 0); err != nil || len(removed) != 0 {
+		t.Fatalf("expected leased entries kept, got %d removed (%v)", len(removed), err)
+	}
+	if err := pc.Release(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if removed, err := store.Prune(1, 0); err != nil || len(removed) != 2 {
+		t.Fatalf("expected released entries pruned, got %d removed (%v)", len(removed), err)
+	}
+}
+
+func assertCalls(t *testing.T, path string, want int) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read calls: %v", err)
+	}
+	if got := strings.Count(string(data), "warp"); got != want {
+		t.Fatalf("expected %d gdalwarp calls, got %d", want, got)
+	}
+}
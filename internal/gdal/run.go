@@ -13,7 +13,7 @@ import (
 // It captures stdout/stderr separately and returns a detailed error if the command fails.
 // The Docker client must be initialized via Initialize() before calling this.
 func Run(ctx context.Context, name string, args ...string) (stdout string, stderr string, err error) {
-	mode := strings.ToLower(strings.TrimSpace(os.Getenv("BOATDETECT_GDAL_MODE")))
+	mode := gdalMode()
 
 	if mode == "local" {
 		return runLocal(ctx, name, args...)
@@ -31,6 +31,11 @@ func Run(ctx context.Context, name string, args ...string) (stdout string, stder
 	return runLocal(ctx, name, args...)
 }
 
+// gdalMode returns the normalized BOATDETECT_GDAL_MODE setting.
+func gdalMode() string {
+	return strings.ToLower(strings.TrimSpace(os.Getenv("BOATDETECT_GDAL_MODE")))
+}
+
 func runLocal(ctx context.Context, name string, args ...string) (stdout string, stderr string, err error) {
 	cmd := exec.CommandContext(ctx, name, args...)
 
@@ -0,0 +1,31 @@
+package gdal
+
+import "context"
+
+// RuntimeInfo describes where GDAL commands run, for provenance records.
+type RuntimeInfo struct {
+	// Mode is "docker" or "local".
+	Mode    string `json:"mode"`
+	Image   string `json:"image,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// Runtime reports the GDAL runtime that Run uses. Lookups are best effort:
+// fields that cannot be determined are left empty.
+func Runtime(ctx context.Context) RuntimeInfo {
+	info := RuntimeInfo{Mode: "local"}
+	if version, err := Version(ctx); err == nil {
+		info.Version = version
+	}
+
+	client := GetClient()
+	if gdalMode() == "local" || client == nil {
+		return info
+	}
+
+	info.Mode = "docker"
+	info.Image = GDALImage
+	info.Digest = client.imageDigest(ctx)
+	return info
+}
@@ -0,0 +1,21 @@
+package gdal
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestRuntimeLocalMode(t *testing.T) {
+	useLocalGDAL(t)
+
+	binDir := t.TempDir()
+	writeScript(t, filepath.Join(binDir, "gdalinfo"), "#!/bin/sh\necho 'GDAL 3.9.0, released 2024/05/07'\n")
+	prependPath(t, binDir)
+
+	got := Runtime(context.Background())
+	want := RuntimeInfo{Mode: "local", Version: "GDAL 3.9.0, released 2024/05/07"}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
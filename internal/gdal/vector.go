@@ -0,0 +1,18 @@
+package gdal
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// VectorTranslate converts the vector dataset src into dst with ogr2ogr.
+// args, such as the driver, layer name and creation options, precede the
+// datasets on the command line.
+func VectorTranslate(ctx context.Context, dst, src string, args ...string) error {
+	cmdArgs := append(append([]string(nil), args...), dst, src)
+	if _, _, err := Run(ctx, "ogr2ogr", cmdArgs...); err != nil {
+		return fmt.Errorf("ogr2ogr %s: %w", filepath.Base(dst), err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package gdal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVectorTranslateArgs(t *testing.T) {
+	dir := t.TempDir()
+	argsPath := filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsPath + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "ogr2ogr"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if err := VectorTranslate(context.Background(), "out.gpkg", "in.csv", "-f", "GPKG", "-nln", "boats"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	data, err := os.ReadFile(argsPath)
+	if err != nil {
+		t.Fatalf("read args: %v", err)
+	}
+	want := "-f\nGPKG\n-nln\nboats\nout.gpkg\nin.csv\n"
+	if string(data) != want {
+		t.Fatalf("expected args %q, got %q", want, data)
+	}
+}
+
+func TestVectorTranslateError(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	err := VectorTranslate(context.Background(), "out.shp", "in.csv")
+	if err == nil || !strings.Contains(err.Error(), "ogr2ogr out.shp") {
+		t.Fatalf("expected ogr2ogr error, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package gdal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultWorkspaceRoot is relative to the working directory, which is the
+// directory mounted into the GDAL container.
+const DefaultWorkspaceRoot = ".tmp"
+
+// Workspace is a per-run directory for intermediate rasters. Each run gets a
+// unique directory, so concurrent runs sharing a root do not interfere.
+type Workspace struct {
+	root string
+	dir  string
+	keep bool
+}
+
+// NewWorkspace creates a unique run directory under root (DefaultWorkspaceRoot
+// when empty). When keep is set, Close leaves the files for inspection.
+func NewWorkspace(root string, keep bool) (*Workspace, error) {
+	if root == "" {
+		root = DefaultWorkspaceRoot
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("create workspace root: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(root, "run-")
+	if err != nil {
+		return nil, fmt.Errorf("create workspace: %w", err)
+	}
+
+	return &Workspace{root: root, dir: dir, keep: keep}, nil
+}
+
+// Dir returns the run directory.
+func (w *Workspace) Dir() string {
+	return w.dir
+}
+
+// Subdir returns the named directory inside the workspace, creating it if needed.
+func (w *Workspace) Subdir(name string) (string, error) {
+	dir := filepath.Join(w.dir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create workspace dir: %w", err)
+	}
+	return dir, nil
+}
+
+// TempDir creates a unique directory inside the workspace for one step. The
+// returned cleanup removes it unless the workspace keeps its files.
+func (w *Workspace) TempDir(pattern string) (string, func(), error) {
+	dir, err := os.MkdirTemp(w.dir, pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("create workspace dir: %w", err)
+	}
+
+	cleanup := func() {
+		if !w.keep {
+			os.RemoveAll(dir)
+		}
+	}
+	return dir, cleanup, nil
+}
+
+// Close removes the run directory, and the root once no other run uses it,
+// unless the workspace keeps its files. It is safe to call more than once.
+func (w *Workspace) Close() error {
+	if w.keep {
+		return nil
+	}
+	if err := os.RemoveAll(w.dir); err != nil {
+		return fmt.Errorf("remove workspace: %w", err)
+	}
+	// Fails while other runs still have directories under the root.
+	os.Remove(w.root)
+	return nil
+}
@@ -0,0 +1,84 @@
+package gdal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceRunsAreIsolated(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "work")
+
+	first, err := NewWorkspace(root, false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	second, err := NewWorkspace(root, false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if first.Dir() == second.Dir() {
+		t.Fatalf("expected distinct run directories, got %q twice", first.Dir())
+	}
+
+	secondFile := filepath.Join(second.Dir(), "keep.tif")
+	if err := os.WriteFile(secondFile, nil, 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := os.Stat(first.Dir()); !os.IsNotExist(err) {
+		t.Fatalf("expected first workspace removed, got %v", err)
+	}
+	if _, err := os.Stat(secondFile); err != nil {
+		t.Fatalf("expected second workspace untouched, got %v", err)
+	}
+
+	if err := second.Close(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := os.Stat(root); !os.IsNotExist(err) {
+		t.Fatalf("expected empty root removed, got %v", err)
+	}
+}
+
+func TestWorkspaceTempDirCleanup(t *testing.T) {
+	ws, err := NewWorkspace(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer ws.Close()
+
+	dir, cleanup, err := ws.TempDir("step-")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if filepath.Dir(dir) != ws.Dir() {
+		t.Fatalf("expected temp dir inside %q, got %q", ws.Dir(), dir)
+	}
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected temp dir removed, got %v", err)
+	}
+}
+
+func TestWorkspaceKeep(t *testing.T) {
+	ws, err := NewWorkspace(t.TempDir(), true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	dir, cleanup, err := ws.TempDir("step-")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	cleanup()
+	if err := ws.Close(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("expected kept temp dir, got %v", err)
+	}
+}
@@ -1,23 +1,57 @@
 package geojson
 
-import "boatdetect/internal/detect"
+import (
+	"fmt"
+	"strings"
 
-// BuildBoatsFC builds a GeoJSON feature collection from detected candidates.
+	"boatdetect/internal/detect"
+)
+
+// GeometryMode selects the geometry of each boat feature.
+type GeometryMode string
+
+const (
+	// GeometryPoint emits the candidate centroid only.
+	GeometryPoint GeometryMode = "point"
+	// GeometryFootprint emits the footprint instead of the point.
+	GeometryFootprint GeometryMode = "footprint"
+	// GeometryBoth emits a GeometryCollection of the point and footprint.
+	GeometryBoth GeometryMode = "both"
+)
+
+// ParseGeometryMode resolves a geometry mode name as accepted on the command line.
+func ParseGeometryMode(name string) (GeometryMode, error) {
+	switch mode := GeometryMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return GeometryPoint, nil
+	case GeometryPoint, GeometryFootprint, GeometryBoth:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown geometry %q", name)
+	}
+}
+
+// BuildBoatsFC builds a GeoJSON feature collection of candidate points.
 func BuildBoatsFC(sceneID string, candidates []detect.Candidate) FeatureCollection {
+	return BuildBoatsFCGeometry(sceneID, candidates, GeometryPoint)
+}
+
+// BuildBoatsFCGeometry builds a GeoJSON feature collection from detected
+// candidates with the given geometry. Candidates without a footprint fall
+// back to their point.
+func BuildBoatsFCGeometry(sceneID string, candidates []detect.Candidate, mode GeometryMode) FeatureCollection {
 	features := make([]Feature, 0, len(candidates))
 	for _, candidate := range candidates {
-		features = append(features, Feature{
-			Type: featureType,
-			Geometry: Geometry{
-				Type:        geometryPointType,
-				Coordinates: []float64{candidate.Lon, candidate.Lat},
-			},
-			Properties: map[string]interface{}{
-				"scene_id": sceneID,
-				"score":    candidate.Score,
-				"area_px":  candidate.AreaPx,
-			},
-		})
+		properties := Properties{
+			"scene_id": sceneID,
+			"score":    candidate.Score,
+			"area_px":  candidate.AreaPx,
+		}
+		if candidate.Reason != "" {
+			properties["reason"] = candidate.Reason
+		}
+
+		features = append(features, NewFeature(CandidateGeometry(candidate, mode), properties))
 	}
 
 	return FeatureCollection{
@@ -25,3 +59,42 @@ func BuildBoatsFC(sceneID string, candidates []detect.Candidate) FeatureCollecti
 		Features: features,
 	}
 }
+
+// CandidateGeometry returns the geometry of candidate for mode. Candidates
+// without a footprint fall back to their point.
+func CandidateGeometry(candidate detect.Candidate, mode GeometryMode) Geometry {
+	point := NewPoint(candidate.Lon, candidate.Lat)
+	if mode == GeometryPoint || len(candidate.Footprint) == 0 {
+		return point
+	}
+
+	footprint := footprintGeometry(candidate.Footprint)
+	if mode == GeometryFootprint {
+		return footprint
+	}
+	return NewGeometryCollection(point, footprint)
+}
+
+// footprintGeometry returns a Polygon for a single part and a MultiPolygon
+// for merged candidates.
+func footprintGeometry(polygons []detect.Polygon) Geometry {
+	if len(polygons) == 1 {
+		return NewPolygon(polygonPositions(polygons[0]))
+	}
+	multi := make([][][]Position, len(polygons))
+	for i, polygon := range polygons {
+		multi[i] = polygonPositions(polygon)
+	}
+	return NewMultiPolygon(multi)
+}
+
+func polygonPositions(polygon detect.Polygon) [][]Position {
+	rings := make([][]Position, len(polygon))
+	for i, ring := range polygon {
+		rings[i] = make([]Position, len(ring))
+		for j, p := range ring {
+			rings[i][j] = Position{p[0], p[1]}
+		}
+	}
+	return rings
+}
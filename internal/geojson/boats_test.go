@@ -1,6 +1,7 @@
 package geojson
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
@@ -36,15 +37,12 @@ func TestBuildBoatsFC(t *testing.T) {
 		if feature.Type != featureType {
 			t.Fatalf("feature %d type: expected %q, got %q", i, featureType, feature.Type)
 		}
-		if feature.Geometry.Type != geometryPointType {
-			t.Fatalf("feature %d geometry type: expected %q, got %q", i, geometryPointType, feature.Geometry.Type)
-		}
-		coords, ok := feature.Geometry.Coordinates.([]float64)
-		if !ok {
-			t.Fatalf("feature %d coordinates: expected []float64, got %T", i, feature.Geometry.Coordinates)
+		if feature.Geometry.Type != PointType {
+			t.Fatalf("feature %d geometry type: expected %q, got %q", i, PointType, feature.Geometry.Type)
 		}
+		coords := feature.Geometry.Point
 
-		wantCoords := []float64{candidates[i].Lon, candidates[i].Lat}
+		wantCoords := Position{candidates[i].Lon, candidates[i].Lat}
 		if !reflect.DeepEqual(coords, wantCoords) {
 			t.Fatalf("feature %d coordinates: expected %v, got %v", i, wantCoords, coords)
 		}
@@ -58,6 +56,20 @@ func TestBuildBoatsFC(t *testing.T) {
 		if area, ok := feature.Properties["area_px"].(int); !ok || area != candidates[i].AreaPx {
 			t.Fatalf("feature %d area_px: expected %v, got %v", i, candidates[i].AreaPx, feature.Properties["area_px"])
 		}
+		if _, ok := feature.Properties["reason"]; ok {
+			t.Fatalf("feature %d: expected no reason for unflagged candidate", i)
+		}
+	}
+}
+
+func TestBuildBoatsFCReason(t *testing.T) {
+	candidates := []detect.Candidate{
+		{Lon: 103.8, Lat: 1.2, Score: 3, AreaPx: 4, Reason: detect.ReasonAzimuthAmbiguity},
+	}
+
+	fc := BuildBoatsFC("scene-123", candidates)
+	if got := fc.Features[0].Properties["reason"]; got != detect.ReasonAzimuthAmbiguity {
+		t.Fatalf("expected reason %q, got %v", detect.ReasonAzimuthAmbiguity, got)
 	}
 }
 
@@ -70,3 +82,53 @@ func TestBuildBoatsFCEmpty(t *testing.T) {
 		t.Fatalf("expected no features, got %d", len(fc.Features))
 	}
 }
+
+func TestBuildBoatsFCGeometry(t *testing.T) {
+	square := detect.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}
+	candidates := []detect.Candidate{
+		{Lon: 0.5, Lat: 0.5, Score: 2, AreaPx: 1, Footprint: []detect.Polygon{square}},
+		{Lon: 3, Lat: 3, Score: 1, AreaPx: 2, Footprint: []detect.Polygon{square, square}},
+		{Lon: 5, Lat: 5, Score: 1, AreaPx: 1},
+	}
+
+	fc := BuildBoatsFCGeometry("scene-123", candidates, GeometryFootprint)
+	wantTypes := []GeometryType{PolygonType, MultiPolygonType, PointType}
+	for i, feature := range fc.Features {
+		if feature.Geometry.Type != wantTypes[i] {
+			t.Fatalf("feature %d: expected %q, got %q", i, wantTypes[i], feature.Geometry.Type)
+		}
+	}
+
+	fc = BuildBoatsFCGeometry("scene-123", candidates, GeometryBoth)
+	both := fc.Features[0].Geometry
+	if both.Type != GeometryCollectionType || len(both.Geometries) != 2 {
+		t.Fatalf("expected point and polygon collection, got %+v", both)
+	}
+	if both.Geometries[0].Type != PointType || both.Geometries[1].Type != PolygonType {
+		t.Fatalf("unexpected collection members: %+v", both.Geometries)
+	}
+	if err := fc.Validate(); err != nil {
+		t.Fatalf("expected valid collection, got %v", err)
+	}
+
+	data, err := json.Marshal(fc.Features[0].Geometry.Geometries[1])
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}`
+	if string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+}
+
+func TestParseGeometryMode(t *testing.T) {
+	for name, want := range map[string]GeometryMode{"": GeometryPoint, "point": GeometryPoint, "Footprint": GeometryFootprint, "both": GeometryBoth} {
+		got, err := ParseGeometryMode(name)
+		if err != nil || got != want {
+			t.Fatalf("%q: expected %q, got %q (%v)", name, want, got, err)
+		}
+	}
+	if _, err := ParseGeometryMode("line"); err == nil {
+		t.Fatalf("expected error for unknown geometry")
+	}
+}
@@ -0,0 +1,271 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Layout selects how an Encoder frames features.
+type Layout string
+
+const (
+	// LayoutCollection writes a single FeatureCollection.
+	LayoutCollection Layout = "collection"
+	// LayoutSequence writes an RFC 8142 GeoJSON text sequence: each feature
+	// is preceded by an RS character and followed by a line feed.
+	LayoutSequence Layout = "seq"
+	// LayoutLines writes newline-delimited features.
+	LayoutLines Layout = "ndjson"
+)
+
+const recordSeparator = 0x1e
+
+// ParseLayout resolves a layout name as accepted on the command line.
+func ParseLayout(name string) (Layout, error) {
+	switch layout := Layout(strings.ToLower(strings.TrimSpace(name))); layout {
+	case "":
+		return LayoutCollection, nil
+	case LayoutCollection, LayoutSequence, LayoutLines:
+		return layout, nil
+	default:
+		return "", fmt.Errorf("unknown geojson layout %q", name)
+	}
+}
+
+// EncoderOptions configures an Encoder.
+type EncoderOptions struct {
+	Layout Layout
+	// Precision rounds coordinates to this many decimal places; 0 keeps
+	// full precision.
+	Precision int
+	// Foreign members of the collection, for LayoutCollection.
+	Foreign map[string]json.RawMessage
+	// BBox replaces the extent computed from the encoded features, for
+	// LayoutCollection.
+	BBox []float64
+}
+
+// Encoder writes features to w as they are produced. Call Close to finish
+// the output; a collection is not valid JSON until then.
+type Encoder struct {
+	w       io.Writer
+	opts    EncoderOptions
+	count   int
+	started bool
+	bbox    []float64
+	err     error
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer, opts EncoderOptions) *Encoder {
+	if opts.Layout == "" {
+		opts.Layout = LayoutCollection
+	}
+	return &Encoder{w: w, opts: opts}
+}
+
+// Encode writes one feature.
+func (e *Encoder) Encode(f Feature) error {
+	if e.err != nil {
+		return e.err
+	}
+	if f.Type == "" {
+		f.Type = featureType
+	}
+	if f.Geometry != nil {
+		g := roundGeometry(*f.Geometry, e.opts.Precision)
+		f.Geometry = &g
+		e.extend(g.Positions())
+	}
+	f.BBox = roundValues(f.BBox, e.opts.Precision)
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return e.fail(fmt.Errorf("encode feature %d: %w", e.count, err))
+	}
+
+	switch e.opts.Layout {
+	case LayoutCollection:
+		if err := e.header(); err != nil {
+			return err
+		}
+		if e.count > 0 {
+			e.write([]byte{','})
+		}
+		e.write(data)
+	case LayoutSequence:
+		e.write([]byte{recordSeparator})
+		e.write(data)
+		e.write([]byte{'\n'})
+	case LayoutLines:
+		e.write(data)
+		e.write([]byte{'\n'})
+	default:
+		return e.fail(fmt.Errorf("unknown geojson layout %q", e.opts.Layout))
+	}
+	e.count++
+	return e.err
+}
+
+// Close finishes a collection with its bbox. It does not close w.
+func (e *Encoder) Close() error {
+	if e.err != nil || e.opts.Layout != LayoutCollection {
+		return e.err
+	}
+	if err := e.header(); err != nil {
+		return err
+	}
+
+	bbox := e.opts.BBox
+	if bbox == nil {
+		bbox = e.bbox
+	}
+	e.write([]byte{']'})
+	if bbox != nil {
+		data, err := json.Marshal(roundValues(bbox, e.opts.Precision))
+		if err != nil {
+			return e.fail(err)
+		}
+		e.write([]byte(`,"bbox":`))
+		e.write(data)
+	}
+	e.write([]byte("}\n"))
+	return e.err
+}
+
+// header writes the collection opening and foreign members once.
+func (e *Encoder) header() error {
+	if e.started {
+		return e.err
+	}
+	e.started = true
+	e.write([]byte(`{"type":"FeatureCollection"`))
+
+	keys := make([]string, 0, len(e.opts.Foreign))
+	for key := range e.opts.Foreign {
+		switch key {
+		case "type", "features", "bbox":
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(e.opts.Foreign[key])
+		if err != nil {
+			return e.fail(fmt.Errorf("foreign member %q: %w", key, err))
+		}
+		e.write([]byte{','})
+		e.write(name)
+		e.write([]byte{':'})
+		e.write(value)
+	}
+	e.write([]byte(`,"features":[`))
+	return e.err
+}
+
+func (e *Encoder) extend(positions []Position) {
+	for _, p := range positions {
+		if e.bbox == nil {
+			e.bbox = []float64{p.Lon(), p.Lat(), p.Lon(), p.Lat()}
+			continue
+		}
+		e.bbox[0], e.bbox[1] = math.Min(e.bbox[0], p.Lon()), math.Min(e.bbox[1], p.Lat())
+		e.bbox[2], e.bbox[3] = math.Max(e.bbox[2], p.Lon()), math.Max(e.bbox[3], p.Lat())
+	}
+}
+
+func (e *Encoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	if _, err := e.w.Write(p); err != nil {
+		e.err = err
+	}
+}
+
+func (e *Encoder) fail(err error) error {
+	if e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
+
+// roundGeometry returns a copy of g with coordinates rounded to precision
+// decimal places, or g itself when precision is not positive.
+func roundGeometry(g Geometry, precision int) Geometry {
+	if precision <= 0 {
+		return g
+	}
+	g.Point = roundPosition(g.Point, precision)
+	g.MultiPoint = roundPositions(g.MultiPoint, precision)
+	g.LineString = roundPositions(g.LineString, precision)
+	if g.Polygon != nil {
+		g.Polygon = roundRings(g.Polygon, precision)
+	}
+	if g.MultiPolygon != nil {
+		polygons := make([][][]Position, len(g.MultiPolygon))
+		for i, polygon := range g.MultiPolygon {
+			polygons[i] = roundRings(polygon, precision)
+		}
+		g.MultiPolygon = polygons
+	}
+	if g.Geometries != nil {
+		members := make([]Geometry, len(g.Geometries))
+		for i, member := range g.Geometries {
+			members[i] = roundGeometry(member, precision)
+		}
+		g.Geometries = members
+	}
+	g.BBox = roundValues(g.BBox, precision)
+	return g
+}
+
+func roundRings(rings [][]Position, precision int) [][]Position {
+	out := make([][]Position, len(rings))
+	for i, ring := range rings {
+		out[i] = roundPositions(ring, precision)
+	}
+	return out
+}
+
+func roundPositions(positions []Position, precision int) []Position {
+	if positions == nil {
+		return nil
+	}
+	out := make([]Position, len(positions))
+	for i, p := range positions {
+		out[i] = roundPosition(p, precision)
+	}
+	return out
+}
+
+func roundPosition(p Position, precision int) Position {
+	return Position(roundValues(p, precision))
+}
+
+func roundValues(values []float64, precision int) []float64 {
+	if values == nil || precision <= 0 {
+		return values
+	}
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = Round(v, precision)
+	}
+	return out
+}
+
+// Round rounds v to precision decimal places, as the encoder rounds
+// coordinates. A precision of zero or less returns v unchanged.
+func Round(v float64, precision int) float64 {
+	if precision <= 0 {
+		return v
+	}
+	scale := math.Pow(10, float64(precision))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,118 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func encoderFeatures() []Feature {
+	return []Feature{
+		NewFeature(NewPoint(103.123456789, 1.987654321), Properties{"score": 2.0}),
+		NewFeature(NewPolygon([][]Position{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}), nil),
+	}
+}
+
+func encodeAll(t *testing.T, opts EncoderOptions, features []Feature) string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, opts)
+	for _, f := range features {
+		if err := enc.Encode(f); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.String()
+}
+
+func TestEncoderCollectionMatchesMarshal(t *testing.T) {
+	features := encoderFeatures()
+	foreign := map[string]json.RawMessage{"metadata": json.RawMessage(`{"run":1}`)}
+	got := encodeAll(t, EncoderOptions{Foreign: foreign}, features)
+
+	var decoded, want interface{}
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("encoder output is not JSON: %v\n%s", err, got)
+	}
+	fc := FeatureCollection{Features: features, Foreign: foreign}
+	fc.BBox = ComputeBBox(fc.Positions())
+	data, _ := json.Marshal(fc)
+	json.Unmarshal(data, &want)
+	if !reflect.DeepEqual(decoded, want) {
+		t.Fatalf("expected %s, got %s", data, got)
+	}
+}
+
+func TestEncoderEmptyCollection(t *testing.T) {
+	got := encodeAll(t, EncoderOptions{}, nil)
+	if got != "{\"type\":\"FeatureCollection\",\"features\":[]}\n" {
+		t.Fatalf("unexpected empty collection %q", got)
+	}
+}
+
+func TestEncoderSequenceAndLines(t *testing.T) {
+	features := encoderFeatures()
+	for _, layout := range []Layout{LayoutSequence, LayoutLines} {
+		got := encodeAll(t, EncoderOptions{Layout: layout}, features)
+		records := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+		if len(records) != len(features) {
+			t.Fatalf("%s: expected %d records, got %q", layout, len(features), got)
+		}
+		for _, record := range records {
+			if hasRS := strings.HasPrefix(record, "\x1e"); hasRS != (layout == LayoutSequence) {
+				t.Fatalf("%s: unexpected record framing %q", layout, record)
+			}
+		}
+
+		fc, err := ParseFeatureCollection([]byte(got))
+		if err != nil {
+			t.Fatalf("%s: parse: %v", layout, err)
+		}
+		if !reflect.DeepEqual(fc.Features, features) {
+			t.Fatalf("%s: round trip differs: %+v", layout, fc.Features)
+		}
+	}
+}
+
+func TestEncoderPrecision(t *testing.T) {
+	features := encoderFeatures()
+	got := encodeAll(t, EncoderOptions{Layout: LayoutLines, Precision: 5}, features[:1])
+	if !strings.Contains(got, `[103.12346,1.98765]`) {
+		t.Fatalf("expected rounded coordinates, got %s", got)
+	}
+	if features[0].Geometry.Point[0] != 103.123456789 {
+		t.Fatalf("encoder modified the input feature")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestEncoderReportsWriteErrors(t *testing.T) {
+	enc := NewEncoder(failingWriter{}, EncoderOptions{})
+	if err := enc.Encode(encoderFeatures()[0]); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected write error, got %v", err)
+	}
+	if err := enc.Close(); err == nil {
+		t.Fatalf("expected sticky error from Close")
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	for name, want := range map[string]Layout{"": LayoutCollection, "collection": LayoutCollection, "SEQ": LayoutSequence, "ndjson": LayoutLines} {
+		got, err := ParseLayout(name)
+		if err != nil || got != want {
+			t.Fatalf("%q: expected %q, got %q (%v)", name, want, got, err)
+		}
+	}
+	if _, err := ParseLayout("xml"); err == nil {
+		t.Fatalf("expected error for unknown layout")
+	}
+}
@@ -1,46 +1,337 @@
 package geojson
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"os"
+	"sort"
+
+	"boatdetect/internal/atomicfile"
 )
 
 const (
 	featureCollectionType = "FeatureCollection"
 	featureType           = "Feature"
-	geometryPointType     = "Point"
 )
 
+// FeatureCollection is a GeoJSON FeatureCollection. Foreign holds members
+// other than those defined by RFC 7946, such as run metadata.
 type FeatureCollection struct {
+	Type     string
+	Features []Feature
+	BBox     []float64
+	Foreign  map[string]json.RawMessage
+}
+
+// Feature is a GeoJSON Feature. A nil Geometry encodes as null.
+type Feature struct {
+	Type       string
+	ID         interface{}
+	Geometry   *Geometry
+	Properties Properties
+	BBox       []float64
+	Foreign    map[string]json.RawMessage
+}
+
+// Properties are the properties of a feature.
+type Properties map[string]interface{}
+
+// String returns the string property key, or "" when it is missing or not a string.
+func (p Properties) String(key string) string {
+	s, _ := p[key].(string)
+	return s
+}
+
+// Float returns the numeric property key.
+func (p Properties) Float(key string) (float64, bool) {
+	switch v := p[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// NewFeature returns a Feature with the given geometry and properties.
+func NewFeature(geometry Geometry, properties Properties) Feature {
+	return Feature{Type: featureType, Geometry: &geometry, Properties: properties}
+}
+
+type featureCollectionJSON struct {
 	Type     string    `json:"type"`
+	BBox     []float64 `json:"bbox,omitempty"`
 	Features []Feature `json:"features"`
 }
 
-type Feature struct {
-	Type       string                 `json:"type"`
-	Geometry   Geometry               `json:"geometry"`
-	Properties map[string]interface{} `json:"properties,omitempty"`
+var featureCollectionMembers = []string{"type", "bbox", "features"}
+
+// MarshalJSON encodes the collection and its foreign members.
+func (fc FeatureCollection) MarshalJSON() ([]byte, error) {
+	out := featureCollectionJSON{Type: fc.Type, BBox: fc.BBox, Features: nonNil(fc.Features)}
+	if out.Type == "" {
+		out.Type = featureCollectionType
+	}
+	return marshalWithForeign(out, fc.Foreign)
 }
 
-type Geometry struct {
-	Type        string      `json:"type"`
-	Coordinates interface{} `json:"coordinates"`
+// UnmarshalJSON decodes a collection, keeping unknown members as foreign.
+func (fc *FeatureCollection) UnmarshalJSON(data []byte) error {
+	var raw featureCollectionJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	foreign, err := foreignMembers(data, featureCollectionMembers)
+	if err != nil {
+		return err
+	}
+	*fc = FeatureCollection{Type: raw.Type, Features: raw.Features, BBox: raw.BBox, Foreign: foreign}
+	return nil
 }
 
-func WriteFeatureCollection(path string, fc FeatureCollection) error {
-	if fc.Type == "" {
-		fc.Type = featureCollectionType
+type featureJSON struct {
+	Type       string      `json:"type"`
+	ID         interface{} `json:"id,omitempty"`
+	BBox       []float64   `json:"bbox,omitempty"`
+	Geometry   *Geometry   `json:"geometry"`
+	Properties Properties  `json:"properties"`
+}
+
+var featureMembers = []string{"type", "id", "bbox", "geometry", "properties"}
+
+// MarshalJSON encodes the feature and its foreign members.
+func (f Feature) MarshalJSON() ([]byte, error) {
+	out := featureJSON{Type: f.Type, ID: f.ID, BBox: f.BBox, Geometry: f.Geometry, Properties: f.Properties}
+	if out.Type == "" {
+		out.Type = featureType
+	}
+	return marshalWithForeign(out, f.Foreign)
+}
+
+// UnmarshalJSON decodes a feature, keeping unknown members as foreign.
+func (f *Feature) UnmarshalJSON(data []byte) error {
+	var raw featureJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	foreign, err := foreignMembers(data, featureMembers)
+	if err != nil {
+		return err
+	}
+	*f = Feature{Type: raw.Type, ID: raw.ID, Geometry: raw.Geometry, Properties: raw.Properties, BBox: raw.BBox, Foreign: foreign}
+	return nil
+}
+
+// marshalWithForeign encodes v, which must encode as an object, and appends
+// the foreign members in key order. Foreign members that shadow a member of
+// v are dropped.
+func marshalWithForeign(v interface{}, foreign map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(foreign) == 0 {
+		return data, err
+	}
+
+	var known map[string]json.RawMessage
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(foreign))
+	for key := range foreign {
+		if _, ok := known[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, key := range keys {
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(foreign[key])
+		if err != nil {
+			return nil, fmt.Errorf("foreign member %q: %w", key, err)
+		}
+		buf.WriteByte(',')
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// foreignMembers returns the members of the object data not listed in known.
+func foreignMembers(data []byte, known []string) (map[string]json.RawMessage, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	for _, key := range known {
+		delete(members, key)
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+	return members, nil
+}
+
+// ComputeBBox returns the [west, south, east, north] extent of positions,
+// or nil when there are none.
+func ComputeBBox(positions []Position) []float64 {
+	if len(positions) == 0 {
+		return nil
 	}
-	for i := range fc.Features {
-		if fc.Features[i].Type == "" {
-			fc.Features[i].Type = featureType
+	bbox := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range positions {
+		bbox[0], bbox[1] = math.Min(bbox[0], p.Lon()), math.Min(bbox[1], p.Lat())
+		bbox[2], bbox[3] = math.Max(bbox[2], p.Lon()), math.Max(bbox[3], p.Lat())
+	}
+	return bbox
+}
+
+// Positions returns the positions of every feature geometry.
+func (fc FeatureCollection) Positions() []Position {
+	var positions []Position
+	for _, f := range fc.Features {
+		if f.Geometry != nil {
+			positions = append(positions, f.Geometry.Positions()...)
 		}
 	}
+	return positions
+}
 
-	data, err := json.Marshal(fc)
+// WriteFeatureCollection atomically writes fc as a GeoJSON file. When
+// fc.BBox is nil the extent of the features is written.
+func WriteFeatureCollection(path string, fc FeatureCollection) error {
+	opts := EncoderOptions{Layout: LayoutCollection, Foreign: fc.Foreign, BBox: fc.BBox}
+	return WriteFile(path, opts, func(enc *Encoder) error {
+		for _, f := range fc.Features {
+			if err := enc.Encode(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WriteFile streams the features encoded by fn to path. Readers see either
+// the previous file or the complete new one, never a partial write.
+func WriteFile(path string, opts EncoderOptions, fn func(enc *Encoder) error) error {
+	return atomicfile.Write(path, func(w io.Writer) error {
+		enc := NewEncoder(w, opts)
+		if err := fn(enc); err != nil {
+			return err
+		}
+		return enc.Close()
+	})
+}
+
+// ReadFeatureCollection reads a GeoJSON file; see ParseFeatureCollection.
+func ReadFeatureCollection(path string) (FeatureCollection, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return FeatureCollection{}, fmt.Errorf("read geojson: %w", err)
+	}
+	fc, err := ParseFeatureCollection(data)
+	if err != nil {
+		return FeatureCollection{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// ReadValidFeatureCollection reads a GeoJSON file like ReadFeatureCollection
+// and checks it with ValidateStructure, for input that callers rely on, such
+// as ground truth. Rings of either winding are accepted.
+func ReadValidFeatureCollection(path string) (FeatureCollection, error) {
+	fc, err := ReadFeatureCollection(path)
+	if err != nil {
+		return FeatureCollection{}, err
+	}
+	if err := fc.ValidateStructure(); err != nil {
+		return FeatureCollection{}, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// ParseFeatureCollection decodes a FeatureCollection. A single Feature or a
+// bare geometry, as AOI files often are, is wrapped in a collection, as are
+// the features of a text sequence or newline-delimited file.
+func ParseFeatureCollection(data []byte) (FeatureCollection, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == recordSeparator {
+		return parseFeatureSequence(bytes.Split(trimmed[1:], []byte{recordSeparator}))
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var first json.RawMessage
+	if err := dec.Decode(&first); err != nil {
+		return FeatureCollection{}, err
+	}
+	if dec.More() {
+		return parseFeatureLines(data)
+	}
+
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return FeatureCollection{}, err
 	}
 
-	return os.WriteFile(path, data, 0o644)
+	switch head.Type {
+	case featureCollectionType:
+		var fc FeatureCollection
+		if err := json.Unmarshal(data, &fc); err != nil {
+			return FeatureCollection{}, err
+		}
+		return fc, nil
+	case featureType:
+		var f Feature
+		if err := json.Unmarshal(data, &f); err != nil {
+			return FeatureCollection{}, err
+		}
+		return FeatureCollection{Type: featureCollectionType, Features: []Feature{f}}, nil
+	case "":
+		return FeatureCollection{}, fmt.Errorf("missing GeoJSON type")
+	default:
+		var g Geometry
+		if err := json.Unmarshal(data, &g); err != nil {
+			return FeatureCollection{}, err
+		}
+		return FeatureCollection{Type: featureCollectionType, Features: []Feature{NewFeature(g, nil)}}, nil
+	}
+}
+
+func parseFeatureSequence(records [][]byte) (FeatureCollection, error) {
+	fc := FeatureCollection{Type: featureCollectionType, Features: make([]Feature, 0, len(records))}
+	for i, record := range records {
+		var f Feature
+		if err := json.Unmarshal(record, &f); err != nil {
+			return FeatureCollection{}, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc, nil
+}
+
+func parseFeatureLines(data []byte) (FeatureCollection, error) {
+	fc := FeatureCollection{Type: featureCollectionType, Features: make([]Feature, 0)}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var f Feature
+		if err := dec.Decode(&f); err != nil {
+			return FeatureCollection{}, fmt.Errorf("feature %d: %w", len(fc.Features)+1, err)
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc, nil
 }
@@ -0,0 +1,155 @@
+package geojson
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFeatureCollectionRoundTrip(t *testing.T) {
+	square := [][]Position{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}
+	fc := FeatureCollection{
+		Type: featureCollectionType,
+		Features: []Feature{
+			NewFeature(NewPoint(103.5, 1.25), Properties{"scene_id": "s1", "score": 2.5}),
+			NewFeature(NewMultiPoint([]Position{{1, 2}, {3, 4, 5}}), nil),
+			NewFeature(NewLineString([]Position{{1, 2}, {3, 4}}), Properties{}),
+			NewFeature(NewPolygon(square), Properties{"reason": "sidelobe"}),
+			NewFeature(NewMultiPolygon([][][]Position{square, square}), nil),
+			NewFeature(NewGeometryCollection(NewPoint(0.5, 0.5), NewPolygon(square)), nil),
+			{Type: featureType, ID: "b-7", Properties: Properties{"area_px": 3.0}},
+		},
+		Foreign: map[string]json.RawMessage{"metadata": json.RawMessage(`{"tool":"boatdetect"}`)},
+	}
+	fc.BBox = ComputeBBox(fc.Positions())
+
+	path := filepath.Join(t.TempDir(), "fc.geojson")
+	if err := WriteFeatureCollection(path, fc); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	got, err := ReadFeatureCollection(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !reflect.DeepEqual(got, fc) {
+		t.Fatalf("round trip differs\ngot  %+v\nwant %+v", got, fc)
+	}
+	if err := got.Validate(); err != nil {
+		t.Fatalf("expected valid collection, got %v", err)
+	}
+	if want := []float64{0, 0, 103.5, 4}; !reflect.DeepEqual(got.BBox, want) {
+		t.Fatalf("expected bbox %v, got %v", want, got.BBox)
+	}
+}
+
+func TestFeatureCollectionMarshalMembers(t *testing.T) {
+	fc := FeatureCollection{
+		Features: []Feature{{Geometry: nil}},
+		Foreign:  map[string]json.RawMessage{"zeta": json.RawMessage(`1`), "alpha": json.RawMessage(`"a"`), "type": json.RawMessage(`"x"`)},
+	}
+	data, err := json.Marshal(fc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":null,"properties":null}],"alpha":"a","zeta":1}`
+	if string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+}
+
+func TestParseFeatureCollectionWrapsFeatureAndGeometry(t *testing.T) {
+	fc, err := ParseFeatureCollection([]byte(`{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]},"properties":{"name":"a"}}`))
+	if err != nil {
+		t.Fatalf("parse feature: %v", err)
+	}
+	if len(fc.Features) != 1 || fc.Features[0].Properties.String("name") != "a" {
+		t.Fatalf("expected wrapped feature, got %+v", fc)
+	}
+
+	fc, err = ParseFeatureCollection([]byte(`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`))
+	if err != nil {
+		t.Fatalf("parse geometry: %v", err)
+	}
+	if len(fc.Features) != 1 || fc.Features[0].Geometry.Type != PolygonType {
+		t.Fatalf("expected wrapped polygon, got %+v", fc)
+	}
+}
+
+func TestParseFeatureCollectionErrors(t *testing.T) {
+	for _, input := range []string{
+		`{}`,
+		`{"type":"Circle","coordinates":[0,0]}`,
+		`{"type":"Point"}`,
+		`{"type":"Point","coordinates":"x"}`,
+		`{"type":"Point","coordinates":[1]}`,
+		`{"type":"Point","coordinates":[1,2,3,4]}`,
+		`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[0,0],[]]},"properties":null}`,
+		`{"type":"GeometryCollection","geometries":[{"type":"MultiPoint","coordinates":[[5]]}]}`,
+		`[1,2]`,
+	} {
+		if _, err := ParseFeatureCollection([]byte(input)); err == nil {
+			t.Fatalf("%s: expected error", input)
+		}
+	}
+}
+
+func TestPropertiesAccessors(t *testing.T) {
+	p := Properties{"name": "a", "score": 1.5, "area_px": 3, "flag": true}
+	if p.String("name") != "a" || p.String("score") != "" {
+		t.Fatalf("unexpected string accessors")
+	}
+	if v, ok := p.Float("score"); !ok || v != 1.5 {
+		t.Fatalf("expected score 1.5, got %v %v", v, ok)
+	}
+	if v, ok := p.Float("area_px"); !ok || v != 3 {
+		t.Fatalf("expected area 3, got %v %v", v, ok)
+	}
+	if _, ok := p.Float("flag"); ok {
+		t.Fatalf("expected non-numeric flag")
+	}
+}
+
+func TestReadFeatureCollectionReportsPath(t *testing.T) {
+	_, err := ReadFeatureCollection(filepath.Join(t.TempDir(), "missing.geojson"))
+	if err == nil || !strings.Contains(err.Error(), "read geojson") {
+		t.Fatalf("expected read error, got %v", err)
+	}
+}
+
+func TestReadValidFeatureCollection(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.geojson")
+	if err := os.WriteFile(valid, []byte(`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]},"properties":null}]}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if fc, err := ReadValidFeatureCollection(valid); err != nil || len(fc.Features) != 1 {
+		t.Fatalf("expected one valid feature, got %+v (%v)", fc, err)
+	}
+
+	clockwise := filepath.Join(dir, "clockwise.geojson")
+	if err := os.WriteFile(clockwise, []byte(`{"type":"Polygon","coordinates":[[[0,0],[0,1],[1,1],[1,0],[0,0]]]}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := ReadValidFeatureCollection(clockwise); err != nil {
+		t.Fatalf("expected clockwise ring accepted, got %v", err)
+	}
+
+	open := filepath.Join(dir, "open.geojson")
+	if err := os.WriteFile(open, []byte(`{"type":"Polygon","coordinates":[[[0,0],[0,1],[1,1],[1,0]]]}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := ReadValidFeatureCollection(open); err == nil || !strings.Contains(err.Error(), "not closed") {
+		t.Fatalf("expected open ring error, got %v", err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.geojson")
+	if err := os.WriteFile(invalid, []byte(`{"type":"Point","coordinates":[200,2]}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := ReadValidFeatureCollection(invalid); err == nil || !strings.Contains(err.Error(), "longitude") {
+		t.Fatalf("expected longitude error, got %v", err)
+	}
+}
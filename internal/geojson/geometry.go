@@ -0,0 +1,221 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GeometryType is the "type" member of a geometry object.
+type GeometryType string
+
+const (
+	PointType              GeometryType = "Point"
+	MultiPointType         GeometryType = "MultiPoint"
+	LineStringType         GeometryType = "LineString"
+	PolygonType            GeometryType = "Polygon"
+	MultiPolygonType       GeometryType = "MultiPolygon"
+	GeometryCollectionType GeometryType = "GeometryCollection"
+)
+
+// Position is a longitude, latitude and optional altitude.
+type Position []float64
+
+// Lon returns the longitude of p.
+func (p Position) Lon() float64 { return p[0] }
+
+// Lat returns the latitude of p.
+func (p Position) Lat() float64 { return p[1] }
+
+// UnmarshalJSON decodes a position, rejecting those without a longitude and
+// latitude or with more than an altitude besides, so that Lon and Lat are
+// safe on decoded data.
+func (p *Position) UnmarshalJSON(data []byte) error {
+	var values []float64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	if values != nil && (len(values) < 2 || len(values) > 3) {
+		return fmt.Errorf("position has %d elements, want 2 or 3", len(values))
+	}
+	*p = values
+	return nil
+}
+
+// Geometry is a typed GeoJSON geometry. Only the field matching Type is used.
+type Geometry struct {
+	Type         GeometryType
+	Point        Position
+	MultiPoint   []Position
+	LineString   []Position
+	Polygon      [][]Position
+	MultiPolygon [][][]Position
+	Geometries   []Geometry
+	BBox         []float64
+	Foreign      map[string]json.RawMessage
+}
+
+// NewPoint returns a Point geometry.
+func NewPoint(lon, lat float64) Geometry {
+	return Geometry{Type: PointType, Point: Position{lon, lat}}
+}
+
+// NewMultiPoint returns a MultiPoint geometry.
+func NewMultiPoint(points []Position) Geometry {
+	return Geometry{Type: MultiPointType, MultiPoint: points}
+}
+
+// NewLineString returns a LineString geometry.
+func NewLineString(line []Position) Geometry {
+	return Geometry{Type: LineStringType, LineString: line}
+}
+
+// NewPolygon returns a Polygon geometry of an exterior ring and holes.
+func NewPolygon(rings [][]Position) Geometry {
+	return Geometry{Type: PolygonType, Polygon: rings}
+}
+
+// NewMultiPolygon returns a MultiPolygon geometry.
+func NewMultiPolygon(polygons [][][]Position) Geometry {
+	return Geometry{Type: MultiPolygonType, MultiPolygon: polygons}
+}
+
+// NewGeometryCollection returns a GeometryCollection of geometries.
+func NewGeometryCollection(geometries ...Geometry) Geometry {
+	return Geometry{Type: GeometryCollectionType, Geometries: geometries}
+}
+
+// coordinates returns the coordinates member for the geometry type.
+func (g Geometry) coordinates() (interface{}, error) {
+	switch g.Type {
+	case PointType:
+		return g.Point, nil
+	case MultiPointType:
+		return nonNil(g.MultiPoint), nil
+	case LineStringType:
+		return nonNil(g.LineString), nil
+	case PolygonType:
+		return nonNil(g.Polygon), nil
+	case MultiPolygonType:
+		return nonNil(g.MultiPolygon), nil
+	default:
+		return nil, fmt.Errorf("unknown geometry type %q", g.Type)
+	}
+}
+
+// nonNil keeps empty coordinate arrays from encoding as null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+type geometryJSON struct {
+	Type        GeometryType `json:"type"`
+	BBox        []float64    `json:"bbox,omitempty"`
+	Coordinates interface{}  `json:"coordinates,omitempty"`
+	Geometries  *[]Geometry  `json:"geometries,omitempty"`
+}
+
+// MarshalJSON encodes the members of the geometry type plus foreign members.
+func (g Geometry) MarshalJSON() ([]byte, error) {
+	out := geometryJSON{Type: g.Type, BBox: g.BBox}
+	if g.Type == GeometryCollectionType {
+		geometries := nonNil(g.Geometries)
+		out.Geometries = &geometries
+	} else {
+		coords, err := g.coordinates()
+		if err != nil {
+			return nil, err
+		}
+		out.Coordinates = coords
+	}
+	return marshalWithForeign(out, g.Foreign)
+}
+
+var geometryMembers = []string{"type", "bbox", "coordinates", "geometries"}
+
+// UnmarshalJSON decodes a geometry, keeping unknown members as foreign.
+func (g *Geometry) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type        GeometryType    `json:"type"`
+		BBox        []float64       `json:"bbox"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometries  []Geometry      `json:"geometries"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	foreign, err := foreignMembers(data, geometryMembers)
+	if err != nil {
+		return err
+	}
+
+	*g = Geometry{Type: raw.Type, BBox: raw.BBox, Foreign: foreign}
+	if raw.Type == GeometryCollectionType {
+		g.Geometries = raw.Geometries
+		return nil
+	}
+	if len(raw.Coordinates) == 0 {
+		return fmt.Errorf("%s geometry has no coordinates", raw.Type)
+	}
+
+	var target interface{}
+	switch raw.Type {
+	case PointType:
+		target = &g.Point
+	case MultiPointType:
+		target = &g.MultiPoint
+	case LineStringType:
+		target = &g.LineString
+	case PolygonType:
+		target = &g.Polygon
+	case MultiPolygonType:
+		target = &g.MultiPolygon
+	default:
+		return fmt.Errorf("unknown geometry type %q", raw.Type)
+	}
+	if err := json.Unmarshal(raw.Coordinates, target); err != nil {
+		return fmt.Errorf("%s coordinates: %w", raw.Type, err)
+	}
+	return nil
+}
+
+// Positions returns every position of the geometry, including those of
+// collection members.
+func (g Geometry) Positions() []Position {
+	switch g.Type {
+	case PointType:
+		if g.Point == nil {
+			return nil
+		}
+		return []Position{g.Point}
+	case MultiPointType:
+		return g.MultiPoint
+	case LineStringType:
+		return g.LineString
+	case PolygonType:
+		return flatten(g.Polygon)
+	case MultiPolygonType:
+		var positions []Position
+		for _, polygon := range g.MultiPolygon {
+			positions = append(positions, flatten(polygon)...)
+		}
+		return positions
+	case GeometryCollectionType:
+		var positions []Position
+		for _, member := range g.Geometries {
+			positions = append(positions, member.Positions()...)
+		}
+		return positions
+	}
+	return nil
+}
+
+func flatten(rings [][]Position) []Position {
+	var positions []Position
+	for _, ring := range rings {
+		positions = append(positions, ring...)
+	}
+	return positions
+}
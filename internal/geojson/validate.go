@@ -0,0 +1,213 @@
+package geojson
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validate checks fc against RFC 7946: member types, position ranges,
+// closed rings of at least four positions, counter-clockwise exteriors and
+// clockwise holes, and well-formed bboxes. Every problem is reported with
+// its location, e.g. "features[3].geometry.coordinates[0]: ring is not closed".
+func (fc FeatureCollection) Validate() error {
+	return errors.Join(fc.problems(true)...)
+}
+
+// ValidateStructure checks fc like Validate but accepts rings of either
+// winding, since RFC 7946 §3.1.6 asks parsers not to reject polygons from
+// producers that predate the right-hand rule.
+func (fc FeatureCollection) ValidateStructure() error {
+	return errors.Join(fc.problems(false)...)
+}
+
+// Validate checks f against RFC 7946; see FeatureCollection.Validate.
+func (f Feature) Validate() error {
+	return errors.Join(f.problems("", true)...)
+}
+
+// Validate checks g against RFC 7946; see FeatureCollection.Validate.
+func (g Geometry) Validate() error {
+	return errors.Join(g.problems("", true)...)
+}
+
+// problems lists the problems of fc, checking ring winding when winding is
+// set.
+func (fc FeatureCollection) problems(winding bool) []error {
+	var errs []error
+	if fc.Type != featureCollectionType {
+		errs = append(errs, fmt.Errorf("type is %q, want %q", fc.Type, featureCollectionType))
+	}
+	errs = appendProblem(errs, "bbox", validateBBox(fc.BBox))
+	for i, f := range fc.Features {
+		errs = append(errs, f.problems(fmt.Sprintf("features[%d]", i), winding)...)
+	}
+	return errs
+}
+
+func (f Feature) problems(path string, winding bool) []error {
+	var errs []error
+	if f.Type != featureType {
+		errs = appendProblem(errs, join(path, "type"), fmt.Errorf("is %q, want %q", f.Type, featureType))
+	}
+	switch f.ID.(type) {
+	case nil, string, float64, int:
+	default:
+		errs = appendProblem(errs, join(path, "id"), fmt.Errorf("must be a string or number"))
+	}
+	errs = appendProblem(errs, join(path, "bbox"), validateBBox(f.BBox))
+	if f.Geometry != nil {
+		errs = append(errs, f.Geometry.problems(join(path, "geometry"), winding)...)
+	}
+	return errs
+}
+
+func (g Geometry) problems(path string, winding bool) []error {
+	errs := appendProblem(nil, join(path, "bbox"), validateBBox(g.BBox))
+	coords := join(path, "coordinates")
+
+	switch g.Type {
+	case PointType:
+		errs = appendProblem(errs, coords, validatePosition(g.Point))
+	case MultiPointType:
+		for i, p := range g.MultiPoint {
+			errs = appendProblem(errs, fmt.Sprintf("%s[%d]", coords, i), validatePosition(p))
+		}
+	case LineStringType:
+		errs = appendProblem(errs, coords, validateLine(g.LineString))
+	case PolygonType:
+		errs = append(errs, polygonProblems(coords, g.Polygon, winding)...)
+	case MultiPolygonType:
+		for i, polygon := range g.MultiPolygon {
+			errs = append(errs, polygonProblems(fmt.Sprintf("%s[%d]", coords, i), polygon, winding)...)
+		}
+	case GeometryCollectionType:
+		for i, member := range g.Geometries {
+			errs = append(errs, member.problems(fmt.Sprintf("%s[%d]", join(path, "geometries"), i), winding)...)
+		}
+	default:
+		errs = appendProblem(errs, join(path, "type"), fmt.Errorf("unknown geometry type %q", g.Type))
+	}
+	return errs
+}
+
+func polygonProblems(path string, rings [][]Position, winding bool) []error {
+	if len(rings) == 0 {
+		return []error{fmt.Errorf("%s: polygon has no rings", path)}
+	}
+	var errs []error
+	for i, ring := range rings {
+		errs = appendProblem(errs, fmt.Sprintf("%s[%d]", path, i), validateRing(ring, i == 0, winding))
+	}
+	return errs
+}
+
+func validatePosition(p Position) error {
+	if len(p) < 2 || len(p) > 3 {
+		return fmt.Errorf("position has %d elements, want 2 or 3", len(p))
+	}
+	if p.Lon() < -180 || p.Lon() > 180 {
+		return fmt.Errorf("longitude %v out of range", p.Lon())
+	}
+	if p.Lat() < -90 || p.Lat() > 90 {
+		return fmt.Errorf("latitude %v out of range", p.Lat())
+	}
+	return nil
+}
+
+func validateLine(line []Position) error {
+	if len(line) < 2 {
+		return fmt.Errorf("line string has %d positions, want at least 2", len(line))
+	}
+	return validatePositions(line)
+}
+
+func validatePositions(positions []Position) error {
+	for i, p := range positions {
+		if err := validatePosition(p); err != nil {
+			return fmt.Errorf("[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateRing checks a ring is closed and, when winding is set, that an
+// exterior is counter-clockwise and a hole clockwise.
+func validateRing(ring []Position, exterior, winding bool) error {
+	if len(ring) < 4 {
+		return fmt.Errorf("ring has %d positions, want at least 4", len(ring))
+	}
+	if err := validatePositions(ring); err != nil {
+		return err
+	}
+	first, last := ring[0], ring[len(ring)-1]
+	if first.Lon() != last.Lon() || first.Lat() != last.Lat() {
+		return fmt.Errorf("ring is not closed")
+	}
+	if !winding {
+		return nil
+	}
+
+	area := signedArea(ring)
+	switch {
+	case exterior && area < 0:
+		return fmt.Errorf("exterior ring is clockwise, want counter-clockwise")
+	case !exterior && area > 0:
+		return fmt.Errorf("hole is counter-clockwise, want clockwise")
+	}
+	return nil
+}
+
+// signedArea returns the planar shoelace area of a closed lon/lat ring,
+// positive when counter-clockwise.
+func signedArea(ring []Position) float64 {
+	area := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		area += ring[i].Lon()*ring[i+1].Lat() - ring[i+1].Lon()*ring[i].Lat()
+	}
+	return area / 2
+}
+
+// validateBBox accepts a missing bbox or 2D/3D bounds. West may exceed east
+// for boxes crossing the antimeridian.
+func validateBBox(bbox []float64) error {
+	if bbox == nil {
+		return nil
+	}
+	if len(bbox) != 4 && len(bbox) != 6 {
+		return fmt.Errorf("has %d elements, want 4 or 6", len(bbox))
+	}
+	half := len(bbox) / 2
+	west, south, east, north := bbox[0], bbox[1], bbox[half], bbox[half+1]
+	for _, lon := range []float64{west, east} {
+		if lon < -180 || lon > 180 {
+			return fmt.Errorf("longitude %v out of range", lon)
+		}
+	}
+	for _, lat := range []float64{south, north} {
+		if lat < -90 || lat > 90 {
+			return fmt.Errorf("latitude %v out of range", lat)
+		}
+	}
+	if south > north {
+		return fmt.Errorf("south %v is north of %v", south, north)
+	}
+	if len(bbox) == 6 && bbox[2] > bbox[5] {
+		return fmt.Errorf("minimum altitude %v exceeds maximum %v", bbox[2], bbox[5])
+	}
+	return nil
+}
+
+// appendProblem prefixes err with path and appends it when non-nil.
+func appendProblem(errs []error, path string, err error) []error {
+	if err == nil {
+		return errs
+	}
+	return append(errs, fmt.Errorf("%s: %w", path, err))
+}
+
+func join(path, member string) string {
+	if path == "" {
+		return member
+	}
+	return path + "." + member
+}
@@ -0,0 +1,65 @@
+package geojson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsProblems(t *testing.T) {
+	cases := []struct {
+		name     string
+		geometry Geometry
+		want     string
+	}{
+		{"longitude", NewPoint(181, 0), "coordinates: longitude 181 out of range"},
+		{"latitude", NewPoint(0, -91), "coordinates: latitude -91 out of range"},
+		{"dimensions", Geometry{Type: PointType, Point: Position{1}}, "position has 1 elements"},
+		{"open ring", NewPolygon([][]Position{{{0, 0}, {1, 0}, {1, 1}, {0, 1}}}), "coordinates[0]: ring is not closed"},
+		{"short ring", NewPolygon([][]Position{{{0, 0}, {1, 0}, {0, 0}}}), "ring has 3 positions"},
+		{"clockwise exterior", NewPolygon([][]Position{{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}}), "exterior ring is clockwise"},
+		{"ccw hole", NewPolygon([][]Position{
+			{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}},
+			{{1, 1}, {2, 1}, {2, 2}, {1, 2}, {1, 1}},
+		}), "coordinates[1]: hole is counter-clockwise"},
+		{"short line", NewLineString([]Position{{0, 0}}), "line string has 1 positions"},
+		{"nested", NewGeometryCollection(NewPoint(0, 0), NewPoint(200, 0)), "geometries[1].coordinates: longitude 200"},
+		{"bbox", Geometry{Type: PointType, Point: Position{0, 0}, BBox: []float64{0, 10, 1, 5}}, "bbox: south 10 is north of 5"},
+		{"type", Geometry{Type: "Circle"}, `unknown geometry type "Circle"`},
+	}
+	for _, tc := range cases {
+		fc := FeatureCollection{Type: featureCollectionType, Features: []Feature{NewFeature(tc.geometry, nil)}}
+		err := fc.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected error containing %q, got %v", tc.name, tc.want, err)
+		}
+		if !strings.HasPrefix(err.Error(), "features[0].geometry.") {
+			t.Fatalf("%s: expected located error, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestValidateAcceptsAntimeridianBBoxAndNullGeometry(t *testing.T) {
+	fc := FeatureCollection{
+		Type:     featureCollectionType,
+		BBox:     []float64{170, -10, -170, 10},
+		Features: []Feature{{Type: featureType}},
+	}
+	if err := fc.Validate(); err != nil {
+		t.Fatalf("expected valid collection, got %v", err)
+	}
+}
+
+func TestValidateCollectsAllProblems(t *testing.T) {
+	fc := FeatureCollection{Type: featureCollectionType, Features: []Feature{
+		NewFeature(NewPoint(500, 0), nil),
+		{Type: "Thing"},
+	}}
+	err := fc.Validate()
+	if err == nil {
+		t.Fatalf("expected errors")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "features[1].type") {
+		t.Fatalf("expected one problem per feature, got %q", lines)
+	}
+}
@@ -0,0 +1,73 @@
+// Package imagery renders raster products for review: candidate chips and
+// scene quicklooks.
+package imagery
+
+import (
+	"image/png"
+	"io"
+	"math"
+
+	"boatdetect/internal/detect"
+	"boatdetect/internal/gdal"
+)
+
+// Chip is a square window of a raster around a candidate.
+type Chip struct {
+	Size int
+	// X0 and Y0 are the raster pixel of the top-left chip pixel. They are
+	// negative when the window extends past the raster edge.
+	X0, Y0 int
+	// Data holds Size×Size values in row order; NaN marks pixels outside the
+	// raster or equal to its nodata value.
+	Data []float64
+	// GeoTransform maps chip pixels to lon/lat.
+	GeoTransform [6]float64
+}
+
+// Cut returns the size×size window of grid centred on lon, lat. gt is the
+// geotransform of grid.
+func Cut(grid gdal.Grid, gt [6]float64, lon, lat float64, size int) Chip {
+	px, py := detect.LonLatToPixel(gt, lon, lat)
+	x0 := int(math.Round(px)) - size/2
+	y0 := int(math.Round(py)) - size/2
+
+	chip := Chip{Size: size, X0: x0, Y0: y0, Data: make([]float64, size*size)}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			chip.Data[y*size+x] = gridValue(grid, x0+x, y0+y)
+		}
+	}
+
+	chip.GeoTransform = gt
+	chip.GeoTransform[0], chip.GeoTransform[3] = detect.PixelToLonLat(gt, float64(x0), float64(y0))
+	return chip
+}
+
+func gridValue(grid gdal.Grid, x, y int) float64 {
+	if x < 0 || y < 0 || x >= grid.Width || y >= grid.Height {
+		return math.NaN()
+	}
+	v := grid.Data[y*grid.Width+x]
+	if v == grid.NoData {
+		return math.NaN()
+	}
+	return v
+}
+
+// Bytes returns the chip as 8-bit samples, clamped to 0–255 with NaN as 0.
+func (c Chip) Bytes() []uint8 {
+	out := make([]uint8, len(c.Data))
+	for i, v := range c.Data {
+		if math.IsNaN(v) {
+			continue
+		}
+		out[i] = uint8(math.Round(math.Min(math.Max(v, 0), 255)))
+	}
+	return out
+}
+
+// WritePNG writes the chip as a greyscale PNG stretched between its 2nd and
+// 98th percentiles.
+func (c Chip) WritePNG(w io.Writer) error {
+	return png.Encode(w, Stretch(c.Data, c.Size, c.Size, 2, 98))
+}
@@ -0,0 +1,156 @@
+package imagery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+	"math"
+	"testing"
+
+	"boatdetect/internal/gdal"
+)
+
+// testGrid is 4×3 with values 0..11 and pixel (x, y) at lon 100+x, lat 10-y.
+func testGrid() (gdal.Grid, [6]float64) {
+	grid := gdal.Grid{Width: 4, Height: 3, NoData: -9999, Data: make([]float64, 12)}
+	for i := range grid.Data {
+		grid.Data[i] = float64(i)
+	}
+	grid.Data[5] = -9999
+	return grid, [6]float64{100, 1, 0, 10, 0, -1}
+}
+
+func TestCutCentresAndPads(t *testing.T) {
+	grid, gt := testGrid()
+	chip := Cut(grid, gt, 100, 10, 3)
+
+	if chip.X0 != -1 || chip.Y0 != -1 {
+		t.Fatalf("expected origin (-1, -1), got (%d, %d)", chip.X0, chip.Y0)
+	}
+	if chip.GeoTransform[0] != 99 || chip.GeoTransform[3] != 11 {
+		t.Fatalf("expected chip origin at 99, 11, got %v", chip.GeoTransform)
+	}
+	if !math.IsNaN(chip.Data[0]) || chip.Data[4] != 0 || chip.Data[5] != 1 {
+		t.Fatalf("expected padding and the grid corner, got %v", chip.Data)
+	}
+	if !math.IsNaN(chip.Data[8]) {
+		t.Fatalf("expected nodata as NaN, got %v", chip.Data[8])
+	}
+	if got := chip.Bytes(); got[0] != 0 || got[5] != 1 {
+		t.Fatalf("expected NaN as 0, got %v", got)
+	}
+}
+
+func TestStretch(t *testing.T) {
+	img := Stretch([]float64{10, 20, 30, math.NaN()}, 2, 2, 0, 100)
+	want := []uint8{0, 128, 255, 0}
+	for i, v := range want {
+		if img.Pix[i] != v {
+			t.Fatalf("expected %v, got %v", want, img.Pix)
+		}
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	grid, gt := testGrid()
+	var buf bytes.Buffer
+	if err := Cut(grid, gt, 101, 9, 4).WritePNG(&buf); err != nil {
+		t.Fatalf("write png: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil || img.Bounds().Dx() != 4 || img.Bounds().Dy() != 4 {
+		t.Fatalf("expected 4x4 png, got %v (%v)", img, err)
+	}
+}
+
+func TestWriteNPY(t *testing.T) {
+	grid, gt := testGrid()
+	var buf bytes.Buffer
+	if err := Cut(grid, gt, 101, 9, 2).WriteNPY(&buf); err != nil {
+		t.Fatalf("write npy: %v", err)
+	}
+	data := buf.Bytes()
+	if string(data[:8]) != "\x93NUMPY\x01\x00" {
+		t.Fatalf("expected npy magic, got %q", data[:8])
+	}
+	headerLen := int(binary.LittleEndian.Uint16(data[8:10]))
+	if (10+headerLen)%64 != 0 {
+		t.Fatalf("expected data aligned to 64 bytes, header is %d", headerLen)
+	}
+	header := string(data[10 : 10+headerLen])
+	if !bytes.Contains([]byte(header), []byte("'shape': (2, 2)")) || header[len(header)-1] != '\n' {
+		t.Fatalf("unexpected header %q", header)
+	}
+	if got := data[10+headerLen:]; !bytes.Equal(got, []byte{0, 1, 4, 0}) {
+		t.Fatalf("expected chip bytes, got %v", got)
+	}
+}
+
+func TestWriteGeoTIFF(t *testing.T) {
+	grid, gt := testGrid()
+	chip := Cut(grid, gt, 101, 9, 2)
+	var buf bytes.Buffer
+	if err := chip.WriteGeoTIFF(&buf); err != nil {
+		t.Fatalf("write geotiff: %v", err)
+	}
+	data := buf.Bytes()
+	if string(data[:4]) != "II*\x00" {
+		t.Fatalf("expected little-endian tiff, got %q", data[:4])
+	}
+
+	tags, next := readIFD(data, binary.LittleEndian.Uint32(data[4:8]))
+	value := func(tag uint16) uint32 { return binary.LittleEndian.Uint32(tags[tag][4:]) }
+
+	if value(256) != 2 || value(257) != 2 {
+		t.Fatalf("expected 2x2 image, got %dx%d", value(256), value(257))
+	}
+	strip := value(273)
+	if got := data[strip : strip+value(279)]; !bytes.Equal(got, chip.Bytes()) {
+		t.Fatalf("expected strip %v, got %v", chip.Bytes(), got)
+	}
+
+	transform := value(34264)
+	origin := math.Float64frombits(binary.LittleEndian.Uint64(data[transform+24:]))
+	if origin != chip.GeoTransform[0] {
+		t.Fatalf("expected model transformation origin %v, got %v", chip.GeoTransform[0], origin)
+	}
+	if _, ok := tags[34735]; !ok {
+		t.Fatalf("expected GeoKeyDirectory tag")
+	}
+
+	// The nodata pixel at (1, 1) is 0 like the valid pixel at (0, 0); only
+	// the mask tells them apart.
+	if next == 0 {
+		t.Fatalf("expected a mask IFD")
+	}
+	mask, last := readIFD(data, next)
+	maskValue := func(tag uint16) uint32 { return binary.LittleEndian.Uint32(mask[tag][4:]) }
+	if last != 0 || maskValue(254) != 4 || binary.LittleEndian.Uint16(mask[262][4:]) != 4 || binary.LittleEndian.Uint16(mask[258][4:]) != 1 {
+		t.Fatalf("expected a 1-bit transparency mask")
+	}
+	maskStrip := maskValue(273)
+	if got := data[maskStrip : maskStrip+maskValue(279)]; !bytes.Equal(got, []byte{0xc0, 0x80}) {
+		t.Fatalf("expected mask rows [c0 80], got %x", got)
+	}
+
+	full := Cut(grid, gt, 100, 10, 1)
+	buf.Reset()
+	if err := full.WriteGeoTIFF(&buf); err != nil {
+		t.Fatalf("write geotiff: %v", err)
+	}
+	if _, next := readIFD(buf.Bytes(), 8); next != 0 {
+		t.Fatalf("expected no mask for a chip without missing pixels")
+	}
+}
+
+// readIFD returns the entries of the IFD at offset, keyed by tag with the
+// type, count and value bytes, and the offset of the next IFD.
+func readIFD(data []byte, offset uint32) (map[uint16][]byte, uint32) {
+	n := int(binary.LittleEndian.Uint16(data[offset:]))
+	tags := make(map[uint16][]byte, n)
+	for i := 0; i < n; i++ {
+		entry := data[int(offset)+2+i*12:]
+		tags[binary.LittleEndian.Uint16(entry)] = entry[4:12]
+	}
+	return tags, binary.LittleEndian.Uint32(data[int(offset)+2+n*12:])
+}
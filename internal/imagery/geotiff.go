@@ -0,0 +1,188 @@
+package imagery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+)
+
+// TIFF field types.
+const (
+	tiffShort  = 3
+	tiffLong   = 4
+	tiffDouble = 12
+)
+
+type tiffEntry struct {
+	tag    uint16
+	typ    uint16
+	shorts []uint16
+	longs  []uint32
+	floats []float64
+}
+
+func (e tiffEntry) count() int {
+	return len(e.shorts) + len(e.longs) + len(e.floats)
+}
+
+func (e tiffEntry) payload() []byte {
+	var buf bytes.Buffer
+	for _, v := range e.shorts {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	for _, v := range e.longs {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	for _, v := range e.floats {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// tiffImage is one image file directory and its single strip.
+type tiffImage struct {
+	entries []tiffEntry
+	strip   []byte
+}
+
+// WriteGeoTIFF writes the chip as an uncompressed single-band 8-bit GeoTIFF
+// in EPSG:4326. The geotransform is stored as a ModelTransformation so
+// rotated grids are kept. When the chip has pixels outside the raster or at
+// its nodata value, a 1-bit internal transparency mask marks them, since
+// they are written as 0 like valid zeros; GDAL reads it as the band mask.
+func (c Chip) WriteGeoTIFF(w io.Writer) error {
+	data := c.Bytes()
+	gt := c.GeoTransform
+	size := uint32(c.Size)
+
+	images := []tiffImage{{strip: data, entries: []tiffEntry{
+		{tag: 256, typ: tiffLong, longs: []uint32{size}},              // ImageWidth
+		{tag: 257, typ: tiffLong, longs: []uint32{size}},              // ImageLength
+		{tag: 258, typ: tiffShort, shorts: []uint16{8}},               // BitsPerSample
+		{tag: 259, typ: tiffShort, shorts: []uint16{1}},               // Compression: none
+		{tag: 262, typ: tiffShort, shorts: []uint16{1}},               // Photometric: BlackIsZero
+		{tag: 273, typ: tiffLong, longs: []uint32{0}},                 // StripOffsets, set below
+		{tag: 277, typ: tiffShort, shorts: []uint16{1}},               // SamplesPerPixel
+		{tag: 278, typ: tiffLong, longs: []uint32{size}},              // RowsPerStrip
+		{tag: 279, typ: tiffLong, longs: []uint32{uint32(len(data))}}, // StripByteCounts
+		{tag: 284, typ: tiffShort, shorts: []uint16{1}},               // PlanarConfiguration
+		{tag: 339, typ: tiffShort, shorts: []uint16{1}},               // SampleFormat: unsigned
+		{tag: 34264, typ: tiffDouble, floats: []float64{ // ModelTransformation
+			gt[1], gt[2], 0, gt[0],
+			gt[4], gt[5], 0, gt[3],
+			0, 0, 0, 0,
+			0, 0, 0, 1,
+		}},
+		{tag: 34735, typ: tiffShort, shorts: []uint16{ // GeoKeyDirectory
+			1, 1, 0, 3,
+			1024, 0, 1, 2, // GTModelType: geographic
+			1025, 0, 1, 1, // GTRasterType: PixelIsArea
+			2048, 0, 1, 4326, // GeographicType: WGS 84
+		}},
+	}}}
+	if mask, ok := c.mask(); ok {
+		images = append(images, tiffImage{strip: mask, entries: []tiffEntry{
+			{tag: 254, typ: tiffLong, longs: []uint32{4}},                 // NewSubfileType: transparency mask
+			{tag: 256, typ: tiffLong, longs: []uint32{size}},              // ImageWidth
+			{tag: 257, typ: tiffLong, longs: []uint32{size}},              // ImageLength
+			{tag: 258, typ: tiffShort, shorts: []uint16{1}},               // BitsPerSample
+			{tag: 259, typ: tiffShort, shorts: []uint16{1}},               // Compression: none
+			{tag: 262, typ: tiffShort, shorts: []uint16{4}},               // Photometric: transparency mask
+			{tag: 273, typ: tiffLong, longs: []uint32{0}},                 // StripOffsets, set below
+			{tag: 277, typ: tiffShort, shorts: []uint16{1}},               // SamplesPerPixel
+			{tag: 278, typ: tiffLong, longs: []uint32{size}},              // RowsPerStrip
+			{tag: 279, typ: tiffLong, longs: []uint32{uint32(len(mask))}}, // StripByteCounts
+			{tag: 284, typ: tiffShort, shorts: []uint16{1}},               // PlanarConfiguration
+		}})
+	}
+
+	_, err := w.Write(encodeTIFF(images))
+	return err
+}
+
+// mask packs the chip's valid pixels one bit each, most significant first,
+// with rows padded to whole bytes. It reports false when every pixel is
+// valid.
+func (c Chip) mask() ([]byte, bool) {
+	stride := (c.Size + 7) / 8
+	mask := make([]byte, stride*c.Size)
+	complete := true
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if math.IsNaN(c.Data[y*c.Size+x]) {
+				complete = false
+				continue
+			}
+			mask[y*stride+x/8] |= 0x80 >> (x % 8)
+		}
+	}
+	return mask, !complete
+}
+
+// encodeTIFF lays out a little-endian TIFF as the header, each IFD followed
+// by its out-of-line values, then the strips in IFD order.
+func encodeTIFF(images []tiffImage) []byte {
+	const headerSize = 8
+	offset := uint32(headerSize)
+	ifdOffsets := make([]uint32, len(images))
+	for i, img := range images {
+		sort.Slice(img.entries, func(a, b int) bool { return img.entries[a].tag < img.entries[b].tag })
+		ifdOffsets[i] = offset
+		offset += uint32(2 + len(img.entries)*12 + 4)
+		for _, e := range img.entries {
+			if p := e.payload(); len(p) > 4 {
+				offset += uint32(len(p))
+			}
+		}
+	}
+	for _, img := range images {
+		for _, e := range img.entries {
+			if e.tag == 273 {
+				e.longs[0] = offset
+			}
+		}
+		offset += uint32(len(img.strip))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("II")
+	binary.Write(&buf, binary.LittleEndian, uint16(42))
+	binary.Write(&buf, binary.LittleEndian, uint32(headerSize))
+	for i, img := range images {
+		next := uint32(0)
+		if i+1 < len(images) {
+			next = ifdOffsets[i+1]
+		}
+		writeIFD(&buf, img.entries, ifdOffsets[i], next)
+	}
+	for _, img := range images {
+		buf.Write(img.strip)
+	}
+	return buf.Bytes()
+}
+
+// writeIFD writes entries, sorted by tag, as an IFD at offset at, followed by
+// the values that do not fit inline. next is the offset of the following
+// IFD, or 0.
+func writeIFD(buf *bytes.Buffer, entries []tiffEntry, at, next uint32) {
+	extra := at + uint32(2+len(entries)*12+4)
+	binary.Write(buf, binary.LittleEndian, uint16(len(entries)))
+	var values bytes.Buffer
+	for _, e := range entries {
+		binary.Write(buf, binary.LittleEndian, e.tag)
+		binary.Write(buf, binary.LittleEndian, e.typ)
+		binary.Write(buf, binary.LittleEndian, uint32(e.count()))
+		if p := e.payload(); len(p) > 4 {
+			binary.Write(buf, binary.LittleEndian, extra+uint32(values.Len()))
+			values.Write(p)
+		} else {
+			var inline [4]byte
+			copy(inline[:], p)
+			buf.Write(inline[:])
+		}
+	}
+	binary.Write(buf, binary.LittleEndian, next)
+	buf.Write(values.Bytes())
+}
@@ -0,0 +1,30 @@
+package imagery
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteNPY writes the chip as a NumPy .npy array of uint8 with shape
+// (size, size).
+func (c Chip) WriteNPY(w io.Writer) error {
+	header := fmt.Sprintf("{'descr': '|u1', 'fortran_order': False, 'shape': (%d, %d), }", c.Size, c.Size)
+	// The magic, version and length take 10 bytes; the header is padded so
+	// the data starts on a 64-byte boundary.
+	pad := 64 - (10+len(header)+1)%64
+	header += strings.Repeat(" ", pad%64) + "\n"
+
+	if _, err := io.WriteString(w, "\x93NUMPY\x01\x00"); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(header))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	_, err := w.Write(c.Bytes())
+	return err
+}
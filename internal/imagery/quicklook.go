@@ -0,0 +1,107 @@
+package imagery
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"boatdetect/internal/gdal"
+)
+
+// minMarkerHalf is the smallest marker half-width in quicklook pixels, so
+// single-pixel candidates stay visible after downsampling.
+const minMarkerHalf = 4
+
+// Marker is a candidate drawn on a quicklook.
+type Marker struct {
+	// X and Y are the candidate position in raster pixels.
+	X, Y float64
+	// Half is the half-width of its box in raster pixels.
+	Half  float64
+	Score float64
+}
+
+// Quicklook renders grid downsampled so neither side exceeds maxSize, with
+// a 2–98 percentile stretch, and draws a box around each marker coloured
+// from yellow (lowest score) to red (highest).
+func Quicklook(grid gdal.Grid, maxSize int, markers []Marker) *image.RGBA {
+	factor := 1
+	if maxSize > 0 {
+		factor = max(1, int(math.Ceil(float64(max(grid.Width, grid.Height))/float64(maxSize))))
+	}
+	width := max(1, (grid.Width+factor-1)/factor)
+	height := max(1, (grid.Height+factor-1)/factor)
+
+	gray := Stretch(downsample(grid, factor, width, height), width, height, 2, 98)
+	img := image.NewRGBA(gray.Bounds())
+	draw.Draw(img, img.Bounds(), gray, image.Point{}, draw.Src)
+
+	lo, hi := scoreRange(markers)
+	for _, m := range markers {
+		half := max(minMarkerHalf, int(math.Round(m.Half/float64(factor))))
+		cx := int(math.Round(m.X / float64(factor)))
+		cy := int(math.Round(m.Y / float64(factor)))
+		drawBox(img, image.Rect(cx-half, cy-half, cx+half, cy+half), scoreColor(m.Score, lo, hi))
+	}
+	return img
+}
+
+// downsample averages factor×factor blocks, skipping nodata. Blocks without
+// data are NaN.
+func downsample(grid gdal.Grid, factor, width, height int) []float64 {
+	out := make([]float64, width*height)
+	for by := 0; by < height; by++ {
+		for bx := 0; bx < width; bx++ {
+			sum, n := 0.0, 0
+			for y := by * factor; y < min((by+1)*factor, grid.Height); y++ {
+				for x := bx * factor; x < min((bx+1)*factor, grid.Width); x++ {
+					if v := grid.Data[y*grid.Width+x]; v != grid.NoData && !math.IsNaN(v) {
+						sum += v
+						n++
+					}
+				}
+			}
+			out[by*width+bx] = math.NaN()
+			if n > 0 {
+				out[by*width+bx] = sum / float64(n)
+			}
+		}
+	}
+	return out
+}
+
+func scoreRange(markers []Marker) (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, m := range markers {
+		lo, hi = math.Min(lo, m.Score), math.Max(hi, m.Score)
+	}
+	return lo, hi
+}
+
+// scoreColor interpolates from yellow at lo to red at hi.
+func scoreColor(score, lo, hi float64) color.RGBA {
+	t := 1.0
+	if hi > lo {
+		t = (score - lo) / (hi - lo)
+	}
+	return color.RGBA{R: 255, G: uint8(math.Round(255 * (1 - t))), A: 255}
+}
+
+// drawBox draws the one-pixel outline of r, clipped to img.
+func drawBox(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	for x := r.Min.X; x <= r.Max.X; x++ {
+		setClipped(img, x, r.Min.Y, c)
+		setClipped(img, x, r.Max.Y, c)
+	}
+	for y := r.Min.Y; y <= r.Max.Y; y++ {
+		setClipped(img, r.Min.X, y, c)
+		setClipped(img, r.Max.X, y, c)
+	}
+}
+
+func setClipped(img *image.RGBA, x, y int, c color.RGBA) {
+	if (image.Point{X: x, Y: y}).In(img.Bounds()) {
+		img.SetRGBA(x, y, c)
+	}
+}
@@ -0,0 +1,54 @@
+package imagery
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"boatdetect/internal/gdal"
+)
+
+func rampGrid(width, height int) gdal.Grid {
+	grid := gdal.Grid{Width: width, Height: height, NoData: -9999, Data: make([]float64, width*height)}
+	for i := range grid.Data {
+		grid.Data[i] = float64(i % width)
+	}
+	return grid
+}
+
+func TestQuicklookDownsamples(t *testing.T) {
+	img := Quicklook(rampGrid(100, 40), 25, nil)
+	if img.Bounds().Dx() != 25 || img.Bounds().Dy() != 10 {
+		t.Fatalf("expected 25x10, got %v", img.Bounds())
+	}
+	left, right := img.RGBAAt(0, 5), img.RGBAAt(24, 5)
+	if left.R != 0 || right.R != 255 || left.R != left.G {
+		t.Fatalf("expected stretched grey ramp, got %v to %v", left, right)
+	}
+}
+
+func TestQuicklookMarkersColouredByScore(t *testing.T) {
+	markers := []Marker{{X: 20, Y: 20, Score: 1}, {X: 60, Y: 20, Score: 3}}
+	img := Quicklook(rampGrid(80, 40), 0, markers)
+
+	weak := img.RGBAAt(20-minMarkerHalf, 20)
+	strong := img.RGBAAt(60+minMarkerHalf, 20)
+	if weak != (color.RGBA{R: 255, G: 255, A: 255}) {
+		t.Fatalf("expected yellow box for the weakest, got %v", weak)
+	}
+	if strong != (color.RGBA{R: 255, A: 255}) {
+		t.Fatalf("expected red box for the strongest, got %v", strong)
+	}
+}
+
+func TestDownsampleSkipsNoData(t *testing.T) {
+	grid := gdal.Grid{Width: 2, Height: 2, NoData: -1, Data: []float64{-1, 4, 2, -1}}
+	got := downsample(grid, 2, 1, 1)
+	if got[0] != 3 {
+		t.Fatalf("expected mean 3, got %v", got[0])
+	}
+	grid.Data = []float64{-1, -1, -1, -1}
+	if got := downsample(grid, 2, 1, 1); !math.IsNaN(got[0]) {
+		t.Fatalf("expected NaN for an empty block, got %v", got[0])
+	}
+}
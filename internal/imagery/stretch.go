@@ -0,0 +1,46 @@
+package imagery
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Stretch renders width×height values as grey levels, mapping the low to
+// high percentile linearly onto 0–255. NaN values are black.
+func Stretch(data []float64, width, height int, low, high float64) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	lo, hi := percentileRange(data, low, high)
+	for i, v := range data[:width*height] {
+		if math.IsNaN(v) {
+			continue
+		}
+		level := 0.0
+		if hi > lo {
+			level = (v - lo) / (hi - lo) * 255
+		} else if v > 0 {
+			level = 255
+		}
+		img.Pix[(i/width)*img.Stride+i%width] = uint8(math.Round(math.Min(math.Max(level, 0), 255)))
+	}
+	return img
+}
+
+// percentileRange returns the low and high percentiles of the non-NaN data.
+func percentileRange(data []float64, low, high float64) (float64, float64) {
+	values := make([]float64, 0, len(data))
+	for _, v := range data {
+		if !math.IsNaN(v) {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sort.Float64s(values)
+	at := func(p float64) float64 {
+		i := int(math.Round(p / 100 * float64(len(values)-1)))
+		return values[min(max(i, 0), len(values)-1)]
+	}
+	return at(low), at(high)
+}
@@ -0,0 +1,42 @@
+package output
+
+import (
+	"encoding/csv"
+
+	"boatdetect/internal/atomicfile"
+)
+
+type csvWriter struct {
+	file      *atomicfile.File
+	csv       *csv.Writer
+	precision int
+	err       error
+}
+
+func newCSVWriter(file *atomicfile.File, opts Options) (Writer, error) {
+	w := &csvWriter{file: file, csv: csv.NewWriter(file), precision: opts.GeoJSON.Precision}
+	if err := w.csv.Write(Columns); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *csvWriter) Write(r Record) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.csv.Write(newRow(r, w.precision).strings())
+	return w.err
+}
+
+func (w *csvWriter) Close() error {
+	if w.err == nil {
+		w.csv.Flush()
+		w.err = w.csv.Error()
+	}
+	return finish(w.file, w.err)
+}
+
+func (w *csvWriter) Abort() {
+	w.file.Abort()
+}
@@ -0,0 +1,38 @@
+package output
+
+import (
+	"boatdetect/internal/atomicfile"
+	"boatdetect/internal/geojson"
+)
+
+type geojsonWriter struct {
+	file     *atomicfile.File
+	enc      *geojson.Encoder
+	geometry geojson.GeometryMode
+	err      error
+}
+
+func newGeoJSONWriter(file *atomicfile.File, opts Options) (Writer, error) {
+	return &geojsonWriter{file: file, enc: geojson.NewEncoder(file, opts.GeoJSON), geometry: opts.Geometry}, nil
+}
+
+func (w *geojsonWriter) Write(r Record) error {
+	if w.err != nil {
+		return w.err
+	}
+	// The encoder rounds the geometry itself.
+	properties := newRow(r, 0).properties()
+	w.err = w.enc.Encode(geojson.NewFeature(geojson.CandidateGeometry(r.Candidate, w.geometry), properties))
+	return w.err
+}
+
+func (w *geojsonWriter) Close() error {
+	if w.err == nil {
+		w.err = w.enc.Close()
+	}
+	return finish(w.file, w.err)
+}
+
+func (w *geojsonWriter) Abort() {
+	w.file.Abort()
+}
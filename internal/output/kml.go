@@ -0,0 +1,268 @@
+package output
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"boatdetect/internal/atomicfile"
+	"boatdetect/internal/detect"
+	"boatdetect/internal/geojson"
+)
+
+// scoreBands are the placemark styles from weakest to strongest score.
+// Colours are KML aabbggrr.
+var scoreBands = []struct {
+	id    string
+	color string
+}{
+	{"score-low", "ff00ffff"},
+	{"score-medium", "ff0088ff"},
+	{"score-high", "ff0000ff"},
+}
+
+// DefaultScoreBreaks split scores into the KML bands at thirds of the 0-255
+// range of the byte-scaled rasters detection runs on, so a colour means the
+// same score in every file.
+var DefaultScoreBreaks = []float64{85, 170}
+
+// kmlWriter collects the records and writes them on Close, since each
+// scene's placemarks go in one folder however the records arrive.
+type kmlWriter struct {
+	file    *atomicfile.File
+	zipped  bool
+	opts    Options
+	records []Record
+}
+
+func newKMLWriter(file *atomicfile.File, opts Options) (Writer, error) {
+	return &kmlWriter{file: file, opts: opts}, nil
+}
+
+func newKMZWriter(file *atomicfile.File, opts Options) (Writer, error) {
+	return &kmlWriter{file: file, opts: opts, zipped: true}, nil
+}
+
+func (w *kmlWriter) Write(r Record) error {
+	w.records = append(w.records, r)
+	return nil
+}
+
+func (w *kmlWriter) Close() error {
+	doc := buildKML(w.records, w.opts)
+	if !w.zipped {
+		return finish(w.file, encodeKML(w.file, doc))
+	}
+
+	zw := zip.NewWriter(w.file)
+	entry, err := zw.Create("doc.kml")
+	if err == nil {
+		err = encodeKML(entry, doc)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	return finish(w.file, err)
+}
+
+func (w *kmlWriter) Abort() {
+	w.file.Abort()
+}
+
+func encodeKML(w io.Writer, doc kmlRoot) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encode kml: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type kmlRoot struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name    string      `xml:"name"`
+	Styles  []kmlStyle  `xml:"Style"`
+	Folders []kmlFolder `xml:"Folder"`
+}
+
+type kmlStyle struct {
+	ID        string       `xml:"id,attr"`
+	IconStyle kmlIconStyle `xml:"IconStyle"`
+	LineStyle kmlLineStyle `xml:"LineStyle"`
+	PolyStyle kmlPolyStyle `xml:"PolyStyle"`
+}
+
+type kmlIconStyle struct {
+	Color string  `xml:"color"`
+	Scale float64 `xml:"scale"`
+	Icon  struct {
+		Href string `xml:"href"`
+	} `xml:"Icon"`
+}
+
+type kmlLineStyle struct {
+	Color string  `xml:"color"`
+	Width float64 `xml:"width"`
+}
+
+type kmlPolyStyle struct {
+	Color string `xml:"color"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name          string            `xml:"name"`
+	StyleURL      string            `xml:"styleUrl"`
+	Description   kmlCDATA          `xml:"description"`
+	ExtendedData  []kmlData         `xml:"ExtendedData>Data"`
+	Point         *kmlPoint         `xml:"Point,omitempty"`
+	MultiGeometry *kmlMultiGeometry `xml:"MultiGeometry,omitempty"`
+}
+
+type kmlCDATA struct {
+	Text string `xml:",cdata"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlMultiGeometry struct {
+	Point    *kmlPoint    `xml:"Point,omitempty"`
+	Polygons []kmlPolygon `xml:"Polygon"`
+}
+
+type kmlPolygon struct {
+	Outer kmlRing `xml:"outerBoundaryIs>LinearRing"`
+	// Inner holds one innerBoundaryIs per hole, each wrapping a LinearRing.
+	Inner []kmlBoundary `xml:"innerBoundaryIs"`
+}
+
+type kmlBoundary struct {
+	Ring kmlRing `xml:"LinearRing"`
+}
+
+type kmlRing struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+func buildKML(records []Record, opts Options) kmlRoot {
+	doc := kmlDocument{Name: "boatdetect detections"}
+	for _, band := range scoreBands {
+		style := kmlStyle{ID: band.id}
+		style.IconStyle.Color = band.color
+		style.IconStyle.Scale = 1
+		style.IconStyle.Icon.Href = "http://maps.google.com/mapfiles/kml/shapes/placemark_circle.png"
+		style.LineStyle = kmlLineStyle{Color: band.color, Width: 2}
+		// Footprints are filled at a quarter opacity.
+		style.PolyStyle = kmlPolyStyle{Color: "40" + band.color[2:]}
+		doc.Styles = append(doc.Styles, style)
+	}
+
+	breaks := opts.ScoreBreaks
+	if breaks == nil {
+		breaks = DefaultScoreBreaks
+	}
+	folders := make(map[string]int)
+	for _, r := range records {
+		i, ok := folders[r.SceneID]
+		if !ok {
+			i = len(doc.Folders)
+			folders[r.SceneID] = i
+			doc.Folders = append(doc.Folders, kmlFolder{Name: r.SceneID})
+		}
+		doc.Folders[i].Placemarks = append(doc.Folders[i].Placemarks, newPlacemark(r, scoreBand(r.Candidate.Score, breaks), opts))
+	}
+	return kmlRoot{Xmlns: "http://www.opengis.net/kml/2.2", Document: doc}
+}
+
+// scoreBand returns the style of the band score falls in: the first band
+// below breaks[0], the next up to breaks[1], and so on.
+func scoreBand(score float64, breaks []float64) string {
+	band := 0
+	for _, b := range breaks {
+		if score >= b {
+			band++
+		}
+	}
+	return scoreBands[min(band, len(scoreBands)-1)].id
+}
+
+func newPlacemark(r Record, band string, opts Options) kmlPlacemark {
+	precision := opts.GeoJSON.Precision
+	values := newRow(r, precision).strings()
+
+	var table strings.Builder
+	table.WriteString("<table>")
+	placemark := kmlPlacemark{
+		Name:     "score " + formatFloat(r.Candidate.Score),
+		StyleURL: "#" + band,
+	}
+	for i, column := range Columns {
+		if values[i] == "" {
+			continue
+		}
+		fmt.Fprintf(&table, "<tr><th>%s</th><td>%s</td></tr>", column, html.EscapeString(values[i]))
+		placemark.ExtendedData = append(placemark.ExtendedData, kmlData{Name: column, Value: values[i]})
+	}
+	table.WriteString("</table>")
+	placemark.Description.Text = table.String()
+
+	point := &kmlPoint{Coordinates: kmlCoordinates(precision, [2]float64{r.Candidate.Lon, r.Candidate.Lat})}
+	if opts.Geometry == geojson.GeometryPoint || len(r.Candidate.Footprint) == 0 {
+		placemark.Point = point
+		return placemark
+	}
+
+	multi := &kmlMultiGeometry{Polygons: kmlPolygons(r.Candidate.Footprint, precision)}
+	if opts.Geometry != geojson.GeometryFootprint {
+		multi.Point = point
+	}
+	placemark.MultiGeometry = multi
+	return placemark
+}
+
+func kmlPolygons(footprint []detect.Polygon, precision int) []kmlPolygon {
+	polygons := make([]kmlPolygon, 0, len(footprint))
+	for _, polygon := range footprint {
+		if len(polygon) == 0 {
+			continue
+		}
+		p := kmlPolygon{Outer: kmlRing{Coordinates: kmlCoordinates(precision, polygon[0]...)}}
+		for _, hole := range polygon[1:] {
+			p.Inner = append(p.Inner, kmlBoundary{Ring: kmlRing{Coordinates: kmlCoordinates(precision, hole...)}})
+		}
+		polygons = append(polygons, p)
+	}
+	return polygons
+}
+
+// kmlCoordinates formats lon,lat tuples separated by spaces.
+func kmlCoordinates(precision int, points ...[2]float64) string {
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = formatFloat(geojson.Round(p[0], precision)) + "," + formatFloat(geojson.Round(p[1], precision))
+	}
+	return strings.Join(parts, " ")
+}
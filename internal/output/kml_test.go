@@ -0,0 +1,137 @@
+package output
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"boatdetect/internal/detect"
+	"boatdetect/internal/geojson"
+)
+
+func readKML(t *testing.T, path string) kmlRoot {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var doc kmlRoot
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("parse kml: %v", err)
+	}
+	return doc
+}
+
+func TestKMLWriterFoldersAndStyles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boats.kml")
+	writeRecords(t, "kml", path, Options{Geometry: geojson.GeometryPoint})
+
+	doc := readKML(t, path).Document
+	if len(doc.Styles) != len(scoreBands) {
+		t.Fatalf("expected %d styles, got %d", len(scoreBands), len(doc.Styles))
+	}
+	if len(doc.Folders) != 1 || doc.Folders[0].Name != "S1A_1" || len(doc.Folders[0].Placemarks) != 2 {
+		t.Fatalf("expected one scene folder of two placemarks, got %+v", doc.Folders)
+	}
+
+	strong, weak := doc.Folders[0].Placemarks[0], doc.Folders[0].Placemarks[1]
+	if strong.StyleURL != "#score-high" || weak.StyleURL != "#score-medium" {
+		t.Fatalf("expected high and medium bands, got %s and %s", strong.StyleURL, weak.StyleURL)
+	}
+	if strong.Point == nil || strong.Point.Coordinates != "103.123456789,1.987654321" {
+		t.Fatalf("expected point coordinates, got %+v", strong.Point)
+	}
+	if !strings.Contains(strong.Description.Text, "<th>length_m</th><td>42</td>") {
+		t.Fatalf("expected length in description, got %s", strong.Description.Text)
+	}
+	if !strings.Contains(weak.Description.Text, "azimuth_ambiguity") {
+		t.Fatalf("expected reason in description, got %s", weak.Description.Text)
+	}
+}
+
+func TestBuildKMLGroupsScenesAndUsesFixedBreaks(t *testing.T) {
+	records := []Record{
+		{SceneID: "a", Candidate: detect.Candidate{Score: 10}},
+		{SceneID: "b", Candidate: detect.Candidate{Score: 50}},
+		{SceneID: "a", Candidate: detect.Candidate{Score: 12}},
+	}
+	doc := buildKML(records, Options{ScoreBreaks: []float64{11, 40}}).Document
+	if len(doc.Folders) != 2 || doc.Folders[0].Name != "a" || len(doc.Folders[0].Placemarks) != 2 || doc.Folders[1].Name != "b" {
+		t.Fatalf("expected folders a (2 placemarks) and b, got %+v", doc.Folders)
+	}
+	var styles []string
+	for _, folder := range doc.Folders {
+		for _, p := range folder.Placemarks {
+			styles = append(styles, p.StyleURL)
+		}
+	}
+	if strings.Join(styles, ",") != "#score-low,#score-medium,#score-high" {
+		t.Fatalf("expected low, medium and high bands, got %v", styles)
+	}
+
+	// A file of equal scores is banded by the breaks, not its own range.
+	same := buildKML(records[:1], Options{}).Document
+	if got := same.Folders[0].Placemarks[0].StyleURL; got != "#score-low" {
+		t.Fatalf("expected low band for score 10, got %s", got)
+	}
+}
+
+func TestKMLWriterFootprint(t *testing.T) {
+	ring := [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 0}}
+	r := Record{SceneID: "s", Candidate: detect.Candidate{Footprint: []detect.Polygon{{ring, ring}}}}
+
+	both := newPlacemark(r, "score-low", Options{Geometry: geojson.GeometryBoth})
+	if both.MultiGeometry == nil || both.MultiGeometry.Point == nil || len(both.MultiGeometry.Polygons) != 1 {
+		t.Fatalf("expected point and polygon, got %+v", both)
+	}
+	encoded, err := xml.Marshal(both)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, want := range []string{
+		"<outerBoundaryIs><LinearRing><coordinates>",
+		"<innerBoundaryIs><LinearRing><coordinates>",
+	} {
+		if got := strings.Count(string(encoded), want); got != 1 {
+			t.Fatalf("expected one %s, got %d in %s", want, got, encoded)
+		}
+	}
+
+	footprint := newPlacemark(r, "score-low", Options{Geometry: geojson.GeometryFootprint})
+	if footprint.MultiGeometry == nil || footprint.MultiGeometry.Point != nil {
+		t.Fatalf("expected polygon only, got %+v", footprint.MultiGeometry)
+	}
+
+	point := newPlacemark(r, "score-low", Options{Geometry: geojson.GeometryPoint})
+	if point.Point == nil || point.MultiGeometry != nil {
+		t.Fatalf("expected point only, got %+v", point)
+	}
+}
+
+func TestKMZWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boats.kmz")
+	writeRecords(t, "kmz", path, Options{})
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("open kmz: %v", err)
+	}
+	defer zr.Close()
+	if len(zr.File) != 1 || zr.File[0].Name != "doc.kml" {
+		t.Fatalf("expected doc.kml, got %v", zr.File)
+	}
+	rc, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatalf("open doc.kml: %v", err)
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	var doc kmlRoot
+	if err := xml.Unmarshal(data, &doc); err != nil || len(doc.Document.Folders) != 1 {
+		t.Fatalf("expected one folder, got %+v (%v)", doc.Document.Folders, err)
+	}
+}
@@ -0,0 +1,375 @@
+package output
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"boatdetect/internal/atomicfile"
+	"boatdetect/internal/detect"
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/geojson"
+)
+
+// ogrTypes are the OGR field types of Columns, written as a .csvt sidecar so
+// ogr2ogr does not guess them from the values.
+var ogrTypes = []string{
+	"String", "String", "Real",
+	"Real", "Real", "Real", "Integer",
+	"Real", "Real", "Real",
+	"String", "String",
+}
+
+// dbfNames shortens columns longer than the ten characters a DBF field
+// name allows.
+var dbfNames = map[string]string{
+	"orientation_deg": "orient_deg",
+}
+
+// ogrWriter collects the records and converts them with ogr2ogr on Close.
+// The conversion runs in a temporary directory of the workspace; the files
+// it produces are then copied next to the target and renamed into place
+// only once every one of them is staged.
+type ogrWriter struct {
+	ctx     context.Context
+	path    string
+	opts    Options
+	records []Record
+	convert func(w *ogrWriter, dir string) error
+}
+
+func newShapefileWriter(ctx context.Context, path string, opts Options) (Writer, error) {
+	return newOGRWriter(ctx, path, opts, (*ogrWriter).shapefile)
+}
+
+func newGeoPackageWriter(ctx context.Context, path string, opts Options) (Writer, error) {
+	return newOGRWriter(ctx, path, opts, (*ogrWriter).geopackage)
+}
+
+func newOGRWriter(ctx context.Context, path string, opts Options, convert func(w *ogrWriter, dir string) error) (Writer, error) {
+	if opts.Workspace == nil {
+		return nil, fmt.Errorf("no GDAL workspace for the conversion")
+	}
+	return &ogrWriter{ctx: ctx, path: path, opts: opts, convert: convert}, nil
+}
+
+func (w *ogrWriter) Write(r Record) error {
+	w.records = append(w.records, r)
+	return nil
+}
+
+// Abort drops the records; nothing is written before Close.
+func (w *ogrWriter) Abort() {
+	w.records = nil
+}
+
+func (w *ogrWriter) Close() error {
+	dir, cleanup, err := w.opts.Workspace.TempDir("ogr-")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := os.Mkdir(filepath.Join(dir, "src"), 0o755); err != nil {
+		return err
+	}
+	if err := w.convert(w, dir); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return replaceFiles(dir, filepath.Dir(w.path), names)
+}
+
+// replaceFiles copies the named files from srcDir to temporary files in
+// dstDir and, once all are staged, renames them over their targets, so a
+// failed copy leaves every target as it was.
+func replaceFiles(srcDir, dstDir string, names []string) error {
+	staged := make([]string, 0, len(names))
+	defer func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+	}()
+
+	for _, name := range names {
+		tmp, err := stageFile(filepath.Join(srcDir, name), dstDir)
+		if err != nil {
+			return fmt.Errorf("stage %s: %w", name, err)
+		}
+		staged = append(staged, tmp)
+	}
+	for i, name := range names {
+		if err := os.Rename(staged[i], filepath.Join(dstDir, name)); err != nil {
+			return fmt.Errorf("rename %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// stageFile copies src to a synced temporary file in dir and returns its
+// path.
+func stageFile(src, dir string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(dir, "."+filepath.Base(src)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if err == nil {
+		err = out.Chmod(0o644)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// shapefile writes the points to <base>.shp and, when there are any,
+// the footprints to <base>_footprints.shp, since a shapefile holds a
+// single geometry type.
+func (w *ogrWriter) shapefile(dir string) error {
+	base := strings.TrimSuffix(filepath.Base(w.path), filepath.Ext(w.path))
+	args := []string{"-f", "ESRI Shapefile", "-lco", "ENCODING=UTF-8", "-lco", "RESIZE=YES"}
+
+	src, err := w.pointSource(dir, "points", w.records, dbfName)
+	if err != nil {
+		return err
+	}
+	if err := gdal.VectorTranslate(w.ctx, filepath.Join(dir, base+".shp"), src, append(args, pointArgs...)...); err != nil {
+		return err
+	}
+
+	footprints := w.footprintRecords(w.records)
+	if len(footprints) == 0 {
+		return nil
+	}
+	src, err = w.footprintSource(dir, "footprints", footprints, dbfName)
+	if err != nil {
+		return err
+	}
+	return gdal.VectorTranslate(w.ctx, filepath.Join(dir, base+"_footprints.shp"), src, append(args, footprintArgs...)...)
+}
+
+// geopackage writes one point layer per scene, plus a <layer>_footprints
+// layer for scenes with footprints.
+func (w *ogrWriter) geopackage(dir string) error {
+	dst := filepath.Join(dir, filepath.Base(w.path))
+	scenes := groupByScene(w.records)
+	if len(scenes) == 0 {
+		// An empty layer keeps the schema visible to readers.
+		scenes = []sceneRecords{{layer: "detections"}}
+	}
+
+	created := false
+	translate := func(src, layer string, args []string) error {
+		cmdArgs := []string{"-f", "GPKG", "-nln", layer}
+		if created {
+			cmdArgs = append(cmdArgs, "-update")
+		}
+		created = true
+		return gdal.VectorTranslate(w.ctx, dst, src, append(cmdArgs, args...)...)
+	}
+
+	for i, scene := range scenes {
+		src, err := w.pointSource(dir, fmt.Sprintf("points-%d", i), scene.records, nil)
+		if err != nil {
+			return err
+		}
+		if err := translate(src, scene.layer, pointArgs); err != nil {
+			return err
+		}
+
+		footprints := w.footprintRecords(scene.records)
+		if len(footprints) == 0 {
+			continue
+		}
+		src, err = w.footprintSource(dir, fmt.Sprintf("footprints-%d", i), footprints, nil)
+		if err != nil {
+			return err
+		}
+		if err := translate(src, scene.layer+"_footprints", footprintArgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var pointArgs = []string{
+	"-oo", "X_POSSIBLE_NAMES=lon", "-oo", "Y_POSSIBLE_NAMES=lat",
+	"-a_srs", "EPSG:4326", "-nlt", "POINT",
+}
+
+var footprintArgs = []string{
+	"-oo", "GEOM_POSSIBLE_NAMES=wkt", "-oo", "KEEP_GEOM_COLUMNS=NO",
+	"-a_srs", "EPSG:4326", "-nlt", "MULTIPOLYGON",
+}
+
+// footprintRecords returns the records with footprints, or none when the
+// geometry mode asks for points only.
+func (w *ogrWriter) footprintRecords(records []Record) []Record {
+	if w.opts.Geometry == geojson.GeometryPoint {
+		return nil
+	}
+	var out []Record
+	for _, r := range records {
+		if len(r.Candidate.Footprint) > 0 {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (w *ogrWriter) pointSource(dir, name string, records []Record, rename func(string) string) (string, error) {
+	return writeOGRSource(filepath.Join(dir, "src", name+".csv"), records, w.opts.GeoJSON.Precision, rename, false)
+}
+
+func (w *ogrWriter) footprintSource(dir, name string, records []Record, rename func(string) string) (string, error) {
+	return writeOGRSource(filepath.Join(dir, "src", name+".csv"), records, w.opts.GeoJSON.Precision, rename, true)
+}
+
+// writeOGRSource writes records as a CSV with a .csvt sidecar, renaming the
+// columns with rename when set and appending a footprint wkt column when
+// footprint is set.
+func writeOGRSource(path string, records []Record, precision int, rename func(string) string, footprint bool) (string, error) {
+	header := make([]string, len(Columns))
+	for i, column := range Columns {
+		header[i] = column
+		if rename != nil {
+			header[i] = rename(column)
+		}
+	}
+	types := append([]string(nil), ogrTypes...)
+	if footprint {
+		header = append(header, "wkt")
+		types = append(types, "WKT")
+	}
+
+	err := atomicfile.Write(path, func(f io.Writer) error {
+		cw := csv.NewWriter(f)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		for _, r := range records {
+			values := newRow(r, precision).strings()
+			if footprint {
+				values = append(values, footprintWKT(r.Candidate.Footprint, precision))
+			}
+			if err := cw.Write(values); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	if err != nil {
+		return "", fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+
+	csvt := quoteAll(types)
+	if err := os.WriteFile(strings.TrimSuffix(path, ".csv")+".csvt", []byte(csvt+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("write field types: %w", err)
+	}
+	return path, nil
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = `"` + v + `"`
+	}
+	return strings.Join(quoted, ",")
+}
+
+// dbfName returns column as a DBF field name of at most ten characters.
+func dbfName(column string) string {
+	if name, ok := dbfNames[column]; ok {
+		return name
+	}
+	if len(column) > 10 {
+		return column[:10]
+	}
+	return column
+}
+
+// footprintWKT returns the footprint as a WKT MULTIPOLYGON.
+func footprintWKT(footprint []detect.Polygon, precision int) string {
+	polygons := make([]string, 0, len(footprint))
+	for _, polygon := range footprint {
+		rings := make([]string, len(polygon))
+		for i, ring := range polygon {
+			points := make([]string, len(ring))
+			for j, p := range ring {
+				points[j] = formatFloat(geojson.Round(p[0], precision)) + " " + formatFloat(geojson.Round(p[1], precision))
+			}
+			rings[i] = "(" + strings.Join(points, ", ") + ")"
+		}
+		polygons = append(polygons, "("+strings.Join(rings, ", ")+")")
+	}
+	return "MULTIPOLYGON (" + strings.Join(polygons, ", ") + ")"
+}
+
+// sceneRecords are the records of one scene and its GeoPackage layer name.
+type sceneRecords struct {
+	layer   string
+	records []Record
+}
+
+// groupByScene splits records into consecutive scenes with unique layer names.
+func groupByScene(records []Record) []sceneRecords {
+	var scenes []sceneRecords
+	used := make(map[string]bool)
+	for _, r := range records {
+		if len(scenes) > 0 && scenes[len(scenes)-1].records[0].SceneID == r.SceneID {
+			last := &scenes[len(scenes)-1]
+			last.records = append(last.records, r)
+			continue
+		}
+		layer := layerName(r.SceneID)
+		for i := 2; used[layer]; i++ {
+			layer = fmt.Sprintf("%s_%d", layerName(r.SceneID), i)
+		}
+		used[layer] = true
+		scenes = append(scenes, sceneRecords{layer: layer, records: []Record{r}})
+	}
+	return scenes
+}
+
+// layerName keeps letters, digits and underscores of a scene ID.
+func layerName(sceneID string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, sceneID)
+	if name == "" {
+		return "scene"
+	}
+	return name
+}
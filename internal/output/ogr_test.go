@@ -0,0 +1,197 @@
+package output
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"boatdetect/internal/detect"
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/geojson"
+)
+
+// fakeOGR2OGR installs an ogr2ogr that logs its arguments, keeps a copy of
+// its CSV source and creates the destination (and a .dbf for shapefiles).
+func fakeOGR2OGR(t *testing.T) (logPath, sources string) {
+	t.Helper()
+	dir := t.TempDir()
+	logPath = filepath.Join(dir, "calls")
+	sources = filepath.Join(dir, "sources")
+	if err := os.Mkdir(sources, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	script := `#!/bin/sh
+for a; do dst=$src; src=$a; done
+echo "$*" >> ` + logPath + `
+cp "$src" "${src%.csv}.csvt" ` + sources + `/
+touch "$dst"
+case "$dst" in *.shp) touch "${dst%.shp}.dbf" ;; esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "ogr2ogr"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("BOATDETECT_GDAL_MODE", "local")
+	return logPath, sources
+}
+
+func footprintRecords() []Record {
+	ring := [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 0}}
+	records := append([]Record(nil), testRecords...)
+	records[0].Candidate.Footprint = []detect.Polygon{{ring}}
+	return append(records, Record{SceneID: "S1B/2", Candidate: detect.Candidate{Score: 1, AreaPx: 1}})
+}
+
+func writeOGR(t *testing.T, name, path string, records []Record, opts Options) {
+	t.Helper()
+	ws, err := gdal.NewWorkspace(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("workspace: %v", err)
+	}
+	defer ws.Close()
+	opts.Workspace = ws
+	format, _ := Lookup(name)
+	w, err := format.Create(context.Background(), path, opts)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestShapefileWriter(t *testing.T) {
+	logPath, sources := fakeOGR2OGR(t)
+	dir := t.TempDir()
+	writeOGR(t, "shapefile", filepath.Join(dir, "boats.shp"), footprintRecords(), Options{Geometry: geojson.GeometryBoth})
+
+	for _, name := range []string{"boats.shp", "boats.dbf", "boats_footprints.shp", "boats_footprints.dbf"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 4 {
+		t.Fatalf("expected no temporary files, got %v", entries)
+	}
+
+	calls := readLines(t, logPath)
+	if len(calls) != 2 || !strings.Contains(calls[0], "-nlt POINT") || !strings.Contains(calls[1], "-nlt MULTIPOLYGON") {
+		t.Fatalf("expected point and footprint conversions, got %v", calls)
+	}
+	if strings.Contains(calls[0], dir) || !strings.Contains(calls[0], "ogr-") {
+		t.Fatalf("expected conversion in the workspace, got %s", calls[0])
+	}
+
+	header := readLines(t, filepath.Join(sources, "points.csv"))[0]
+	if !strings.Contains(header, "orient_deg") || strings.Contains(header, "orientation_deg") {
+		t.Fatalf("expected DBF field names, got %s", header)
+	}
+	for _, field := range strings.Split(header, ",") {
+		if len(field) > 10 {
+			t.Fatalf("field %q exceeds the DBF limit", field)
+		}
+	}
+	types := readLines(t, filepath.Join(sources, "points.csvt"))[0]
+	if !strings.HasPrefix(types, `"String","String","Real","Real","Real","Real","Integer"`) {
+		t.Fatalf("expected typed fields, got %s", types)
+	}
+
+	footprints := readLines(t, filepath.Join(sources, "footprints.csv"))
+	if len(footprints) != 2 || !strings.HasSuffix(footprints[1], `"MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)))"`) {
+		t.Fatalf("expected one footprint row, got %v", footprints)
+	}
+}
+
+func TestGeoPackageWriterLayersPerScene(t *testing.T) {
+	logPath, _ := fakeOGR2OGR(t)
+	dir := t.TempDir()
+	writeOGR(t, "gpkg", filepath.Join(dir, "boats.gpkg"), footprintRecords(), Options{Geometry: geojson.GeometryPoint})
+
+	if _, err := os.Stat(filepath.Join(dir, "boats.gpkg")); err != nil {
+		t.Fatalf("expected geopackage: %v", err)
+	}
+	calls := readLines(t, logPath)
+	if len(calls) != 2 {
+		t.Fatalf("expected one layer per scene and no footprints, got %v", calls)
+	}
+	if !strings.Contains(calls[0], "-nln S1A_1 ") || strings.Contains(calls[0], "-update") {
+		t.Fatalf("expected first call to create layer S1A_1, got %s", calls[0])
+	}
+	if !strings.Contains(calls[1], "-nln S1B_2 -update") {
+		t.Fatalf("expected second call to add layer S1B_2, got %s", calls[1])
+	}
+}
+
+func TestReplaceFilesStagesAllBeforeRenaming(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	for _, name := range []string{"boats.shp", "boats.shx"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte("new"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dst, name), []byte("old"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	if err := replaceFiles(src, dst, []string{"boats.shp", "boats.dbf", "boats.shx"}); err == nil {
+		t.Fatalf("expected error for a missing sidecar")
+	}
+	entries, _ := os.ReadDir(dst)
+	if len(entries) != 2 {
+		t.Fatalf("expected staged files removed, got %v", entries)
+	}
+	for _, name := range []string{"boats.shp", "boats.shx"} {
+		if data, _ := os.ReadFile(filepath.Join(dst, name)); string(data) != "old" {
+			t.Fatalf("expected %s untouched, got %q", name, data)
+		}
+	}
+
+	if err := replaceFiles(src, dst, []string{"boats.shp", "boats.shx"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "boats.shx")); string(data) != "new" {
+		t.Fatalf("expected boats.shx replaced, got %q", data)
+	}
+}
+
+func TestOGRWriterNeedsWorkspace(t *testing.T) {
+	format, _ := Lookup("gpkg")
+	if _, err := format.Create(context.Background(), filepath.Join(t.TempDir(), "boats.gpkg"), Options{}); err == nil {
+		t.Fatalf("expected error without a workspace")
+	}
+}
+
+func TestGroupByScene(t *testing.T) {
+	records := []Record{{SceneID: "a.b"}, {SceneID: "a.b"}, {SceneID: "a_b"}, {SceneID: ""}}
+	scenes := groupByScene(records)
+	var layers []string
+	for _, scene := range scenes {
+		layers = append(layers, scene.layer)
+	}
+	if strings.Join(layers, ",") != "a_b,a_b_2,scene" || len(scenes[0].records) != 2 {
+		t.Fatalf("expected unique layers a_b,a_b_2,scene, got %v", layers)
+	}
+}
+
+func TestOGRTypesCoverColumns(t *testing.T) {
+	if len(ogrTypes) != len(Columns) {
+		t.Fatalf("expected %d field types, got %d", len(Columns), len(ogrTypes))
+	}
+}
@@ -0,0 +1,139 @@
+// Package output writes detected candidates in the supported file formats.
+package output
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"boatdetect/internal/atomicfile"
+	"boatdetect/internal/detect"
+	"boatdetect/internal/gdal"
+	"boatdetect/internal/geojson"
+)
+
+// Record is one candidate with the metadata of the scene it came from.
+type Record struct {
+	SceneID string
+	// Source is the input raster the scene was read from.
+	Source string
+	// Threshold is the intensity threshold applied to the scene.
+	Threshold float64
+	Candidate detect.Candidate
+	// Chip is the path of the candidate's image chip, if one was cut.
+	Chip string
+}
+
+// Writer writes records to one output file. Close finishes the file and
+// replaces the target atomically; after a failed Write it discards the file
+// and returns the error. Abort discards the file and leaves the target
+// untouched; it is a no-op after Close.
+type Writer interface {
+	Write(r Record) error
+	Close() error
+	Abort()
+}
+
+// Options configures the writers.
+type Options struct {
+	// GeoJSON configures the geojson layout and coordinate precision.
+	// Precision also applies to the lon and lat columns of tabular formats.
+	GeoJSON  geojson.EncoderOptions
+	Geometry geojson.GeometryMode
+	// ScoreBreaks are the ascending scores at which KML placemarks move to
+	// the next score band, one fewer than the bands; nil uses
+	// DefaultScoreBreaks.
+	ScoreBreaks []float64
+	// Workspace holds the ogr2ogr conversions of the shapefile and gpkg
+	// formats, so that in Docker mode they run under the mounted directory.
+	Workspace *gdal.Workspace
+}
+
+// Format is an output format.
+type Format struct {
+	Name string
+	// Ext is the file extension, including the dot.
+	Ext  string
+	open func(ctx context.Context, path string, opts Options) (Writer, error)
+}
+
+var formats = []Format{
+	{Name: "geojson", Ext: ".geojson", open: fileWriter(newGeoJSONWriter)},
+	{Name: "csv", Ext: ".csv", open: fileWriter(newCSVWriter)},
+	{Name: "parquet", Ext: ".parquet", open: fileWriter(newParquetWriter)},
+	{Name: "kml", Ext: ".kml", open: fileWriter(newKMLWriter)},
+	{Name: "kmz", Ext: ".kmz", open: fileWriter(newKMZWriter)},
+	{Name: "shapefile", Ext: ".shp", open: newShapefileWriter},
+	{Name: "gpkg", Ext: ".gpkg", open: newGeoPackageWriter},
+}
+
+// Names returns the names of the supported formats.
+func Names() []string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Lookup returns the format called name.
+func Lookup(name string) (Format, error) {
+	for _, f := range formats {
+		if strings.EqualFold(f.Name, strings.TrimSpace(name)) {
+			return f, nil
+		}
+	}
+	return Format{}, fmt.Errorf("unknown output format %q", name)
+}
+
+// Path returns the file format f writes for out when writing count formats.
+// A single format, or the one whose extension out already has, writes to
+// out itself; the others replace its extension with their own.
+func (f Format) Path(out string, count int) string {
+	ext := filepath.Ext(out)
+	if count == 1 || f.matches(ext) {
+		return out
+	}
+	return strings.TrimSuffix(out, ext) + f.Ext
+}
+
+func (f Format) matches(ext string) bool {
+	ext = strings.ToLower(ext)
+	return ext == f.Ext || (f.Name == "geojson" && ext == ".json")
+}
+
+// Create opens a writer for path. ctx bounds the GDAL conversions of
+// formats written through ogr2ogr.
+func (f Format) Create(ctx context.Context, path string, opts Options) (Writer, error) {
+	w, err := f.open(ctx, path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("open %s writer: %w", f.Name, err)
+	}
+	return w, nil
+}
+
+// fileWriter adapts a constructor of writers to a single atomic file.
+func fileWriter(open func(file *atomicfile.File, opts Options) (Writer, error)) func(context.Context, string, Options) (Writer, error) {
+	return func(_ context.Context, path string, opts Options) (Writer, error) {
+		file, err := atomicfile.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		w, err := open(file, opts)
+		if err != nil {
+			file.Abort()
+			return nil, err
+		}
+		return w, nil
+	}
+}
+
+// finish commits file, or aborts it and returns err when err is set.
+func finish(file *atomicfile.File, err error) error {
+	if err != nil {
+		file.Abort()
+		return err
+	}
+	return file.Commit()
+}
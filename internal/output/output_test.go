@@ -0,0 +1,206 @@
+package output
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+
+	"boatdetect/internal/detect"
+	"boatdetect/internal/geojson"
+)
+
+var testRecords = []Record{
+	{
+		SceneID:   "S1A_1",
+		Source:    "data/s1a.tif",
+		Threshold: 12.5,
+		Candidate: detect.Candidate{
+			Lon: 103.123456789, Lat: 1.987654321, Score: 200, AreaPx: 7,
+			Shape: detect.Shape{LengthM: 42, WidthM: 9.5, OrientationDeg: 135},
+		},
+	},
+	{
+		SceneID: "S1A_1", Source: "data/s1a.tif", Threshold: 12.5,
+		Candidate: detect.Candidate{Lon: 103.5, Lat: 1.5, Score: 90, AreaPx: 2, Reason: "azimuth_ambiguity"},
+	},
+}
+
+func writeRecords(t *testing.T, name, path string, opts Options) {
+	t.Helper()
+	format, err := Lookup(name)
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	w, err := format.Create(context.Background(), path, opts)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	for _, r := range testRecords {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if !reflect.DeepEqual(Names(), []string{"geojson", "csv", "parquet", "kml", "kmz", "shapefile", "gpkg"}) {
+		t.Fatalf("expected every format, got %v", Names())
+	}
+	if f, err := Lookup(" CSV "); err != nil || f.Name != "csv" {
+		t.Fatalf("expected csv, got %v (%v)", f.Name, err)
+	}
+	if _, err := Lookup("shp"); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	geojsonFormat, _ := Lookup("geojson")
+	csvFormat, _ := Lookup("csv")
+	tests := []struct {
+		format Format
+		out    string
+		count  int
+		want   string
+	}{
+		{csvFormat, "out/boats.geojson", 2, "out/boats.csv"},
+		{csvFormat, "out/boats.CSV", 2, "out/boats.CSV"},
+		{geojsonFormat, "out/boats.geojson", 2, "out/boats.geojson"},
+		{geojsonFormat, "out/boats.json", 2, "out/boats.json"},
+		{geojsonFormat, "out/boats.csv", 2, "out/boats.geojson"},
+		{csvFormat, "out/boats", 2, "out/boats.csv"},
+		{geojsonFormat, "out/results", 1, "out/results"},
+		{csvFormat, "out/boats.txt", 1, "out/boats.txt"},
+	}
+	for _, tt := range tests {
+		if got := tt.format.Path(tt.out, tt.count); got != tt.want {
+			t.Fatalf("%s %s (%d formats): expected %s, got %s", tt.format.Name, tt.out, tt.count, tt.want, got)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boats.csv")
+	writeRecords(t, "csv", path, Options{GeoJSON: geojson.EncoderOptions{Precision: 6}})
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 3 || !reflect.DeepEqual(rows[0], Columns) {
+		t.Fatalf("expected header and two rows, got %v", rows)
+	}
+	want := []string{"S1A_1", "data/s1a.tif", "12.5", "103.123457", "1.987654", "200", "7", "42", "9.5", "135", "", ""}
+	if !reflect.DeepEqual(rows[1], want) {
+		t.Fatalf("expected %v, got %v", want, rows[1])
+	}
+	if rows[2][len(Columns)-2] != "azimuth_ambiguity" {
+		t.Fatalf("expected reason column, got %v", rows[2])
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boats.parquet")
+	writeRecords(t, "parquet", path, Options{})
+
+	rows, err := parquet.ReadFile[row](path)
+	if err != nil {
+		t.Fatalf("read parquet: %v", err)
+	}
+	want := []row{newRow(testRecords[0], 0), newRow(testRecords[1], 0)}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("expected %+v, got %+v", want, rows)
+	}
+
+	var names []string
+	for _, field := range parquet.SchemaOf(row{}).Fields() {
+		names = append(names, field.Name())
+	}
+	if !reflect.DeepEqual(names, Columns) {
+		t.Fatalf("expected parquet columns %v, got %v", Columns, names)
+	}
+}
+
+func TestGeoJSONWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boats.geojson")
+	writeRecords(t, "geojson", path, Options{Geometry: geojson.GeometryPoint})
+
+	fc, err := geojson.ReadFeatureCollection(path)
+	if err != nil {
+		t.Fatalf("read geojson: %v", err)
+	}
+	if len(fc.Features) != 2 {
+		t.Fatalf("expected 2 features, got %d", len(fc.Features))
+	}
+	props := fc.Features[0].Properties
+	if props.String("source") != "data/s1a.tif" {
+		t.Fatalf("expected source property, got %v", props)
+	}
+	if length, ok := props.Float("length_m"); !ok || length != 42 {
+		t.Fatalf("expected length_m 42, got %v", props["length_m"])
+	}
+	if _, ok := props["reason"]; ok {
+		t.Fatalf("expected no reason for a clean candidate, got %v", props)
+	}
+}
+
+func TestWriterFailureKeepsTarget(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "boats.csv")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	format, _ := Lookup("csv")
+	w, err := format.Create(context.Background(), path, Options{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	w.(*csvWriter).err = os.ErrClosed
+	if err := w.Close(); err != os.ErrClosed {
+		t.Fatalf("expected write error from close, got %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	entries, _ := os.ReadDir(dir)
+	if string(data) != "old" || len(entries) != 1 {
+		t.Fatalf("expected original file only, got %q and %d entries", data, len(entries))
+	}
+}
+
+func TestWriterAbortKeepsTarget(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "boats.geojson")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	format, _ := Lookup("geojson")
+	w, err := format.Create(context.Background(), path, Options{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := w.Write(testRecords[0]); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	w.Abort()
+
+	data, _ := os.ReadFile(path)
+	entries, _ := os.ReadDir(dir)
+	if string(data) != "old" || len(entries) != 1 {
+		t.Fatalf("expected original file only, got %q and %d entries", data, len(entries))
+	}
+}
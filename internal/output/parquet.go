@@ -0,0 +1,38 @@
+package output
+
+import (
+	"github.com/parquet-go/parquet-go"
+
+	"boatdetect/internal/atomicfile"
+)
+
+type parquetWriter struct {
+	file      *atomicfile.File
+	pq        *parquet.GenericWriter[row]
+	precision int
+	err       error
+}
+
+func newParquetWriter(file *atomicfile.File, opts Options) (Writer, error) {
+	pq := parquet.NewGenericWriter[row](file, parquet.Compression(&parquet.Snappy))
+	return &parquetWriter{file: file, pq: pq, precision: opts.GeoJSON.Precision}, nil
+}
+
+func (w *parquetWriter) Write(r Record) error {
+	if w.err != nil {
+		return w.err
+	}
+	_, w.err = w.pq.Write([]row{newRow(r, w.precision)})
+	return w.err
+}
+
+func (w *parquetWriter) Close() error {
+	if w.err == nil {
+		w.err = w.pq.Close()
+	}
+	return finish(w.file, w.err)
+}
+
+func (w *parquetWriter) Abort() {
+	w.file.Abort()
+}
@@ -0,0 +1,85 @@
+package output
+
+import (
+	"strconv"
+
+	"boatdetect/internal/geojson"
+)
+
+// Columns names the candidate fields in the order tabular formats write them.
+var Columns = []string{
+	"scene_id", "source", "threshold",
+	"lon", "lat", "score", "area_px",
+	"length_m", "width_m", "orientation_deg",
+	"reason", "chip",
+}
+
+// row is the flat candidate schema shared by every format.
+type row struct {
+	SceneID        string  `parquet:"scene_id"`
+	Source         string  `parquet:"source"`
+	Threshold      float64 `parquet:"threshold"`
+	Lon            float64 `parquet:"lon"`
+	Lat            float64 `parquet:"lat"`
+	Score          float64 `parquet:"score"`
+	AreaPx         int64   `parquet:"area_px"`
+	LengthM        float64 `parquet:"length_m"`
+	WidthM         float64 `parquet:"width_m"`
+	OrientationDeg float64 `parquet:"orientation_deg"`
+	Reason         string  `parquet:"reason"`
+	Chip           string  `parquet:"chip"`
+}
+
+func newRow(r Record, precision int) row {
+	c := r.Candidate
+	return row{
+		SceneID:        r.SceneID,
+		Source:         r.Source,
+		Threshold:      r.Threshold,
+		Lon:            geojson.Round(c.Lon, precision),
+		Lat:            geojson.Round(c.Lat, precision),
+		Score:          c.Score,
+		AreaPx:         int64(c.AreaPx),
+		LengthM:        c.Shape.LengthM,
+		WidthM:         c.Shape.WidthM,
+		OrientationDeg: c.Shape.OrientationDeg,
+		Reason:         c.Reason,
+		Chip:           r.Chip,
+	}
+}
+
+// strings returns the row in Columns order.
+func (r row) strings() []string {
+	return []string{
+		r.SceneID, r.Source, formatFloat(r.Threshold),
+		formatFloat(r.Lon), formatFloat(r.Lat), formatFloat(r.Score), strconv.FormatInt(r.AreaPx, 10),
+		formatFloat(r.LengthM), formatFloat(r.WidthM), formatFloat(r.OrientationDeg),
+		r.Reason, r.Chip,
+	}
+}
+
+// properties returns the row as feature properties; the position is the
+// geometry and an empty reason or chip is omitted.
+func (r row) properties() geojson.Properties {
+	properties := geojson.Properties{
+		"scene_id":        r.SceneID,
+		"source":          r.Source,
+		"threshold":       r.Threshold,
+		"score":           r.Score,
+		"area_px":         r.AreaPx,
+		"length_m":        r.LengthM,
+		"width_m":         r.WidthM,
+		"orientation_deg": r.OrientationDeg,
+	}
+	if r.Reason != "" {
+		properties["reason"] = r.Reason
+	}
+	if r.Chip != "" {
+		properties["chip"] = r.Chip
+	}
+	return properties
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
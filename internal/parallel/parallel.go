@@ -0,0 +1,124 @@
+// Package parallel runs indexed work on a bounded pool of goroutines.
+package parallel
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Limiter is a goroutine budget shared by nested ForEach calls, such as
+// scenes and the tiles and label bands within each scene, so together they
+// stay within one bound rather than multiplying their worker counts.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter returns a limiter allowing n goroutines at once, counting the
+// one that starts the outermost ForEach (zero uses one per CPU).
+func NewLimiter(n int) *Limiter {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	return &Limiter{slots: make(chan struct{}, n-1)}
+}
+
+// tryAcquire takes a slot without waiting; a nil limiter always has one.
+func (l *Limiter) tryAcquire() bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *Limiter) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
+
+type limiterKey struct{}
+
+// WithLimiter returns a context whose ForEach calls, and those nested in
+// them, share the budget of l.
+func WithLimiter(ctx context.Context, l *Limiter) context.Context {
+	return context.WithValue(ctx, limiterKey{}, l)
+}
+
+func limiterFrom(ctx context.Context) *Limiter {
+	l, _ := ctx.Value(limiterKey{}).(*Limiter)
+	return l
+}
+
+// ForEach calls fn for 0..n-1 on up to workers goroutines (zero uses one per
+// CPU). The calling goroutine works through indices itself; when ctx carries
+// a Limiter, each further worker starts only once it takes a free slot,
+// retried between indices, so a caller that already holds a slot never waits
+// for one and nested calls cannot deadlock. The first error cancels the
+// context passed to the remaining calls, stops handing out indices and is
+// returned once running calls finish.
+func ForEach(ctx context.Context, n, workers int, fn func(ctx context.Context, i int) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	limiter := limiterFrom(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var next atomic.Int64
+	var once sync.Once
+	var firstErr error
+
+	// step runs the next index and reports whether there was one to run.
+	step := func() bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		i := int(next.Add(1) - 1)
+		if i >= n {
+			return false
+		}
+		if err := fn(ctx, i); err != nil {
+			once.Do(func() {
+				firstErr = err
+				cancel()
+			})
+		}
+		return true
+	}
+
+	var wg sync.WaitGroup
+	started := 1
+	grow := func() {
+		for started < min(workers, n) && int(next.Load()) < n && limiter.tryAcquire() {
+			started++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer limiter.release()
+				for step() {
+				}
+			}()
+		}
+	}
+
+	for {
+		grow()
+		if !step() {
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,94 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEachVisitsEveryIndex(t *testing.T) {
+	seen := make([]int32, 50)
+	err := ForEach(context.Background(), len(seen), 4, func(_ context.Context, i int) error {
+		atomic.AddInt32(&seen[i], 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for i, n := range seen {
+		if n != 1 {
+			t.Fatalf("expected index %d visited once, got %d", i, n)
+		}
+	}
+}
+
+func TestForEachFirstErrorCancels(t *testing.T) {
+	errFirst := errors.New("first")
+	var started sync.WaitGroup
+	started.Add(1)
+	var calls int32
+
+	err := ForEach(context.Background(), 100, 2, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		if i == 0 {
+			// Fail only once the other worker is blocked on the context.
+			started.Wait()
+			return errFirst
+		}
+		if i == 1 {
+			started.Done()
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	if !errors.Is(err, errFirst) {
+		t.Fatalf("expected first error, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("expected no calls after the error, got %d calls", n)
+	}
+}
+
+func TestForEachReturnsContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := ForEach(ctx, 10, 2, func(context.Context, int) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestForEachLimiterBoundsNestedCalls(t *testing.T) {
+	for _, budget := range []int{1, 3} {
+		var active, peak int32
+		work := func() {
+			n := atomic.AddInt32(&active, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&active, -1)
+		}
+
+		ctx := WithLimiter(context.Background(), NewLimiter(budget))
+		err := ForEach(ctx, 4, 4, func(ctx context.Context, _ int) error {
+			return ForEach(ctx, 20, 4, func(context.Context, int) error {
+				work()
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatalf("budget %d: expected no error, got %v", budget, err)
+		}
+		if p := atomic.LoadInt32(&peak); p > int32(budget) {
+			t.Fatalf("budget %d: expected at most %d concurrent calls, got %d", budget, budget, p)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package report renders a run as a single self-contained HTML page.
+package report
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"time"
+)
+
+//go:embed report.html.tmpl
+var pageTemplate string
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{
+	"png": pngURL,
+}).Parse(pageTemplate))
+
+// Report is everything shown on the page.
+type Report struct {
+	Title     string
+	Version   string
+	Generated time.Time
+	Scenes    []Scene
+	// Candidates are listed in the candidate table in this order.
+	Candidates []Candidate
+}
+
+// Scene is one row of the per-scene summary.
+type Scene struct {
+	ID         string
+	Candidates int
+	ScoreMean  float64
+	ScoreMax   float64
+	AreaMin    int
+	AreaMax    int
+	// Error is set when the scene failed.
+	Error string
+	// Quicklooks holds a PNG overview per input raster of the scene.
+	Quicklooks [][]byte
+}
+
+// Failed reports whether the scene failed.
+func (s Scene) Failed() bool {
+	return s.Error != ""
+}
+
+// Candidate is one row of the candidate table.
+type Candidate struct {
+	SceneID        string
+	Lon            float64
+	Lat            float64
+	Score          float64
+	AreaPx         int
+	LengthM        float64
+	WidthM         float64
+	OrientationDeg float64
+	Reason         string
+	// Chip is a PNG thumbnail around the candidate, or nil.
+	Chip []byte
+}
+
+// histogramBins is the number of bars in each histogram.
+const histogramBins = 20
+
+// Histogram is an SVG bar chart of a candidate property.
+type Histogram struct {
+	Title    string
+	Min, Max float64
+	Bars     []Bar
+}
+
+// Bar is one histogram bin in SVG viewBox units.
+type Bar struct {
+	X, Y, Width, Height float64
+	Count               int
+	Low, High           float64
+}
+
+// Histogram viewBox size.
+const (
+	chartWidth  = 400.0
+	chartHeight = 120.0
+)
+
+type pageData struct {
+	Report
+	Failed        int
+	HasQuicklooks bool
+	Histograms    []Histogram
+}
+
+// Write renders r as HTML to w. Images are inlined as data URIs so the page
+// needs no other files.
+func Write(w io.Writer, r Report) error {
+	scores := make([]float64, len(r.Candidates))
+	areas := make([]float64, len(r.Candidates))
+	for i, c := range r.Candidates {
+		scores[i] = c.Score
+		areas[i] = float64(c.AreaPx)
+	}
+
+	data := pageData{
+		Report: r,
+		Histograms: []Histogram{
+			NewHistogram("Score", scores, histogramBins),
+			NewHistogram("Area (px)", areas, histogramBins),
+		},
+	}
+	for _, scene := range r.Scenes {
+		if scene.Failed() {
+			data.Failed++
+		}
+		if len(scene.Quicklooks) > 0 {
+			data.HasQuicklooks = true
+		}
+	}
+
+	if err := page.Execute(w, data); err != nil {
+		return fmt.Errorf("render report: %w", err)
+	}
+	return nil
+}
+
+// NewHistogram bins values into n equal-width bins spanning their range.
+// All values fall into a single bin when they are equal.
+func NewHistogram(title string, values []float64, n int) Histogram {
+	h := Histogram{Title: title}
+	if len(values) == 0 || n <= 0 {
+		return h
+	}
+
+	h.Min, h.Max = values[0], values[0]
+	for _, v := range values {
+		h.Min = math.Min(h.Min, v)
+		h.Max = math.Max(h.Max, v)
+	}
+	if h.Min == h.Max {
+		n = 1
+	}
+
+	counts := make([]int, n)
+	for _, v := range values {
+		counts[binIndex(v, h.Min, h.Max, n)]++
+	}
+
+	peak := 0
+	for _, c := range counts {
+		peak = max(peak, c)
+	}
+	width := (h.Max - h.Min) / float64(n)
+	barWidth := chartWidth / float64(n)
+	for i, c := range counts {
+		height := chartHeight * float64(c) / float64(peak)
+		h.Bars = append(h.Bars, Bar{
+			X:      float64(i) * barWidth,
+			Y:      chartHeight - height,
+			Width:  barWidth,
+			Height: height,
+			Count:  c,
+			Low:    h.Min + float64(i)*width,
+			High:   h.Min + float64(i+1)*width,
+		})
+	}
+	return h
+}
+
+// binIndex returns the bin of v; the maximum falls into the last bin.
+func binIndex(v, lo, hi float64, n int) int {
+	if hi == lo {
+		return 0
+	}
+	i := int((v - lo) / (hi - lo) * float64(n))
+	return min(max(i, 0), n-1)
+}
+
+// pngURL returns data as a PNG data URI.
+func pngURL(data []byte) template.URL {
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(data))
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteInlinesImagesAndRows(t *testing.T) {
+	quicklook := []byte("quicklook-png")
+	chip := []byte("chip-png")
+	r := Report{
+		Title:     "boatdetect run",
+		Version:   "v1.0.0",
+		Generated: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Scenes: []Scene{
+			{ID: "S1A_1", Candidates: 2, ScoreMean: 3.5, ScoreMax: 4, AreaMin: 2, AreaMax: 9, Quicklooks: [][]byte{quicklook}},
+			{ID: "S1A_2", Error: "read raster: <corrupt>"},
+		},
+		Candidates: []Candidate{
+			{SceneID: "S1A_1", Lon: 10.5, Lat: 54.25, Score: 4, AreaPx: 9, Chip: chip},
+			{SceneID: "S1A_1", Lon: 10.6, Lat: 54.3, Score: 3, AreaPx: 2, Reason: "azimuth-ambiguity"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, r); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	html := buf.String()
+
+	for _, want := range []string{
+		"data:image/png;base64," + base64.StdEncoding.EncodeToString(quicklook),
+		"data:image/png;base64," + base64.StdEncoding.EncodeToString(chip),
+		`<tr class="failed"><td class="text">S1A_2</td>`,
+		"read raster: &lt;corrupt&gt;",
+		"azimuth-ambiguity",
+		`<table class="sortable">`,
+		"2 scenes, 1 failed, 2 candidates",
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("expected report to contain %q", want)
+		}
+	}
+	for _, external := range []string{`src="http`, `href="http`, "<link"} {
+		if strings.Contains(html, external) {
+			t.Fatalf("expected no external assets, found %q", external)
+		}
+	}
+}
+
+func TestWriteWithoutCandidates(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, Report{Title: "empty", Scenes: []Scene{{ID: "S1A_1"}}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if html := buf.String(); strings.Contains(html, "<svg") || strings.Contains(html, "Quicklooks") {
+		t.Fatalf("expected no charts or quicklooks without data")
+	}
+}
+
+func TestNewHistogram(t *testing.T) {
+	h := NewHistogram("score", []float64{0, 1, 1, 3, 4}, 4)
+	if h.Min != 0 || h.Max != 4 || len(h.Bars) != 4 {
+		t.Fatalf("expected 4 bars over [0, 4], got %+v", h)
+	}
+	counts := []int{1, 2, 0, 2}
+	for i, bar := range h.Bars {
+		if bar.Count != counts[i] {
+			t.Fatalf("bin %d: expected %d, got %d", i, counts[i], bar.Count)
+		}
+	}
+	if h.Bars[1].Height != chartHeight || h.Bars[1].Y != 0 {
+		t.Fatalf("expected tallest bar to fill the chart, got %+v", h.Bars[1])
+	}
+	if h.Bars[2].Height != 0 {
+		t.Fatalf("expected empty bin to have no height, got %v", h.Bars[2].Height)
+	}
+}
+
+func TestNewHistogramEqualValues(t *testing.T) {
+	h := NewHistogram("area", []float64{5, 5, 5}, 10)
+	if len(h.Bars) != 1 || h.Bars[0].Count != 3 {
+		t.Fatalf("expected one bar of 3, got %+v", h.Bars)
+	}
+}
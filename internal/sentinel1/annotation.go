@@ -0,0 +1,145 @@
+package sentinel1
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// speedOfLight is used to convert two-way slant range time to distance.
+const speedOfLight = 299792458.0
+
+// Annotation holds the acquisition geometry read from a Sentinel-1 SAFE
+// product annotation file.
+type Annotation struct {
+	PlatformHeadingDeg float64
+	RadarFrequencyHz   float64
+	PRFHz              float64
+	// SlantRangeM is the mean slant range over the geolocation grid, or the
+	// near range when the grid is missing.
+	SlantRangeM float64
+	// VelocityMps is the mean platform speed over the orbit state vectors.
+	VelocityMps float64
+	// Geolocation is the tie-point grid relating image positions to ground
+	// coordinates and incidence angle.
+	Geolocation []GeolocationPoint
+}
+
+// GeolocationPoint is one tie point of the annotation geolocation grid.
+type GeolocationPoint struct {
+	Line         int
+	Pixel        int
+	Lat          float64
+	Lon          float64
+	IncidenceDeg float64
+}
+
+// WavelengthM returns the radar wavelength in metres.
+func (a Annotation) WavelengthM() float64 {
+	if a.RadarFrequencyHz <= 0 {
+		return 0
+	}
+	return speedOfLight / a.RadarFrequencyHz
+}
+
+type annotationXML struct {
+	PlatformHeading float64   `xml:"generalAnnotation>productInformation>platformHeading"`
+	RadarFrequency  float64   `xml:"generalAnnotation>productInformation>radarFrequency"`
+	PRF             []float64 `xml:"generalAnnotation>downlinkInformationList>downlinkInformation>prf"`
+	Orbits          []struct {
+		X float64 `xml:"velocity>x"`
+		Y float64 `xml:"velocity>y"`
+		Z float64 `xml:"velocity>z"`
+	} `xml:"generalAnnotation>orbitList>orbit"`
+	SlantRangeTime float64 `xml:"imageAnnotation>imageInformation>slantRangeTime"`
+	GridPoints     []struct {
+		SlantRangeTime float64 `xml:"slantRangeTime"`
+		Line           int     `xml:"line"`
+		Pixel          int     `xml:"pixel"`
+		Latitude       float64 `xml:"latitude"`
+		Longitude      float64 `xml:"longitude"`
+		IncidenceAngle float64 `xml:"incidenceAngle"`
+	} `xml:"geolocationGrid>geolocationGridPointList>geolocationGridPoint"`
+}
+
+// ReadAnnotation parses a Sentinel-1 product annotation XML document.
+func ReadAnnotation(r io.Reader) (Annotation, error) {
+	var doc annotationXML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return Annotation{}, fmt.Errorf("parse annotation: %w", err)
+	}
+
+	ann := Annotation{
+		PlatformHeadingDeg: doc.PlatformHeading,
+		RadarFrequencyHz:   doc.RadarFrequency,
+		PRFHz:              mean(doc.PRF),
+		SlantRangeM:        doc.SlantRangeTime * speedOfLight / 2,
+	}
+
+	if len(doc.GridPoints) > 0 {
+		times := make([]float64, 0, len(doc.GridPoints))
+		ann.Geolocation = make([]GeolocationPoint, 0, len(doc.GridPoints))
+		for _, pt := range doc.GridPoints {
+			times = append(times, pt.SlantRangeTime)
+			ann.Geolocation = append(ann.Geolocation, GeolocationPoint{
+				Line:         pt.Line,
+				Pixel:        pt.Pixel,
+				Lat:          pt.Latitude,
+				Lon:          pt.Longitude,
+				IncidenceDeg: pt.IncidenceAngle,
+			})
+		}
+		ann.SlantRangeM = mean(times) * speedOfLight / 2
+	}
+
+	speeds := make([]float64, 0, len(doc.Orbits))
+	for _, orbit := range doc.Orbits {
+		speeds = append(speeds, math.Sqrt(orbit.X*orbit.X+orbit.Y*orbit.Y+orbit.Z*orbit.Z))
+	}
+	ann.VelocityMps = mean(speeds)
+
+	return ann, nil
+}
+
+// ReadAnnotationFile parses the annotation XML at path.
+func ReadAnnotationFile(path string) (Annotation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Annotation{}, fmt.Errorf("open annotation: %w", err)
+	}
+	defer f.Close()
+
+	return ReadAnnotation(f)
+}
+
+// AnnotationPath returns the annotation file that accompanies a measurement
+// raster inside a SAFE directory, and whether it exists.
+func AnnotationPath(measurementPath string) (string, bool) {
+	dir := filepath.Dir(measurementPath)
+	if filepath.Base(dir) != "measurement" {
+		return "", false
+	}
+
+	base := filepath.Base(measurementPath)
+	name := strings.TrimSuffix(base, filepath.Ext(base)) + ".xml"
+	path := filepath.Join(filepath.Dir(dir), "annotation", name)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
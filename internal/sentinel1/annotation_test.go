@@ -0,0 +1,109 @@
+package sentinel1
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleAnnotation = `<?xml version="1.0" encoding="UTF-8"?>
+<product>
+  <generalAnnotation>
+    <productInformation>
+      <pass>Ascending</pass>
+      <platformHeading>-1.234000e+01</platformHeading>
+      <radarFrequency>5.405000454334350e+09</radarFrequency>
+    </productInformation>
+    <downlinkInformationList count="2">
+      <downlinkInformation><prf>1.700000e+03</prf></downlinkInformation>
+      <downlinkInformation><prf>1.800000e+03</prf></downlinkInformation>
+    </downlinkInformationList>
+    <orbitList count="2">
+      <orbit><velocity><x>3000</x><y>4000</y><z>0</z></velocity></orbit>
+      <orbit><velocity><x>0</x><y>0</y><z>7000</z></velocity></orbit>
+    </orbitList>
+  </generalAnnotation>
+  <imageAnnotation>
+    <imageInformation><slantRangeTime>5.000000e-03</slantRangeTime></imageInformation>
+  </imageAnnotation>
+  <geolocationGrid>
+    <geolocationGridPointList count="2">
+      <geolocationGridPoint>
+        <slantRangeTime>5.000000e-03</slantRangeTime>
+        <line>0</line><pixel>0</pixel>
+        <latitude>1.1</latitude><longitude>103.5</longitude>
+        <incidenceAngle>3.05e+01</incidenceAngle>
+      </geolocationGridPoint>
+      <geolocationGridPoint>
+        <slantRangeTime>7.000000e-03</slantRangeTime>
+        <line>0</line><pixel>25000</pixel>
+        <latitude>1.3</latitude><longitude>105.6</longitude>
+        <incidenceAngle>4.55e+01</incidenceAngle>
+      </geolocationGridPoint>
+    </geolocationGridPointList>
+  </geolocationGrid>
+</product>`
+
+func TestReadAnnotation(t *testing.T) {
+	ann, err := ReadAnnotation(strings.NewReader(sampleAnnotation))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	assertClose(t, "heading", ann.PlatformHeadingDeg, -12.34)
+	assertClose(t, "prf", ann.PRFHz, 1750)
+	assertClose(t, "velocity", ann.VelocityMps, 6000)
+	assertClose(t, "slant range", ann.SlantRangeM, 0.006*speedOfLight/2)
+	assertClose(t, "wavelength", ann.WavelengthM(), speedOfLight/5.405000454334350e+09)
+
+	want := []GeolocationPoint{
+		{Line: 0, Pixel: 0, Lat: 1.1, Lon: 103.5, IncidenceDeg: 30.5},
+		{Line: 0, Pixel: 25000, Lat: 1.3, Lon: 105.6, IncidenceDeg: 45.5},
+	}
+	if !reflect.DeepEqual(ann.Geolocation, want) {
+		t.Fatalf("unexpected geolocation grid: %+v", ann.Geolocation)
+	}
+}
+
+func TestReadAnnotationRejectsInvalidXML(t *testing.T) {
+	if _, err := ReadAnnotation(strings.NewReader("<product>")); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestAnnotationPath(t *testing.T) {
+	safe := filepath.Join(t.TempDir(), "S1A_IW_GRDH.SAFE")
+	measurement := filepath.Join(safe, "measurement", "s1a-iw-grd-vv-001.tiff")
+	annotation := filepath.Join(safe, "annotation", "s1a-iw-grd-vv-001.xml")
+	for _, dir := range []string{filepath.Dir(measurement), filepath.Dir(annotation)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+
+	if _, ok := AnnotationPath(measurement); ok {
+		t.Fatalf("expected missing annotation to be reported")
+	}
+
+	if err := os.WriteFile(annotation, []byte(sampleAnnotation), 0o644); err != nil {
+		t.Fatalf("write annotation: %v", err)
+	}
+	got, ok := AnnotationPath(measurement)
+	if !ok || got != annotation {
+		t.Fatalf("expected %q, got %q (%v)", annotation, got, ok)
+	}
+
+	if _, ok := AnnotationPath(filepath.Join(safe, "scene.tif")); ok {
+		t.Fatalf("expected non-measurement path to have no annotation")
+	}
+}
+
+func assertClose(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-6*math.Max(1, math.Abs(want)) {
+		t.Fatalf("%s: expected %v, got %v", name, want, got)
+	}
+}